@@ -0,0 +1,238 @@
+// Package api is the response layer every route group shares: one error type,
+// one v2 envelope, and the v1 shapes the groups shipped with.
+//
+// Handlers answer through OK, Respond and Fail and never pick a shape
+// themselves. A request that opted into v2 (the MediaTypeV2 Accept header or
+// a /v2 path prefix, see Versioned) gets the envelope; every other request
+// gets its route group's v1 Dialect, so existing clients see the bodies and
+// status codes they always did.
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"earapi/logging"
+)
+
+// MediaTypeV2 in the Accept header selects the v2 envelope.
+const MediaTypeV2 = "application/vnd.earapi.v2+json"
+
+// Error kinds shared across groups. Packages with their own error types
+// (imdb, jellyfin) keep their kinds and set the status explicitly.
+const (
+	KindInvalidInput = "invalid_input"
+	KindUnauthorized = "unauthorized"
+	KindForbidden    = "forbidden"
+	KindNotFound     = "not_found"
+	KindConflict     = "conflict"
+	KindRateLimited  = "rate_limited"
+	KindUpstream     = "upstream"
+	KindUnavailable  = "unavailable"
+	KindInternal     = "internal"
+)
+
+var kindStatus = map[string]int{
+	KindInvalidInput: http.StatusBadRequest,
+	KindUnauthorized: http.StatusUnauthorized,
+	KindForbidden:    http.StatusForbidden,
+	KindNotFound:     http.StatusNotFound,
+	KindConflict:     http.StatusConflict,
+	KindRateLimited:  http.StatusTooManyRequests,
+	KindUpstream:     http.StatusBadGateway,
+	KindUnavailable:  http.StatusServiceUnavailable,
+	KindInternal:     http.StatusInternalServerError,
+}
+
+// StatusFor is the HTTP status for a kind; unknown kinds are 500.
+func StatusFor(kind string) int {
+	if code, ok := kindStatus[kind]; ok {
+		return code
+	}
+	return http.StatusInternalServerError
+}
+
+// Error is a failure the caller can act on: a stable kind to switch on, a
+// message to show, and optionally a hint for fixing it.
+type Error struct {
+	Status  int    `json:"-"` // 0 means StatusFor(Kind)
+	V1      int    `json:"-"` // status outside v2 when it predates the kinds; 0 means HTTPStatus
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// HTTPStatus is the status the error is served with.
+func (e *Error) HTTPStatus() int {
+	if e.Status != 0 {
+		return e.Status
+	}
+	return StatusFor(e.Kind)
+}
+
+// v1Status is the status the error is served with outside v2.
+func (e *Error) v1Status() int {
+	if e.V1 != 0 {
+		return e.V1
+	}
+	return e.HTTPStatus()
+}
+
+// New builds an Error whose status follows its kind.
+func New(kind, message, hint string) *Error {
+	return &Error{Kind: kind, Message: message, Hint: hint}
+}
+
+// Errorf is New with a formatted message and no hint.
+func Errorf(kind, format string, args ...any) *Error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+// WithStatus overrides the kind's status, for domain kinds StatusFor
+// doesn't know.
+func (e *Error) WithStatus(code int) *Error {
+	e.Status = code
+	return e
+}
+
+// WithV1Status keeps the status a v1 route answered this failure with
+// before it had a kind. v2 requests still get the kind's status.
+func (e *Error) WithV1Status(code int) *Error {
+	e.V1 = code
+	return e
+}
+
+// From returns err as an *Error, wrapping anything else as internal.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return &Error{Kind: KindInternal, Message: err.Error()}
+}
+
+// Envelope is the v2 body for every JSON response.
+type Envelope struct {
+	OK        bool   `json:"ok"`
+	Data      any    `json:"data,omitempty"`
+	Error     *Error `json:"error,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Dialect is a v1 response shape. Groups declare theirs with Use.
+type Dialect int
+
+const (
+	// Structured: success bodies as-is, failures as
+	// {"error":{"kind","message","hint"}} with the error's status.
+	// This is the default for routes that don't declare a dialect.
+	Structured Dialect = iota
+	// Plain: success bodies as-is, failures as {"error":"message"}.
+	Plain
+	// Flagged: {"success","msg","data"} for everything, failures
+	// included, with HTTP 200 unless the error sets a v1 status.
+	Flagged
+)
+
+const dialectKey = "api.dialect"
+
+// Use sets the v1 dialect for the routes of a group.
+func Use(d Dialect) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(dialectKey, d)
+		c.Next()
+	}
+}
+
+func dialectOf(c *gin.Context) Dialect {
+	if d, ok := c.Get(dialectKey); ok {
+		return d.(Dialect)
+	}
+	return Structured
+}
+
+type v2Key struct{}
+
+// Versioned strips a leading /v2 from the path and marks the request as v2,
+// so /v2/steam/v1/top is routed, authorised and rate limited exactly like
+// /steam/v1/top. It wraps the whole router.
+func Versioned(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rest, ok := strings.CutPrefix(r.URL.Path, "/v2"); ok && (rest == "" || rest[0] == '/') {
+			if rest == "" {
+				rest = "/"
+			}
+			r2 := r.Clone(context.WithValue(r.Context(), v2Key{}, true))
+			r2.URL.Path = rest
+			r2.URL.RawPath = ""
+			r = r2
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// IsV2 reports whether the request opted into the v2 envelope.
+func IsV2(c *gin.Context) bool {
+	if v, _ := c.Request.Context().Value(v2Key{}).(bool); v {
+		return true
+	}
+	for _, part := range strings.Split(c.GetHeader("Accept"), ",") {
+		if mt, _, err := mime.ParseMediaType(strings.TrimSpace(part)); err == nil && mt == MediaTypeV2 {
+			return true
+		}
+	}
+	return false
+}
+
+// OK answers 200 with data.
+func OK(c *gin.Context, data any) { Respond(c, http.StatusOK, data) }
+
+// Respond answers with data and a success status.
+func Respond(c *gin.Context, code int, data any) {
+	switch {
+	case IsV2(c):
+		c.JSON(code, Envelope{OK: true, Data: data, RequestID: logging.RequestID(c.Request.Context())})
+	case dialectOf(c) == Flagged:
+		c.JSON(code, gin.H{"success": true, "msg": "", "data": data})
+	default:
+		c.JSON(code, data)
+	}
+}
+
+// Fail answers with err and stops the handler chain. It records err on the
+// context, so it also shows up on the request's access log line.
+func Fail(c *gin.Context, err error) {
+	_ = c.Error(err)
+	e := From(err)
+	switch {
+	case IsV2(c):
+		c.AbortWithStatusJSON(e.HTTPStatus(), Envelope{Error: e, RequestID: logging.RequestID(c.Request.Context())})
+	case dialectOf(c) == Flagged:
+		status := http.StatusOK
+		if e.V1 != 0 {
+			status = e.V1
+		}
+		c.AbortWithStatusJSON(status, gin.H{"success": false, "msg": e.Message})
+	case dialectOf(c) == Plain:
+		c.AbortWithStatusJSON(e.v1Status(), gin.H{"error": e.Message})
+	default:
+		c.AbortWithStatusJSON(e.v1Status(), gin.H{"error": e})
+	}
+}
+
+// NoRoute answers unknown v2 paths with the envelope. v1 ones are left to
+// gin, whose plain-text 404 is what they always got.
+func NoRoute(c *gin.Context) {
+	if !IsV2(c) {
+		return
+	}
+	Fail(c, Errorf(KindNotFound, "no route for %s %s", c.Request.Method, c.Request.URL.Path))
+}
@@ -0,0 +1,34 @@
+// Package atomicfile replaces files whole, so a reader or a crash sees the
+// old contents or the new ones, never part of either.
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Write replaces path with data through a rename. Every call writes its own
+// temporary file beside path, so concurrent writers of one path can't mix
+// their contents; the last rename wins. The temporary file is removed if
+// anything fails.
+func Write(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+	}
+	return err
+}
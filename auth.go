@@ -0,0 +1,205 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"earapi/api"
+)
+
+// API key scopes. A key carries any mix of these; admin satisfies every check.
+const (
+	scopeRead           = "read"
+	scopeYoutubeWrite   = "youtube:write"
+	scopeJellyfinWrite  = "jellyfin:write"
+	scopeWatchlistWrite = "watchlist:write"
+	scopeSteamWrite     = "steam:write"
+	scopeAdmin          = "admin"
+)
+
+var knownScopes = []string{scopeRead, scopeSteamWrite, scopeYoutubeWrite, scopeJellyfinWrite, scopeWatchlistWrite, scopeAdmin}
+
+// scopeRules maps write routes to the scope they need, first match wins; an
+// empty method matches any. Everything else needs scopeRead, which is only
+// enforced with protect_reads.
+var scopeRules = []struct {
+	method string
+	prefix string
+	scope  string
+}{
+	{"", "/steam/v1/admin/", scopeAdmin},
+	{"", "/netflix/v1/admin/", scopeAdmin},
+	{http.MethodPost, "/steam/v1/prices", scopeSteamWrite},
+	{http.MethodDelete, "/steam/v1/prices", scopeSteamWrite},
+	{http.MethodPost, "/youtube/v1/", scopeYoutubeWrite},
+	{http.MethodPost, "/jellyfin/v1/", scopeJellyfinWrite},
+	{http.MethodPost, "/watchlist/v1/", scopeWatchlistWrite},
+	{http.MethodDelete, "/watchlist/v1/", scopeWatchlistWrite},
+	{http.MethodDelete, "/compare/v1/", scopeWatchlistWrite},
+	{http.MethodPost, "/compare/v1", scopeWatchlistWrite}, // stores the comparison, with or without the slash
+	{http.MethodPost, "/imdb/v1/", scopeWatchlistWrite},
+}
+
+// ctxAPIKey is the gin context key holding the *apiKeyRecord that
+// authenticated the request, when one did.
+const ctxAPIKey = "earapi.apikey"
+
+// apiKeyRecord is one key as kept in earapi.json. Only the SHA-256 of the key
+// is stored; the key itself is shown once, when it is minted.
+type apiKeyRecord struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Prefix    string    `json:"prefix"` // first characters of the key, to recognise it by
+	Hash      string    `json:"hash"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (k *apiKeyRecord) allows(scope string) bool {
+	return slices.Contains(k.Scopes, scope) || slices.Contains(k.Scopes, scopeAdmin)
+}
+
+// authenticator checks bearer keys against the configured key set.
+type authenticator struct {
+	mu           sync.RWMutex
+	enabled      bool
+	protectReads bool
+	keys         []apiKeyRecord
+}
+
+func newAuthenticator() *authenticator {
+	a := &authenticator{}
+	a.apply(*currentConfig())
+	return a
+}
+
+// apply swaps in the auth settings from cfg.
+func (a *authenticator) apply(cfg earapiSettings) {
+	a.mu.Lock()
+	a.enabled = cfg.Auth.Enabled
+	a.protectReads = cfg.Auth.ProtectReads
+	a.keys = slices.Clone(cfg.Auth.Keys)
+	a.mu.Unlock()
+}
+
+// lookup returns the key whose hash matches token.
+func (a *authenticator) lookup(token string) (*apiKeyRecord, bool) {
+	sum := hashAPIKey(token)
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for i := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(a.keys[i].Hash), []byte(sum)) == 1 {
+			k := a.keys[i]
+			return &k, true
+		}
+	}
+	return nil, false
+}
+
+// presentedKey reads a key from "Authorization: Bearer …" or X-API-Key.
+func presentedKey(c *gin.Context) string {
+	if h := c.GetHeader("Authorization"); h != "" {
+		if token, ok := strings.CutPrefix(h, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	return strings.TrimSpace(c.GetHeader("X-API-Key"))
+}
+
+func requiredScope(method, path string) string {
+	for _, r := range scopeRules {
+		if (r.method == "" || method == r.method) && strings.HasPrefix(path, r.prefix) {
+			return r.scope
+		}
+	}
+	return scopeRead
+}
+
+// authMiddleware enforces API keys on write routes (and on reads, when
+// protect_reads is set). corsMiddleware only gates browsers; this gates curl.
+func authMiddleware(a *authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		a.mu.RLock()
+		enabled, protectReads := a.enabled, a.protectReads
+		a.mu.RUnlock()
+		if !enabled || c.Request.Method == http.MethodOptions || healthPaths[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		var key *apiKeyRecord
+		if token := presentedKey(c); token != "" {
+			k, ok := a.lookup(token)
+			if !ok {
+				c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+				api.Fail(c, api.New(api.KindUnauthorized, "that API key is not valid",
+					"It may have been revoked. Ask for a new key."))
+				return
+			}
+			key = k
+			c.Set(ctxAPIKey, key)
+		}
+
+		scope := requiredScope(c.Request.Method, c.Request.URL.Path)
+		if scope == scopeRead && !protectReads {
+			c.Next()
+			return
+		}
+		if key == nil {
+			c.Header("WWW-Authenticate", "Bearer")
+			api.Fail(c, api.New(api.KindUnauthorized, "this endpoint needs an API key",
+				"Send it as \"Authorization: Bearer <key>\"."))
+			return
+		}
+		if !key.allows(scope) {
+			api.Fail(c, api.Errorf(api.KindForbidden, "API key %q lacks the %s scope", key.Name, scope))
+			return
+		}
+		c.Next()
+	}
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// mintAPIKey creates a new key and the record to store for it.
+func mintAPIKey(name string, scopes []string) (string, apiKeyRecord, error) {
+	for _, s := range scopes {
+		if !slices.Contains(knownScopes, s) {
+			return "", apiKeyRecord{}, fmt.Errorf("unknown scope %q (known: %s)", s, strings.Join(knownScopes, ", "))
+		}
+	}
+	if len(scopes) == 0 {
+		return "", apiKeyRecord{}, fmt.Errorf("give the key at least one scope")
+	}
+
+	var secret [24]byte
+	var id [4]byte
+	if _, err := rand.Read(secret[:]); err != nil {
+		return "", apiKeyRecord{}, err
+	}
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", apiKeyRecord{}, err
+	}
+	key := "eak_" + hex.EncodeToString(secret[:])
+	return key, apiKeyRecord{
+		ID:        hex.EncodeToString(id[:]),
+		Name:      name,
+		Prefix:    key[:10],
+		Hash:      hashAPIKey(key),
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(scopes))),
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
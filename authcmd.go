@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// apikeyCommand mints, lists and revokes API keys in the config file. A
+// running server picks the change up on SIGHUP.
+func apikeyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apikey",
+		Short: "Manage API keys for write endpoints",
+	}
+
+	var name string
+	var scopes []string
+	create := &cobra.Command{
+		Use:   "create",
+		Short: "Mint a new API key and print it once",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := loadConfig(); err != nil {
+				return err
+			}
+			key, rec, err := mintAPIKey(strings.TrimSpace(name), scopes)
+			if err != nil {
+				return err
+			}
+			if err := updateConfigFile(func(cfg *earapiSettings) {
+				cfg.Auth.Keys = append(cfg.Auth.Keys, rec)
+			}); err != nil {
+				return err
+			}
+			fmt.Printf("Created key %s (%s) with scopes %s\n", rec.ID, rec.Name, strings.Join(rec.Scopes, ", "))
+			fmt.Println(key)
+			fmt.Println("Store it now: only its hash is kept in the config.")
+			if !currentConfig().Auth.Enabled {
+				fmt.Println("Note: auth.enabled is false, so keys are not enforced yet.")
+			}
+			return nil
+		},
+	}
+	create.Flags().StringVar(&name, "name", "", "label for the key, e.g. who holds it")
+	create.Flags().StringSliceVar(&scopes, "scope", []string{scopeRead},
+		"scope to grant; repeat or comma-separate ("+strings.Join(knownScopes, ", ")+")")
+	_ = create.MarkFlagRequired("name")
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List API keys (hashes only)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := loadConfig(); err != nil {
+				return err
+			}
+			if len(currentConfig().Auth.Keys) == 0 {
+				fmt.Println("No API keys.")
+				return nil
+			}
+			for _, k := range currentConfig().Auth.Keys {
+				fmt.Printf("%s  %-20s %s…  %s  %s\n", k.ID, k.Name, k.Prefix,
+					k.CreatedAt.Format("2006-01-02"), strings.Join(k.Scopes, ","))
+			}
+			return nil
+		},
+	}
+
+	revoke := &cobra.Command{
+		Use:   "revoke ID",
+		Short: "Revoke an API key by id",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := loadConfig(); err != nil {
+				return err
+			}
+			if !slices.ContainsFunc(currentConfig().Auth.Keys, func(k apiKeyRecord) bool { return k.ID == args[0] }) {
+				return fmt.Errorf("no API key with id %s", args[0])
+			}
+			if err := updateConfigFile(func(cfg *earapiSettings) {
+				cfg.Auth.Keys = slices.DeleteFunc(slices.Clone(cfg.Auth.Keys), func(k apiKeyRecord) bool {
+					return k.ID == args[0]
+				})
+			}); err != nil {
+				return err
+			}
+			fmt.Println("Revoked key", args[0])
+			return nil
+		},
+	}
+
+	cmd.AddCommand(create, list, revoke)
+	return cmd
+}
@@ -11,6 +11,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -174,6 +175,7 @@ func (r *Renderer) FetchHTML(ctx context.Context, url string, ready Ready) (stri
 	tabCtx, cancelTab := chromedp.NewContext(allocCtx)
 	defer cancelTab()
 
+	slog.DebugContext(ctx, "rendering page", "browser", r.Name(), "url", url, "headless", r.Headless)
 	if err := chromedp.Run(tabCtx, chromedp.Navigate(url)); err != nil {
 		return "", fmt.Errorf("navigate %s: %w", url, err)
 	}
@@ -186,12 +188,14 @@ func (r *Renderer) FetchHTML(ctx context.Context, url string, ready Ready) (stri
 		); err == nil && html != "" {
 			last = html
 			if ready == nil || ready(html) {
+				slog.DebugContext(ctx, "page ready", "url", url)
 				return html, nil
 			}
 		}
 
 		select {
 		case <-ctx.Done():
+			slog.WarnContext(ctx, "page never became ready", "url", url, "err", context.Cause(ctx))
 			if last != "" {
 				// Hand back whatever rendered; the caller can decide whether the
 				// partial page is still usable and give a better error if not.
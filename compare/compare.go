@@ -27,6 +27,10 @@ type Result struct {
 	Partial []Entry            `json:"partial"` // on some, not all
 	All     []Entry            `json:"all"`     // union, with owner attribution
 	Stats   Stats              `json:"stats"`
+
+	// Sources are the stored watchlist handles the comparison was built from,
+	// in owner order. Empty when the lists were passed inline.
+	Sources []string `json:"sources,omitempty"`
 }
 
 // Stats summarises the comparison.
@@ -159,6 +163,14 @@ func Compare(inputs []Input) *Result {
 	res.Stats.PartialCnt = len(res.Partial)
 	res.Stats.UnionCount = len(res.All)
 
+	res.Stats.Pairwise = Pairwise(owners, sets)
+	return res
+}
+
+// Pairwise returns the overlap of every pair of owners' sets, in owner order.
+// It works on any key, so other kinds of collection can share the maths.
+func Pairwise[K comparable](owners []string, sets map[string]map[K]bool) []Overlap {
+	out := []Overlap{}
 	for i := range owners {
 		for j := i + 1; j < len(owners); j++ {
 			a, b := owners[i], owners[j]
@@ -173,12 +185,10 @@ func Compare(inputs []Input) *Result {
 			if union > 0 {
 				jac = float64(shared) / float64(union)
 			}
-			res.Stats.Pairwise = append(res.Stats.Pairwise, Overlap{
-				A: a, B: b, Shared: shared, Jaccard: jac,
-			})
+			out = append(out, Overlap{A: a, B: b, Shared: shared, Jaccard: jac})
 		}
 	}
-	return res
+	return out
 }
 
 // richer reports whether a carries more usable metadata than b, so a CSV-only
@@ -195,6 +205,7 @@ func score(t imdb.Title) int {
 	for _, ok := range []bool{
 		t.Title != "", t.Year > 0, t.Type != "", t.PosterURL != "",
 		t.Plot != "", len(t.Genres) > 0, t.Rating > 0, t.RuntimeSec > 0,
+		t.TMDB != nil,
 	} {
 		if ok {
 			n++
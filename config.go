@@ -0,0 +1,516 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/netip"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"earapi/logging"
+)
+
+// Configuration is layered, later layers winning:
+//
+//	defaultSettings() → the JSON file → EARAPI_* environment → command-line flags
+//
+// The environment layer is derived from the JSON tags, so every scalar setting
+// has one: api.port is EARAPI_API_PORT, apikeys.steamapikey is
+// EARAPI_APIKEYS_STEAMAPIKEY, ratelimit.default.per_minute is
+// EARAPI_RATELIMIT_DEFAULT_PER_MINUTE. String lists take comma-separated
+// values; maps and key lists (ratelimit.groups, auth.keys) are file-only.
+
+// dataDirs are created on startup if missing.
+var dataDirs = []string{"steamdata", "jokedata", "moviedata", "youtubedata", "watchlistdata"}
+
+// cliFlags holds the flag layer; only flags the user actually set override.
+var cliFlags struct {
+	fs        *pflag.FlagSet
+	port      string
+	logLevel  string
+	logFormat string
+}
+
+// bindConfigFlags registers the config flags on fs. EARAPI_CONFIG picks the
+// file when --config is not given.
+func bindConfigFlags(fs *pflag.FlagSet) {
+	if v := os.Getenv("EARAPI_CONFIG"); v != "" {
+		configFile = v
+	}
+	cliFlags.fs = fs
+	fs.StringVar(&configFile, "config", configFile, "path to the JSON config file (env EARAPI_CONFIG)")
+	fs.StringVar(&cliFlags.port, "port", "", "listen port, overrides api.port")
+	fs.StringVar(&cliFlags.logLevel, "log-level", "", "debug, info, warn or error; overrides log.level")
+	fs.StringVar(&cliFlags.logFormat, "log-format", "", "text or json; overrides log.format")
+}
+
+// defaultSettings is the bottom layer, and what a missing config file is
+// created with.
+func defaultSettings() earapiSettings {
+	var cfg earapiSettings
+	cfg.API.Port = "8080"
+	cfg.Tmdb.CacheHours = 24
+	cfg.Netflix.CacheMinutes = 60
+	cfg.Netflix.ArchiveHours = 6
+	cfg.Youtube.CacheMinutes = 10
+	cfg.Steam.AppListRefreshMinutes = 360
+	cfg.Steam.LibrarySnapshotHours = 24
+	cfg.Steam.AppDetailsCacheHours = 24
+	cfg.Steam.StoreRateLimit = rateLimitRule{PerMinute: 40, Burst: 10}
+	cfg.Steam.PriceCountry = "us"
+	cfg.Steam.PricePollMinutes = 360
+	cfg.Watchlist.CacheMinutes = 360
+	cfg.Watchlist.StoreBackend = "bolt"
+	cfg.Watchlist.StorePath = "watchlistdata/store.db"
+	cfg.Watchlist.RetentionDays = 90
+	cfg.Auth.Keys = []apiKeyRecord{}
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.Default = rateLimitRule{PerMinute: 120, Burst: 30}
+	cfg.RateLimit.Groups = map[string]rateLimitRule{
+		"steam":   {PerMinute: 20, Burst: 5},
+		"netflix": {PerMinute: 10, Burst: 3},
+		"imdb":    {PerMinute: 20, Burst: 5},
+	}
+	cfg.CORS.Default = corsRule{
+		AllowedOrigins: []string{
+			"https://earentir.github.io",
+			"https://earentir.dev",
+			"https://www.earentir.dev",
+			"http://127.0.0.1:8766",
+			"http://localhost:8766",
+			"http://127.0.0.1:8080",
+			"http://localhost:8080",
+		},
+		AllowedMethods:   []string{"GET", "POST", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Content-Type", "Accept", "Authorization", "X-API-Key", "X-Request-ID"},
+		ExposedHeaders:   []string{"X-Request-ID", "X-RateLimit-Limit", "X-RateLimit-Remaining", "Retry-After", "X-Cache", "ETag"},
+		AllowCredentials: new(false),
+		MaxAgeSeconds:    86400,
+	}
+	cfg.CORS.Groups = map[string]corsRule{}
+	cfg.Log.Level = "info"
+	cfg.Log.Format = "text"
+	cfg.Health.ProbeTTLSeconds = 60
+	return cfg
+}
+
+// liveConfig holds the settings the server is running with. It is replaced
+// whole, never changed in place, so handlers can read it while a refreshed
+// YouTube token is being saved.
+var liveConfig atomic.Pointer[earapiSettings]
+
+// currentConfig returns the running settings; treat them as read-only. Before
+// loadConfig they are the zero settings.
+func currentConfig() *earapiSettings {
+	if cfg := liveConfig.Load(); cfg != nil {
+		return cfg
+	}
+	return &earapiSettings{}
+}
+
+// loadConfig creates the data folders and resolves every configuration layer
+// into the running settings.
+func loadConfig() error {
+	if err := checkAndCreateFolders(append(slices.Clone(dataDirs), filepath.Dir(configFile))...); err != nil {
+		return fmt.Errorf("creating data folders: %w", err)
+	}
+	cfg, err := resolveConfig()
+	if err != nil {
+		return err
+	}
+	liveConfig.Store(&cfg)
+	return nil
+}
+
+// resolveConfig merges all layers and validates the result. It has no side
+// effects beyond creating a missing config file, so a reload can call it and
+// throw the result away.
+func resolveConfig() (earapiSettings, error) {
+	cfg, err := readConfigFile()
+	if err != nil {
+		return cfg, err
+	}
+	if err := applyEnv(&cfg, os.LookupEnv); err != nil {
+		return cfg, err
+	}
+	applyFlags(&cfg)
+	if err := validateConfig(cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// readConfigFile returns the defaults overlaid with the config file, writing
+// the defaults out first if there is no file yet.
+func readConfigFile() (earapiSettings, error) {
+	cfg := defaultSettings()
+	data, err := os.ReadFile(configFile)
+	if errors.Is(err, os.ErrNotExist) {
+		slog.Info("config file not found, creating default config file", "path", configFile)
+		return cfg, writeConfigFile(cfg)
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("reading config %s: %w", configFile, err)
+	}
+
+	// Decode strictly first so a misspelt key is reported rather than silently
+	// ignored; then decode leniently so it doesn't stop the server.
+	strict := json.NewDecoder(bytes.NewReader(data))
+	strict.DisallowUnknownFields()
+	probe := defaultSettings()
+	if err := strict.Decode(&probe); err != nil && strings.HasPrefix(err.Error(), "json: unknown field") {
+		slog.Warn("config file has a setting earapi does not know; ignoring it", "path", configFile, "err", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parsing config %s: %w", configFile, describeJSONErr(data, err))
+	}
+	return cfg, nil
+}
+
+// describeJSONErr adds a line number to syntax and type errors.
+func describeJSONErr(data []byte, err error) error {
+	var offset int64
+	var syn *json.SyntaxError
+	var typ *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syn):
+		offset = syn.Offset
+	case errors.As(err, &typ):
+		offset = typ.Offset
+	default:
+		return err
+	}
+	line := 1 + strings.Count(string(data[:min(int(offset), len(data))]), "\n")
+	return fmt.Errorf("line %d: %w", line, err)
+}
+
+func writeConfigFile(cfg earapiSettings) error {
+	b, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding config: %w", err)
+	}
+	if err := os.WriteFile(configFile, append(b, '\n'), 0o600); err != nil {
+		return fmt.Errorf("writing config %s: %w", configFile, err)
+	}
+	return nil
+}
+
+var configFileMu sync.Mutex
+
+// updateConfigFile applies change to the config file and to the running
+// config. Only the file layer is rewritten, so secrets that came from the
+// environment or flags never end up on disk.
+func updateConfigFile(change func(cfg *earapiSettings)) error {
+	configFileMu.Lock()
+	defer configFileMu.Unlock()
+
+	onDisk, err := readConfigFile()
+	if err != nil {
+		return err
+	}
+	change(&onDisk)
+	if err := writeConfigFile(onDisk); err != nil {
+		return err
+	}
+	next := *currentConfig()
+	change(&next)
+	liveConfig.Store(&next)
+	return nil
+}
+
+// applyEnv overrides settings from EARAPI_* variables found by lookup.
+func applyEnv(cfg *earapiSettings, lookup func(string) (string, bool)) error {
+	var errs []error
+	walkSettings(reflect.ValueOf(cfg).Elem(), "EARAPI", func(name string, f reflect.Value) {
+		raw, ok := lookup(name)
+		if !ok {
+			return
+		}
+		if err := setFromEnv(f, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s=%q: %w", name, raw, err))
+		}
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid environment override:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// walkSettings calls fn for every non-struct field under v, named by its JSON
+// tag path in environment-variable form.
+func walkSettings(v reflect.Value, prefix string, fn func(name string, f reflect.Value)) {
+	t := v.Type()
+	for i := range t.NumField() {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name := prefix + "_" + strings.ToUpper(tag)
+		if f := v.Field(i); f.Kind() == reflect.Struct {
+			walkSettings(f, name, fn)
+		} else {
+			fn(name, f)
+		}
+	}
+}
+
+func setFromEnv(f reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return errors.New("want a whole number")
+		}
+		f.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.New("want true or false")
+		}
+		f.SetBool(b)
+	case reflect.Pointer: // optional settings, *bool so "unset" can inherit
+		if f.Type().Elem().Kind() != reflect.Bool {
+			return errors.New("this setting can only be set in the config file")
+		}
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.New("want true or false")
+		}
+		f.Set(reflect.ValueOf(&b))
+	case reflect.Slice:
+		if f.Type().Elem().Kind() != reflect.String {
+			return errors.New("this setting can only be set in the config file")
+		}
+		var items []string
+		for item := range strings.SplitSeq(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		f.Set(reflect.ValueOf(items))
+	default:
+		return errors.New("this setting can only be set in the config file")
+	}
+	return nil
+}
+
+func applyFlags(cfg *earapiSettings) {
+	fs := cliFlags.fs
+	if fs == nil {
+		return
+	}
+	if fs.Changed("port") {
+		cfg.API.Port = cliFlags.port
+	}
+	if fs.Changed("log-level") {
+		cfg.Log.Level = cliFlags.logLevel
+	}
+	if fs.Changed("log-format") {
+		cfg.Log.Format = cliFlags.logFormat
+	}
+}
+
+// validateConfig reports every problem at once, by setting path.
+func validateConfig(cfg earapiSettings) error {
+	var errs []error
+	bad := func(path, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+	}
+
+	if p, err := strconv.Atoi(cfg.API.Port); err != nil || p < 1 || p > 65535 {
+		bad("api.port", "%q is not a port number (1-65535)", cfg.API.Port)
+	}
+	for _, p := range cfg.API.TrustedProxies {
+		if _, err := netip.ParsePrefix(p); err != nil {
+			if _, err := netip.ParseAddr(p); err != nil {
+				bad("api.trusted_proxies", "%q is not an IP address or CIDR", p)
+			}
+		}
+	}
+	if _, err := logging.ParseLevel(cfg.Log.Level); err != nil {
+		bad("log.level", "%q is not debug, info, warn or error", cfg.Log.Level)
+	}
+	if f := strings.ToLower(cfg.Log.Format); f != "" && f != "text" && f != "json" {
+		bad("log.format", "%q is not text or json", cfg.Log.Format)
+	}
+	if b := cfg.Watchlist.StoreBackend; b != "" && b != "bolt" && b != "memory" {
+		bad("watchlist.store_backend", "%q is not bolt or memory", b)
+	}
+
+	for _, id := range cfg.Steam.LibrarySnapshotUsers {
+		if !validSteamID(id) {
+			bad("steam.library_snapshot_users", "%q is not a 64-bit Steam ID", id)
+		}
+	}
+	if cc := cfg.Steam.PriceCountry; cc != "" && !countryPattern.MatchString(strings.ToLower(cc)) {
+		bad("steam.price_country", "%q is not a two-letter country code", cc)
+	}
+	if u := cfg.Steam.PriceWebhookURL; u != "" {
+		if p, err := url.Parse(u); err != nil || (p.Scheme != "http" && p.Scheme != "https") || p.Host == "" {
+			bad("steam.price_webhook_url", "%q is not an http(s) URL", u)
+		}
+	}
+	for _, cc := range cfg.Netflix.ArchiveCountries {
+		if _, err := netflixCountry(cc); err != nil {
+			bad("netflix.archive_countries", "%q is not a country with a Netflix Top 10", cc)
+		}
+	}
+	if cfg.Health.ProbeTTLSeconds < 0 {
+		bad("health.probe_ttl_seconds", "cannot be negative")
+	}
+
+	checkRule := func(path string, r rateLimitRule) {
+		if r.PerMinute < 0 || r.Burst < 0 {
+			bad(path, "per_minute and burst cannot be negative")
+		}
+	}
+	checkRule("steam.store_ratelimit", cfg.Steam.StoreRateLimit)
+	checkRule("ratelimit.default", cfg.RateLimit.Default)
+	for _, g := range slices.Sorted(maps.Keys(cfg.RateLimit.Groups)) {
+		checkRule("ratelimit.groups."+g, cfg.RateLimit.Groups[g])
+	}
+
+	for i, k := range cfg.Auth.Keys {
+		path := fmt.Sprintf("auth.keys[%d]", i)
+		if len(k.Hash) != 64 {
+			bad(path, "hash is not a SHA-256 hex digest; mint keys with `earapi apikey create`")
+		}
+		for _, s := range k.Scopes {
+			if !slices.Contains(knownScopes, s) {
+				bad(path, "unknown scope %q (known: %s)", s, strings.Join(knownScopes, ", "))
+			}
+		}
+	}
+
+	for _, p := range cfg.CORS.Default.problems() {
+		bad("cors.default", "%s", p)
+	}
+	for _, g := range slices.Sorted(maps.Keys(cfg.CORS.Groups)) {
+		for _, p := range cfg.CORS.Groups[g].over(cfg.CORS.Default).problems() {
+			bad("cors.groups."+g, "%s", p)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration (%s, after environment and flag overrides):\n%w",
+			configFile, errors.Join(errs...))
+	}
+	return nil
+}
+
+// watchlistCacheTTL is the IMDb list cache lifetime: unset means 6h, negative
+// disables the cache.
+func watchlistCacheTTL(cfg earapiSettings) time.Duration {
+	minutes := cfg.Watchlist.CacheMinutes
+	switch {
+	case minutes == 0:
+		return 6 * time.Hour
+	case minutes < 0:
+		return 0
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// tmdbCacheTTL is the TMDB details cache lifetime: unset means 24h, negative
+// disables the cache.
+func tmdbCacheTTL(cfg earapiSettings) time.Duration {
+	hours := cfg.Tmdb.CacheHours
+	switch {
+	case hours == 0:
+		return 24 * time.Hour
+	case hours < 0:
+		return 0
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// restartSettings only take effect on restart: they decide what gets built
+// at startup rather than how it behaves. field returns a pointer to the
+// setting in cfg.
+var restartSettings = []struct {
+	path  string
+	field func(cfg *earapiSettings) any
+}{
+	{"api.port", func(cfg *earapiSettings) any { return &cfg.API.Port }},
+	{"api.trusted_proxies", func(cfg *earapiSettings) any { return &cfg.API.TrustedProxies }},
+	{"apikeys", func(cfg *earapiSettings) any { return &cfg.Apikeys }},
+	{"youtube.client_id", func(cfg *earapiSettings) any { return &cfg.Youtube.ClientID }},
+	{"youtube.client_secret", func(cfg *earapiSettings) any { return &cfg.Youtube.ClientSecret }},
+	{"youtube.default_channel_id", func(cfg *earapiSettings) any { return &cfg.Youtube.DefaultChannel }},
+	{"watchlist.browser_path", func(cfg *earapiSettings) any { return &cfg.Watchlist.BrowserPath }},
+	{"watchlist.browser_headful", func(cfg *earapiSettings) any { return &cfg.Watchlist.BrowserHeadful }},
+	{"watchlist.store_backend", func(cfg *earapiSettings) any { return &cfg.Watchlist.StoreBackend }},
+	{"watchlist.store_path", func(cfg *earapiSettings) any { return &cfg.Watchlist.StorePath }},
+	{"watchlist.retention_days", func(cfg *earapiSettings) any { return &cfg.Watchlist.RetentionDays }},
+}
+
+// structuralChanges lists the restartSettings that differ between the
+// running config and next.
+func structuralChanges(cur, next earapiSettings) []string {
+	var changed []string
+	for _, s := range restartSettings {
+		if !reflect.DeepEqual(s.field(&cur), s.field(&next)) {
+			changed = append(changed, s.path)
+		}
+	}
+	return changed
+}
+
+// keepRestartSettings copies the restartSettings from cur into next, so the
+// published settings describe what is actually running.
+func keepRestartSettings(next *earapiSettings, cur earapiSettings) {
+	for _, s := range restartSettings {
+		reflect.ValueOf(s.field(next)).Elem().Set(reflect.ValueOf(s.field(&cur)).Elem())
+	}
+}
+
+// watchReload re-resolves the configuration on every SIGHUP, publishes it as
+// the running settings, less the ones that need a restart, and hands it to
+// apply. A config that fails validation is rejected whole and the running
+// settings stay in place. report hears the outcome of every attempt.
+func watchReload(apply func(next earapiSettings), report func(err error)) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	var pending []string // restart settings already warned about
+	for range hup {
+		next, err := resolveConfig()
+		report(err)
+		if err != nil {
+			slog.Error("config reload rejected, keeping the running settings", "err", err)
+			continue
+		}
+		// Under configFileMu, so a token being saved by updateConfigFile
+		// lands on these settings rather than the ones they replace.
+		configFileMu.Lock()
+		cur := *currentConfig()
+		changed := structuralChanges(cur, next)
+		if fresh := slices.DeleteFunc(slices.Clone(changed), func(s string) bool { return slices.Contains(pending, s) }); len(fresh) > 0 {
+			slog.Warn("config reload: some changes only apply after a restart", "settings", fresh)
+		}
+		pending = changed
+		keepRestartSettings(&next, cur)
+		liveConfig.Store(&next)
+		configFileMu.Unlock()
+		apply(next)
+		slog.Info("config reloaded", "path", configFile)
+	}
+}
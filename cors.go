@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// corsRule is one CORS policy as configured. In cors.groups, unset fields
+// inherit from cors.default, so an override only names what differs.
+type corsRule struct {
+	AllowedOrigins   []string `json:"allowed_origins,omitempty"` // scheme://host[:port], scheme://*.domain, or "*"
+	AllowedMethods   []string `json:"allowed_methods,omitempty"`
+	AllowedHeaders   []string `json:"allowed_headers,omitempty"`
+	ExposedHeaders   []string `json:"exposed_headers,omitempty"`
+	AllowCredentials *bool    `json:"allow_credentials,omitempty"`
+	MaxAgeSeconds    int      `json:"max_age_seconds,omitempty"`
+}
+
+// over returns r with its unset fields taken from base.
+func (r corsRule) over(base corsRule) corsRule {
+	if r.AllowedOrigins == nil {
+		r.AllowedOrigins = base.AllowedOrigins
+	}
+	if r.AllowedMethods == nil {
+		r.AllowedMethods = base.AllowedMethods
+	}
+	if r.AllowedHeaders == nil {
+		r.AllowedHeaders = base.AllowedHeaders
+	}
+	if r.ExposedHeaders == nil {
+		r.ExposedHeaders = base.ExposedHeaders
+	}
+	if r.AllowCredentials == nil {
+		r.AllowCredentials = base.AllowCredentials
+	}
+	if r.MaxAgeSeconds == 0 {
+		r.MaxAgeSeconds = base.MaxAgeSeconds
+	}
+	return r
+}
+
+// problems lists what is wrong with a fully resolved rule.
+func (r corsRule) problems() []string {
+	var out []string
+	for _, o := range r.AllowedOrigins {
+		if err := checkOrigin(o); err != nil {
+			out = append(out, fmt.Sprintf("allowed_origins: %q %v", o, err))
+		}
+	}
+	for _, m := range r.AllowedMethods {
+		if m == "" || strings.ToUpper(m) != m || strings.ContainsAny(m, " ,") {
+			out = append(out, fmt.Sprintf("allowed_methods: %q is not an upper-case method name", m))
+		}
+	}
+	if r.AllowCredentials != nil && *r.AllowCredentials && slices.Contains(r.AllowedOrigins, "*") {
+		out = append(out, `allow_credentials cannot be combined with the "*" origin`)
+	}
+	if r.MaxAgeSeconds < 0 {
+		out = append(out, "max_age_seconds cannot be negative")
+	}
+	return out
+}
+
+// checkOrigin accepts "*", a bare scheme://host[:port] origin, or a subdomain
+// pattern like https://*.example.com.
+func checkOrigin(o string) error {
+	if o == "*" {
+		return nil
+	}
+	u, err := url.Parse(strings.Replace(o, "://*.", "://wildcard.", 1))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("is not an origin like https://example.com")
+	}
+	if u.Path != "" || u.RawQuery != "" {
+		return errors.New("is an origin, so it cannot have a path")
+	}
+	if strings.Contains(strings.TrimPrefix(u.Host, "wildcard."), "*") {
+		return errors.New("can only use * as the whole first label, as in https://*.example.com")
+	}
+	return nil
+}
+
+// corsMatcher is a corsRule compiled for the request path.
+type corsMatcher struct {
+	any         bool
+	exact       map[string]bool
+	wildcards   []originPattern
+	methods     string
+	headers     string
+	expose      string
+	credentials bool
+	maxAge      string
+}
+
+func compileCORS(r corsRule) *corsMatcher {
+	m := &corsMatcher{
+		exact:       map[string]bool{},
+		methods:     strings.Join(r.AllowedMethods, ", "),
+		headers:     strings.Join(r.AllowedHeaders, ", "),
+		expose:      strings.Join(r.ExposedHeaders, ", "),
+		credentials: r.AllowCredentials != nil && *r.AllowCredentials,
+	}
+	if r.MaxAgeSeconds > 0 {
+		m.maxAge = strconv.Itoa(r.MaxAgeSeconds)
+	}
+	for _, o := range r.AllowedOrigins {
+		switch {
+		case o == "*":
+			m.any = true
+		case strings.Contains(o, "://*."):
+			scheme, domain, _ := strings.Cut(o, "*")
+			m.wildcards = append(m.wildcards, originPattern{scheme: scheme, suffix: domain})
+		default:
+			m.exact[o] = true
+		}
+	}
+	return m
+}
+
+// allows matches an origin. A wildcard covers any depth of subdomain but not
+// the bare domain itself: https://*.example.com matches https://a.example.com
+// and https://a.b.example.com, not https://example.com.
+func (m *corsMatcher) allows(origin string) bool {
+	if m.any || m.exact[origin] {
+		return true
+	}
+	for _, w := range m.wildcards {
+		if rest, ok := strings.CutPrefix(origin, w.scheme); ok &&
+			strings.HasSuffix(rest, w.suffix) && len(rest) > len(w.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// originPattern is https://*.example.com split at the star:
+// scheme "https://", suffix ".example.com".
+type originPattern struct{ scheme, suffix string }
+
+// corsPolicy holds the default rule and the per-route-group overrides.
+type corsPolicy struct {
+	mu     sync.RWMutex
+	def    *corsMatcher
+	groups map[string]*corsMatcher
+}
+
+func newCORSPolicy() *corsPolicy {
+	p := &corsPolicy{}
+	p.apply(*currentConfig())
+	return p
+}
+
+// apply swaps in the CORS settings from cfg.
+func (p *corsPolicy) apply(cfg earapiSettings) {
+	groups := map[string]*corsMatcher{}
+	for g, r := range cfg.CORS.Groups {
+		groups[g] = compileCORS(r.over(cfg.CORS.Default))
+	}
+	def := compileCORS(cfg.CORS.Default)
+	p.mu.Lock()
+	p.def, p.groups = def, groups
+	p.mu.Unlock()
+}
+
+func (p *corsPolicy) forPath(path string) *corsMatcher {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if m, ok := p.groups[routeGroup(path)]; ok {
+		return m
+	}
+	return p.def
+}
+
+// corsMiddleware applies the CORS rule for the request's route group and
+// answers preflight requests itself.
+func corsMiddleware(p *corsPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin != "" {
+			c.Header("Vary", "Origin")
+			if m := p.forPath(c.Request.URL.Path); m.allows(origin) {
+				c.Header("Access-Control-Allow-Origin", origin)
+				if m.credentials {
+					c.Header("Access-Control-Allow-Credentials", "true")
+				}
+				if m.expose != "" {
+					c.Header("Access-Control-Expose-Headers", m.expose)
+				}
+				if c.Request.Method == http.MethodOptions {
+					c.Header("Access-Control-Allow-Methods", m.methods)
+					c.Header("Access-Control-Allow-Headers", m.headers)
+					if m.maxAge != "" {
+						c.Header("Access-Control-Max-Age", m.maxAge)
+					}
+				}
+			}
+		}
+		if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
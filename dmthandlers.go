@@ -1,10 +1,10 @@
 package main
 
 import (
-	"net/http"
 	"strconv"
 	"time"
 
+	"earapi/api"
 	"earapi/dmt"
 
 	"github.com/gin-gonic/gin"
@@ -13,25 +13,21 @@ import (
 func dmtTimestampHandler(c *gin.Context) {
 	in, err := parseDMTInput(c)
 	if err != nil {
-		c.JSON(http.StatusOK, gin.H{"success": false, "msg": err.Error()})
+		api.Fail(c, api.New(api.KindInvalidInput, err.Error(), ""))
 		return
 	}
 
 	result, err := dmt.Convert(in)
 	if err != nil {
-		c.JSON(http.StatusOK, gin.H{"success": false, "msg": err.Error()})
+		api.Fail(c, api.New(api.KindInvalidInput, err.Error(), ""))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"success": true, "msg": "", "data": result})
+	api.OK(c, result)
 }
 
 func dmtFormatsHandler(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"msg":     "",
-		"data":    dmt.Styles,
-	})
+	api.OK(c, dmt.Styles)
 }
 
 func parseDMTInput(c *gin.Context) (dmt.Input, error) {
@@ -18,6 +18,13 @@ const GROUPS = [
         summary: "Current API / app version",
         params: [],
       },
+      {
+        id: "openapi",
+        method: "GET",
+        path: "/openapi.json",
+        summary: "OpenAPI 3 description of every endpoint",
+        params: [],
+      },
     ],
   },
   {
@@ -446,6 +453,10 @@ function renderEndpoint(ep) {
 }
 
 document.addEventListener("DOMContentLoaded", () => {
+  // Served by the API itself (/doc/): try requests against this server.
+  if (location.protocol.startsWith("http") && location.pathname.startsWith("/doc")) {
+    $("#baseUrl").value = location.origin;
+  }
   render();
   $("#baseUrl").addEventListener("input", () => {
     document.querySelectorAll(".endpoint form").forEach((form) => {
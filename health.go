@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	wlpackage "earapi/watchlist"
+)
+
+// Check states. A failing required check makes /readyz answer 503; anything
+// else that fails only degrades the overall status.
+const (
+	checkOK       = "ok"
+	checkDisabled = "disabled"
+	checkDegraded = "degraded"
+	checkFail     = "fail"
+)
+
+// healthPaths skip auth and rate limiting: container runtimes poll them
+// without keys, and often.
+var healthPaths = map[string]bool{"/healthz": true, "/readyz": true}
+
+type healthCheck struct {
+	Name      string     `json:"name"`
+	Status    string     `json:"status"`
+	Required  bool       `json:"required,omitempty"`
+	Detail    string     `json:"detail,omitempty"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"` // active probes: when the cached result was taken
+}
+
+type probeResult struct {
+	status, detail string
+	at             time.Time
+}
+
+// readiness is what /readyz reports. runAPIServer fills in each component as
+// it starts; the active probes run on demand and are cached for the probe TTL.
+type readiness struct {
+	started time.Time
+
+	mu         sync.RWMutex
+	reloadErr  error
+	reloadedAt time.Time
+	ytErr      error
+	ytInit     bool
+	wlsvc      *wlpackage.Service
+	wlErr      error
+	probes     bool
+	probeTTL   time.Duration
+
+	probeMu sync.Mutex // one probe round at a time; callers share its result
+	cache   map[string]probeResult
+}
+
+func newReadiness() *readiness {
+	h := &readiness{started: time.Now(), cache: map[string]probeResult{}}
+	h.apply(*currentConfig())
+	return h
+}
+
+// apply swaps in the probe settings from cfg.
+func (h *readiness) apply(cfg earapiSettings) {
+	ttl := time.Duration(cfg.Health.ProbeTTLSeconds) * time.Second
+	if ttl == 0 {
+		ttl = time.Minute
+	}
+	h.mu.Lock()
+	h.probes, h.probeTTL = cfg.Health.Probes, ttl
+	h.mu.Unlock()
+}
+
+// configReloaded records the outcome of a SIGHUP reload.
+func (h *readiness) configReloaded(err error) {
+	h.mu.Lock()
+	h.reloadErr, h.reloadedAt = err, time.Now()
+	h.mu.Unlock()
+}
+
+func (h *readiness) setYouTube(err error) {
+	h.mu.Lock()
+	h.ytErr, h.ytInit = err, true
+	h.mu.Unlock()
+}
+
+func (h *readiness) setWatchlist(svc *wlpackage.Service, err error) {
+	h.mu.Lock()
+	h.wlsvc, h.wlErr = svc, err
+	h.mu.Unlock()
+}
+
+func (h *readiness) checks(ctx context.Context) []healthCheck {
+	h.mu.RLock()
+	reloadErr, reloadedAt := h.reloadErr, h.reloadedAt
+	ytErr, ytInit := h.ytErr, h.ytInit
+	wlsvc, wlErr := h.wlsvc, h.wlErr
+	probes, ttl := h.probes, h.probeTTL
+	h.mu.RUnlock()
+
+	cfg := healthCheck{Name: "config", Status: checkOK, Required: true, Detail: "loaded from " + configFile}
+	if reloadErr != nil {
+		cfg.Status = checkDegraded
+		cfg.Detail = "last reload at " + reloadedAt.UTC().Format(time.RFC3339) +
+			" was rejected, running the previous settings: " + reloadErr.Error()
+	}
+	out := []healthCheck{cfg}
+
+	for _, dir := range dataDirs {
+		c := healthCheck{Name: "dir:" + dir, Status: checkOK, Required: true}
+		if err := checkWritable(dir); err != nil {
+			c.Status, c.Detail = checkFail, err.Error()
+		}
+		out = append(out, c)
+	}
+
+	yt := healthCheck{Name: "youtube", Status: checkOK}
+	switch {
+	case !ytInit:
+		yt.Status, yt.Detail = checkFail, "not started"
+	case ytErr != nil && currentConfig().Youtube.ClientID == "":
+		yt.Status, yt.Detail = checkDisabled, "no OAuth client configured"
+	case ytErr != nil:
+		yt.Status, yt.Detail = checkFail, ytErr.Error()
+	}
+	out = append(out, yt)
+
+	store := healthCheck{Name: "watchlist_store", Status: checkOK}
+	browser := healthCheck{Name: "browser", Status: checkOK}
+	switch {
+	case wlErr != nil:
+		store.Status, store.Detail = checkFail, wlErr.Error()
+		browser.Status = checkDisabled
+	case wlsvc == nil:
+		store.Status, store.Detail = checkFail, "not started"
+		browser.Status = checkDisabled
+	case wlsvc.BrowserName == "":
+		browser.Status, browser.Detail = checkDegraded, "none found; p.* IMDb aliases need CSV import"
+	default:
+		browser.Detail = wlsvc.BrowserName
+	}
+	out = append(out, store, browser)
+
+	if !probes || wlsvc == nil {
+		return out
+	}
+	return append(out, h.probe(ctx, wlsvc, ttl)...)
+}
+
+// probe calls IMDb and the session's Jellyfin server, reusing results
+// younger than ttl.
+func (h *readiness) probe(ctx context.Context, wlsvc *wlpackage.Service, ttl time.Duration) []healthCheck {
+	h.probeMu.Lock()
+	defer h.probeMu.Unlock()
+
+	run := func(name string, fn func(ctx context.Context) (status, detail string)) healthCheck {
+		r, ok := h.cache[name]
+		if !ok || time.Since(r.at) > ttl {
+			pctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			r.status, r.detail = fn(pctx)
+			r.at = time.Now()
+			cancel()
+			h.cache[name] = r
+		}
+		return healthCheck{Name: name, Status: r.status, Detail: r.detail, CheckedAt: &r.at}
+	}
+
+	return []healthCheck{
+		run("imdb", func(ctx context.Context) (string, string) {
+			if err := wlsvc.IMDb.Ping(ctx); err != nil {
+				return checkFail, err.Error()
+			}
+			return checkOK, ""
+		}),
+		run("jellyfin", func(ctx context.Context) (string, string) {
+			connected, err := wlsvc.PingJellyfin(ctx)
+			switch {
+			case !connected:
+				return checkDisabled, "no server connected"
+			case err != nil:
+				return checkFail, err.Error()
+			}
+			return checkOK, ""
+		}),
+	}
+}
+
+// checkWritable creates and removes a file in dir.
+func checkWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".readyz-*")
+	if err != nil {
+		var pe *os.PathError
+		if errors.As(err, &pe) {
+			return pe.Err
+		}
+		return err
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
+
+// healthzHandler answers as long as the process can serve HTTP at all.
+func healthzHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": checkOK})
+}
+
+// handler reports every check, with 503 when a required one fails.
+// Like /metrics it is the same in v1 and v2.
+func (h *readiness) handler(c *gin.Context) {
+	checks := h.checks(c.Request.Context())
+	status, code := checkOK, http.StatusOK
+	for _, ch := range checks {
+		switch {
+		case ch.Status == checkFail && ch.Required:
+			status, code = checkFail, http.StatusServiceUnavailable
+		case (ch.Status == checkFail || ch.Status == checkDegraded) && status == checkOK:
+			status = checkDegraded
+		}
+	}
+	c.JSON(code, gin.H{
+		"status":         status,
+		"version":        appVersion,
+		"uptime_seconds": int(time.Since(h.started).Seconds()),
+		"checks":         checks,
+	})
+}
@@ -6,10 +6,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"math/rand"
 	"net/http"
 	"strings"
 	"time"
+
+	"earapi/metrics"
 )
 
 const (
@@ -72,6 +75,8 @@ func (c *Client) post(ctx context.Context, body gqlRequest, out any) error {
 				d = 8 * time.Second
 			}
 			d += time.Duration(rand.Int63n(int64(250 * time.Millisecond)))
+			slog.WarnContext(ctx, "imdb request failed, retrying",
+				"attempt", attempt+1, "backoff", d, "err", lastErr)
 			select {
 			case <-time.After(d):
 			case <-ctx.Done():
@@ -90,8 +95,10 @@ func (c *Client) post(ctx context.Context, body gqlRequest, out any) error {
 		req.Header.Set("Accept", "application/json")
 		req.Header.Set("User-Agent", userAgent)
 
+		done := metrics.Upstream("imdb", "graphql")
 		resp, err := c.HTTP.Do(req)
 		if err != nil {
+			done(metrics.OutcomeRetry)
 			lastErr = err
 			continue // transient network failure — retry
 		}
@@ -99,28 +106,62 @@ func (c *Client) post(ctx context.Context, body gqlRequest, out any) error {
 		data, readErr := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
 		resp.Body.Close()
 		if readErr != nil {
+			done(metrics.OutcomeRetry)
 			lastErr = readErr
 			continue
 		}
 
 		switch {
 		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+			done(metrics.OutcomeRetry)
 			lastErr = fmt.Errorf("imdb returned HTTP %d", resp.StatusCode)
 			continue // retryable
 		case resp.StatusCode != http.StatusOK:
+			done(metrics.OutcomeError)
 			return newErr(ErrKindUpstream,
 				fmt.Sprintf("IMDb returned HTTP %d", resp.StatusCode), "", nil)
 		}
 
 		if err := json.Unmarshal(data, out); err != nil {
+			done(metrics.OutcomeError)
 			return newErr(ErrKindUpstream, "could not decode IMDb's response", "", err)
 		}
+		done(metrics.OutcomeOK)
 		return nil
 	}
 	return newErr(ErrKindTransport, "could not reach IMDb after several attempts",
 		"Check your internet connection and try again.", lastErr)
 }
 
+// Ping makes one unretried request to the GraphQL endpoint, for health
+// checks. Any HTTP 200 counts: the question is whether IMDb answers at all.
+func (c *Client) Ping(ctx context.Context) error {
+	if err := c.wait(ctx); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint,
+		strings.NewReader(`{"query":"{__typename}"}`))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+
+	done := metrics.Upstream("imdb", "ping")
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		done(metrics.OutcomeError)
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		done(metrics.OutcomeError)
+		return fmt.Errorf("IMDb returned HTTP %d", resp.StatusCode)
+	}
+	done(metrics.OutcomeOK)
+	return nil
+}
+
 // classify maps GraphQL errors onto our error kinds so the UI can react.
 func classify(errs []gqlError, ref ListRef) error {
 	if len(errs) == 0 {
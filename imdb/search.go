@@ -0,0 +1,78 @@
+package imdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"earapi/metrics"
+)
+
+// suggestEndpoint is IMDb's search-as-you-type service. GraphQL has no
+// stable title search; this answers anonymous GETs with the same ids.
+const suggestEndpoint = "https://v3.sg.media-imdb.com/suggestion/x/"
+
+// Suggestion is one title IMDb suggests for a query, most popular first.
+type Suggestion struct {
+	IMDbID string `json:"imdb_id"`
+	Title  string `json:"title"`
+	Year   int    `json:"year,omitempty"`
+	Type   string `json:"type,omitempty"` // movie, tvSeries, tvMiniSeries, tvMovie, …
+}
+
+// Suggest looks query up the way IMDb's search box does. People and other
+// non-title hits are dropped; no hits is an empty slice, not an error.
+func (c *Client) Suggest(ctx context.Context, query string) ([]Suggestion, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, newErr(ErrKindInvalidInput, "a search query is required", "", nil)
+	}
+	if err := c.wait(ctx); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, suggestEndpoint+url.PathEscape(strings.ToLower(query))+".json", nil)
+	if err != nil {
+		return nil, newErr(ErrKindTransport, "could not build request", "", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+
+	done := metrics.Upstream("imdb", "suggest")
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		done(metrics.OutcomeError)
+		return nil, newErr(ErrKindTransport, "could not reach IMDb search", "", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if resp.StatusCode != http.StatusOK {
+		done(metrics.OutcomeError)
+		return nil, newErr(ErrKindUpstream, fmt.Sprintf("IMDb search returned HTTP %d", resp.StatusCode), "", nil)
+	}
+
+	var raw struct {
+		D []struct {
+			ID  string `json:"id"`
+			L   string `json:"l"`   // title
+			Y   int    `json:"y"`   // year
+			QID string `json:"qid"` // title type
+		} `json:"d"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		done(metrics.OutcomeError)
+		return nil, newErr(ErrKindUpstream, "could not decode IMDb's search response", "", err)
+	}
+	done(metrics.OutcomeOK)
+
+	out := []Suggestion{}
+	for _, d := range raw.D {
+		if IsTitleID(d.ID) {
+			out = append(out, Suggestion{IMDbID: d.ID, Title: d.L, Year: d.Y, Type: d.QID})
+		}
+	}
+	return out, nil
+}
@@ -52,6 +52,27 @@ type Title struct {
 	Plot       string   `json:"plot,omitempty"`
 	PosterURL  string   `json:"poster_url,omitempty"`
 	IMDbURL    string   `json:"imdb_url"`
+
+	TMDB *TMDBInfo `json:"tmdb,omitempty"` // set by watchlist enrichment
+}
+
+// TMDBInfo is what The Movie Database adds to a title: its own id, artwork
+// IMDb doesn't have, and where the title streams.
+type TMDBInfo struct {
+	ID               int                      `json:"id"`
+	Type             string                   `json:"type"` // movie | tv
+	BackdropURL      string                   `json:"backdrop_url,omitempty"`
+	OriginalLanguage string                   `json:"original_language,omitempty"`
+	Providers        map[string]StreamOptions `json:"providers,omitempty"` // by ISO 3166-1 region
+	EnrichedAt       time.Time                `json:"enriched_at"`
+}
+
+// StreamOptions names the services a title streams on in one region.
+type StreamOptions struct {
+	Link   string   `json:"link,omitempty"` // TMDB's watch page for the region
+	Stream []string `json:"stream,omitempty"`
+	Free   []string `json:"free,omitempty"`
+	Ads    []string `json:"ads,omitempty"`
 }
 
 // Watchlist is a fetched list plus provenance.
@@ -13,10 +13,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
+
+	"earapi/metrics"
 )
 
 // Client is a configured connection to one Jellyfin server.
@@ -129,12 +132,26 @@ func (c *Client) do(ctx context.Context, method, path string, q url.Values, body
 		req.Header.Set("Content-Type", "application/json")
 	}
 
+	// Label by first path segment (/Items, /Playlists, …): ids further down
+	// the path would give every playlist its own series.
+	op, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	done := metrics.Upstream("jellyfin", op)
+	start := time.Now()
 	resp, err := c.HTTP.Do(req)
 	if err != nil {
+		done(metrics.OutcomeError)
+		slog.WarnContext(ctx, "jellyfin request failed", "method", method, "path", path, "err", err)
 		return jfErr("transport", "could not reach the Jellyfin server",
 			"Check the address and that the server is running and reachable from here.", err)
 	}
 	defer resp.Body.Close()
+	slog.DebugContext(ctx, "jellyfin request", "method", method, "path", path,
+		"status", resp.StatusCode, "duration", time.Since(start))
+	if resp.StatusCode >= 400 {
+		done(metrics.OutcomeError)
+	} else {
+		done(metrics.OutcomeOK)
+	}
 
 	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<20))
 
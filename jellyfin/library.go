@@ -89,7 +89,7 @@ func (c *Client) ScanLibrary(ctx context.Context, userID string, progress Progre
 					idx.byIMDb[id] = it
 				}
 			}
-			for _, k := range []string{normalizeTitle(it.Name), normalizeTitle(it.OriginalTitle)} {
+			for _, k := range []string{NormalizeTitle(it.Name), NormalizeTitle(it.OriginalTitle)} {
 				if k != "" {
 					idx.byTitle[k] = append(idx.byTitle[k], it)
 				}
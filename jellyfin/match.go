@@ -47,9 +47,9 @@ type MatchStats struct {
 // libraries and IMDb disagree about "The"/"A" often enough to matter.
 var articles = []string{"the ", "a ", "an ", "le ", "la ", "les ", "el ", "der ", "die ", "das "}
 
-// normalizeTitle folds a title into a comparison key: lowercase, no diacritics,
+// NormalizeTitle folds a title into a comparison key: lowercase, no diacritics,
 // no punctuation, no leading article, collapsed whitespace.
-func normalizeTitle(s string) string {
+func NormalizeTitle(s string) string {
 	s = strings.ToLower(strings.TrimSpace(s))
 	if s == "" {
 		return ""
@@ -168,7 +168,7 @@ func (idx *LibraryIndex) Match(titles []imdb.Title) *MatchResult {
 // then Szelíd), requiring a compatible year in both cases.
 func (idx *LibraryIndex) matchByTitle(t imdb.Title, used map[string]bool) (Item, bool) {
 	for _, name := range []string{t.Title, t.OrigTitle} {
-		key := normalizeTitle(name)
+		key := NormalizeTitle(name)
 		if key == "" {
 			continue
 		}
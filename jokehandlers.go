@@ -4,6 +4,8 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+
+	"earapi/api"
 )
 
 // curl "http://localhost:8080/joke?type=geek"
@@ -13,33 +15,27 @@ func jokeHandler(c *gin.Context) {
 	case "geek":
 		jokes, err := loadRandomJoke("jokedata/geekjokes.json")
 		if err != nil {
-			c.JSON(http.StatusBadGateway, gin.H{
-				"error": "cant load geekjokes.json",
-			})
+			api.Fail(c, api.New(api.KindUnavailable, "cant load geekjokes.json", "").WithV1Status(http.StatusBadGateway))
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
+		api.OK(c, gin.H{
 			"joke": jokes,
 		})
 
 	case "excuse", "bofh":
 		excuse, err := loadRandomJoke("jokedata/bofh.json")
 		if err != nil {
-			c.JSON(http.StatusBadGateway, gin.H{
-				"error": "cant load bofh.json",
-			})
+			api.Fail(c, api.New(api.KindUnavailable, "cant load bofh.json", "").WithV1Status(http.StatusBadGateway))
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
+		api.OK(c, gin.H{
 			"excuse": excuse,
 		})
 		// Handle bofh excuse
 	default:
 		// Handle default joke
-		c.JSON(http.StatusMethodNotAllowed, gin.H{
-			"error": "unknown type",
-		})
+		api.Fail(c, api.New(api.KindInvalidInput, "unknown type", "Use type=geek or type=excuse.").WithV1Status(http.StatusMethodNotAllowed))
 	}
 }
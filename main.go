@@ -3,24 +3,28 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/cobra"
 
+	"earapi/api"
+	"earapi/logging"
+	"earapi/metrics"
+	"earapi/tmdb"
 	wlpackage "earapi/watchlist"
 	ytpackage "earapi/youtube"
 )
 
 var (
 	configFile = "config/earapi.json"
-	config     earapiSettings
 	appVersion = "v0.0.31"
 )
 
@@ -30,25 +34,34 @@ func main() {
 	var authDevice bool
 
 	cmd := &cobra.Command{
-		Use:     "earapi",
-		Short:   "Ear API server",
-		Version: appVersion,
+		Use:           "earapi",
+		Short:         "Ear API server",
+		Version:       appVersion,
+		SilenceUsage:  true,
+		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			loadConfig()
+			if err := loadConfig(); err != nil {
+				return err
+			}
+			cfg := currentConfig()
+			if err := logging.Setup(os.Stderr, cfg.Log.Level, cfg.Log.Format); err != nil {
+				return err
+			}
 
 			if authURL {
-				fmt.Println(ytpackage.BuildAuthURL(config.Youtube.ClientID, config.Youtube.ClientSecret))
+				fmt.Println(ytpackage.BuildAuthURL(cfg.Youtube.ClientID, cfg.Youtube.ClientSecret))
 				return nil
 			}
 			if authCode != "" {
-				rt, _, err := ytpackage.ExchangeCode(context.Background(), config.Youtube.ClientID, config.Youtube.ClientSecret, authCode)
+				rt, _, err := ytpackage.ExchangeCode(context.Background(), cfg.Youtube.ClientID, cfg.Youtube.ClientSecret, authCode)
 				if err != nil {
 					fmt.Println("exchange error:", err)
 					return nil
 				}
 				if rt != "" {
-					config.Youtube.RefreshToken = rt
-					saveConfig()
+					if err := saveRefreshToken(rt); err != nil {
+						return err
+					}
 					fmt.Println("Saved refresh token to config.")
 				} else {
 					fmt.Println("No refresh token received; ensure AccessTypeOffline and ApprovalForce.")
@@ -58,20 +71,21 @@ func main() {
 
 			if authDevice {
 				ctx := context.Background()
-				start, err := ytpackage.StartDeviceFlow(ctx, config.Youtube.ClientID)
+				start, err := ytpackage.StartDeviceFlow(ctx, cfg.Youtube.ClientID)
 				if err != nil {
 					fmt.Println("device flow start error:", err)
 					return nil
 				}
 				fmt.Printf("Visit: %s\nEnter code: %s\n", start.VerificationURL, start.UserCode)
-				rt, _, err := ytpackage.PollDeviceToken(ctx, config.Youtube.ClientID, config.Youtube.ClientSecret, start.DeviceCode, start.Interval)
+				rt, _, err := ytpackage.PollDeviceToken(ctx, cfg.Youtube.ClientID, cfg.Youtube.ClientSecret, start.DeviceCode, start.Interval)
 				if err != nil {
 					fmt.Println("device flow poll error:", err)
 					return nil
 				}
 				if rt != "" {
-					config.Youtube.RefreshToken = rt
-					saveConfig()
+					if err := saveRefreshToken(rt); err != nil {
+						return err
+					}
 					fmt.Println("Saved refresh token to config.")
 				}
 				return nil
@@ -85,185 +99,274 @@ func main() {
 	cmd.Flags().BoolVar(&authURL, "youtube-auth-url", false, "print YouTube OAuth URL and exit")
 	cmd.Flags().StringVar(&authCode, "youtube-auth-code", "", "exchange OAuth code for refresh token")
 	cmd.Flags().BoolVar(&authDevice, "youtube-auth-device", false, "start OAuth device flow for headless auth")
+	bindConfigFlags(cmd.PersistentFlags())
+	cmd.AddCommand(apikeyCommand())
 
 	if err := cmd.Execute(); err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(125)
 	}
 }
 
-func runAPIServer() {
-	// setup gin to build the API
-	r := gin.Default()
-	r.Use(corsMiddleware())
-
-	// Handler for the root path
-	r.GET("/", func(c *gin.Context) { rootHandler(c, r) })
-
-	steamv1Group := r.Group("/steam/v1/")
-	{
-		// steamGroup.GET("/", steamHandler)
-		steamv1Group.GET("/top", steamTopHandler)
-		steamv1Group.GET("/getuserid", steamUserIDHandler)
-		steamv1Group.GET("/appsused", steamUserAppsUsedHandler)
-		steamv1Group.GET("/appdata", steamAppDataHandler)
-		steamv1Group.GET("/search", searchSteamAppHandler)
-	}
-
-	r.GET("/joke", jokeHandler)
-
-	tmdbGroup := r.Group("/tmdb/v1/")
-	{
-		// movieGroup.GET("/", movieHandler)
-		tmdbGroup.GET("/search", movieSearchHandler)
-		// movieGroup.GET("/actor", movieActorHandler)
-	}
-
-	netflixGroup := r.Group("/netflix/v1/")
-	{
-		netflixGroup.GET("/top", netflixTopHandler)
-	}
+// saveRefreshToken persists a (new) YouTube refresh token to the config file.
+func saveRefreshToken(rt string) error {
+	return updateConfigFile(func(cfg *earapiSettings) { cfg.Youtube.RefreshToken = rt })
+}
 
-	tilecalcGroup := r.Group("/tilecalc/v1/")
-	{
-		tilecalcGroup.GET("/arrange", tilecalcArrangeHandler)
-		tilecalcGroup.GET("/coverage", tilecalcCoverageHandler)
-	}
+func runAPIServer() {
+	cfg := *currentConfig()
 
-	dmtGroup := r.Group("/dmt/v1/")
-	{
-		dmtGroup.GET("/timestamp", dmtTimestampHandler)
-		dmtGroup.GET("/formats", dmtFormatsHandler)
+	// setup gin to build the API; access lines come from logging.Middleware
+	r := gin.New()
+	// Only a proxy we run may say who the client is; everyone else could
+	// pick a new X-Forwarded-For per request and a fresh rate limit bucket.
+	if err := r.SetTrustedProxies(cfg.API.TrustedProxies); err != nil {
+		slog.Error("api.trusted_proxies rejected, trusting no proxy", "err", err)
+		_ = r.SetTrustedProxies(nil)
 	}
+	r.Use(gin.Recovery())
+	r.Use(logging.Middleware())
+	r.Use(metrics.Middleware())
+	cors, authn, limiter := newCORSPolicy(), newAuthenticator(), newRateLimiter()
+	health := newReadiness()
+	steamApps.apply(cfg)
+	go steamApps.run(context.Background(), cfg.Apikeys.Steamapikey)
+	steamLibrary.apply(cfg)
+	steamDetails.apply(cfg)
+	go steamLibrary.run(context.Background(), cfg.Apikeys.Steamapikey)
+	steamPrices.apply(cfg)
+	go steamPrices.run(context.Background())
+	netflixTop.apply(cfg)
+	netflixArchive.apply(cfg)
+	go netflixArchive.run(context.Background())
+	r.Use(corsMiddleware(cors))
+	r.Use(authMiddleware(authn))
+	r.Use(rateLimitMiddleware(limiter))
 
-	r.GET("/version", versionHandler)
+	tmdbClient = tmdb.NewClient(cfg.Apikeys.Tmdbapitoken)
+	tmdbClient.SetCache("moviedata", tmdbCacheTTL(cfg))
+	registerRoutes(r, health)
 
 	// youtube routes
+	var ytsvc *ytpackage.Service
 	{
 		ytcfg := ytpackage.Config{
-			ClientID:       config.Youtube.ClientID,
-			ClientSecret:   config.Youtube.ClientSecret,
-			RefreshToken:   config.Youtube.RefreshToken,
-			DefaultChannel: config.Youtube.DefaultChannel,
-			CacheMinutes:   config.Youtube.CacheMinutes,
-			OnRefresh: func(newToken string) error {
-				// Persist new refresh token back into config and file if rotated
-				config.Youtube.RefreshToken = newToken
-				// best-effort write
-				f, err := os.ReadFile(configFile)
-				if err == nil && len(f) > 0 {
-					// naive replace; for a robust approach, re-marshal config struct
-					// but to avoid altering other fields, we re-encode the struct.
-					type cfgAlias earapiSettings
-					b, err2 := json.MarshalIndent(cfgAlias(config), "\t\t\t\t\t\t\t\t", "\t")
-					if err2 == nil {
-						_ = os.WriteFile(configFile, b, 0644)
-					}
-				}
-				return nil
-			},
+			ClientID:       cfg.Youtube.ClientID,
+			ClientSecret:   cfg.Youtube.ClientSecret,
+			RefreshToken:   cfg.Youtube.RefreshToken,
+			DefaultChannel: cfg.Youtube.DefaultChannel,
+			CacheMinutes:   cfg.Youtube.CacheMinutes,
+			// Persist a rotated refresh token back into the config file
+			OnRefresh: saveRefreshToken,
 		}
-		ytsvc, err := ytpackage.New(context.Background(), ytcfg)
+		var err error
+		ytsvc, err = ytpackage.New(context.Background(), ytcfg)
+		health.setYouTube(err)
 		if err != nil {
-			fmt.Println("YouTube init error:", err)
+			slog.Warn("youtube disabled", "err", err)
 		} else {
 			ytpackage.RegisterRoutes(r, ytsvc)
 		}
 	}
 
 	// IMDb watchlist + Jellyfin playlist routes
+	var wlsvc *wlpackage.Service
 	{
-		cacheMinutes := config.Watchlist.CacheMinutes
-		if cacheMinutes == 0 {
-			cacheMinutes = 360 // 6h default when unset / old config
+		retentionDays := cfg.Watchlist.RetentionDays
+		if retentionDays == 0 {
+			retentionDays = 90 // default when unset / old config
 		}
-		var cacheTTL time.Duration
-		if cacheMinutes < 0 {
-			cacheTTL = 0 // negative disables disk cache
-		} else {
-			cacheTTL = time.Duration(cacheMinutes) * time.Minute
+		var retention time.Duration
+		if retentionDays > 0 {
+			retention = time.Duration(retentionDays) * 24 * time.Hour
+		} // negative keeps stored handles forever
+		storePath := cfg.Watchlist.StorePath
+		if storePath == "" {
+			storePath = "watchlistdata/store.db"
 		}
-		wlsvc := wlpackage.New(wlpackage.Config{
+
+		var err error
+		wlsvc, err = wlpackage.New(wlpackage.Config{
 			CacheDir:       "watchlistdata",
-			CacheTTL:       cacheTTL,
-			BrowserPath:    config.Watchlist.BrowserPath,
-			BrowserHeadful: config.Watchlist.BrowserHeadful,
+			CacheTTL:       watchlistCacheTTL(cfg),
+			BrowserPath:    cfg.Watchlist.BrowserPath,
+			BrowserHeadful: cfg.Watchlist.BrowserHeadful,
+			StoreBackend:   cfg.Watchlist.StoreBackend,
+			StorePath:      storePath,
+			Retention:      retention,
+			TMDB:           tmdbClient,
 		})
-		wlpackage.RegisterRoutes(r, wlsvc)
-		if wlsvc.BrowserName != "" {
-			fmt.Println("Watchlist alias resolution via:", wlsvc.BrowserName)
+		health.setWatchlist(wlsvc, err)
+		if err != nil {
+			slog.Error("watchlist init failed", "err", err)
 		} else {
-			fmt.Println("Watchlist: no browser found — p.* IMDb aliases need CSV import")
+			wlpackage.RegisterRoutes(r, wlsvc)
+			netflixIMDb.setWatchlist(wlsvc)
+			jobs := wlsvc.Jobs
+			metrics.RegisterJobs(func() map[string]int {
+				out := map[string]int{}
+				for state, n := range jobs.Counts() {
+					out[string(state)] = n
+				}
+				return out
+			})
+			if wlsvc.BrowserName != "" {
+				slog.Info("watchlist alias resolution via browser", "browser", wlsvc.BrowserName)
+			} else {
+				slog.Warn("watchlist: no browser found, p.* IMDb aliases need CSV import")
+			}
 		}
 	}
 
+	checkOpenAPI(r.Routes())
+
 	httpserver := &http.Server{
-		Addr:    fmt.Sprintf("%s%s", ":", config.API.Port),
-		Handler: r,
+		Addr:    fmt.Sprintf("%s%s", ":", cfg.API.Port),
+		Handler: api.Versioned(r), // /v2/... is the same routes with the v2 envelope
 	}
 
 	go func() {
 		if err := httpserver.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			fmt.Println(err)
+			slog.Error("http server stopped", "err", err)
 		}
 	}()
+	slog.Info("listening", "addr", httpserver.Addr, "version", appVersion)
+
+	// SIGHUP re-reads the config; see watchReload for what applies live.
+	go watchReload(func(next earapiSettings) {
+		if err := logging.Setup(os.Stderr, next.Log.Level, next.Log.Format); err != nil {
+			slog.Error("config reload: log settings", "err", err)
+		}
+		cors.apply(next)
+		steamApps.apply(next)
+		steamLibrary.apply(next)
+		netflixTop.apply(next)
+		netflixArchive.apply(next)
+		steamDetails.apply(next)
+		steamPrices.apply(next)
+		tmdbClient.SetCacheTTL(tmdbCacheTTL(next))
+		health.apply(next)
+		authn.apply(next)
+		limiter.apply(next)
+		if ytsvc != nil {
+			ytsvc.SetCacheMinutes(next.Youtube.CacheMinutes)
+		}
+		if wlsvc != nil {
+			wlsvc.Store.SetCacheTTL(watchlistCacheTTL(next))
+		}
+	}, health.configReloaded)
 
 	// setup channels for capturing the termination signal from the OS
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 
 	<-signals
-	fmt.Println("Shutting down the API")
+	slog.Info("shutting down")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := httpserver.Shutdown(ctx); err != nil {
-		fmt.Println(err)
+		slog.Error("shutdown", "err", err)
+	}
+	if wlsvc != nil {
+		if err := wlsvc.Close(); err != nil {
+			slog.Error("closing watchlist store", "err", err)
+		}
 	}
 }
 
-func versionHandler(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"version": appVersion,
-	})
-}
+// registerRoutes adds every route that doesn't depend on an optional
+// service. The YouTube and watchlist services register their own once they
+// start.
+func registerRoutes(r *gin.Engine, health *readiness) {
+	r.NoRoute(api.NoRoute)
+
+	// Handler for the root path
+	r.GET("/", func(c *gin.Context) { rootHandler(c, r) })
+	r.GET("/metrics", metrics.Handler())
+	r.GET("/healthz", healthzHandler)
+	r.GET("/readyz", health.handler)
+	registerDocs(r)
 
-// corsMiddleware allows the tools UI (GitHub Pages + local proxy) to call the API.
-func corsMiddleware() gin.HandlerFunc {
-	allowed := map[string]bool{
-		"https://earentir.github.io": true,
-		"https://earentir.dev":       true,
-		"https://www.earentir.dev":   true,
-		"http://127.0.0.1:8766":      true,
-		"http://localhost:8766":      true,
-		"http://127.0.0.1:8080":      true,
-		"http://localhost:8080":      true,
+	steamv1Group := r.Group("/steam/v1/", api.Use(api.Flagged))
+	{
+		// steamGroup.GET("/", steamHandler)
+		steamv1Group.GET("/top", steamTopHandler)
+		steamv1Group.GET("/getuserid", steamUserIDHandler)
+		steamv1Group.GET("/appsused", steamUserAppsUsedHandler)
+		steamv1Group.GET("/appdata", steamAppDataHandler)
+		steamv1Group.POST("/appdata/batch", steamAppDataBatchHandler)
+		steamv1Group.GET("/search", searchSteamAppHandler)
+		steamv1Group.GET("/library/stats", steamLibraryStatsHandler)
+		steamv1Group.GET("/library/history", steamLibraryHistoryHandler)
+		steamv1Group.POST("/compare", steamCompareHandler)
+		steamv1Group.GET("/prices", steamPricesListHandler)
+		steamv1Group.POST("/prices", steamPricesTrackHandler)
+		steamv1Group.DELETE("/prices/:appid", steamPricesUntrackHandler)
+		steamv1Group.GET("/prices/:appid/history", steamPriceHistoryHandler)
+		steamv1Group.GET("/admin/applist", steamAppListStatusHandler)
+		steamv1Group.POST("/admin/applist/refresh", steamAppListRefreshHandler)
+		steamv1Group.POST("/admin/library/snapshot", steamLibrarySnapshotHandler)
+		steamv1Group.POST("/admin/prices/poll", steamPricesPollHandler)
 	}
-	return func(c *gin.Context) {
-		origin := c.GetHeader("Origin")
-		if allowed[origin] {
-			c.Header("Access-Control-Allow-Origin", origin)
-			c.Header("Vary", "Origin")
-			c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-			c.Header("Access-Control-Allow-Headers", "Content-Type, Accept")
-			c.Header("Access-Control-Max-Age", "86400")
-		}
-		if c.Request.Method == http.MethodOptions {
-			c.AbortWithStatus(http.StatusNoContent)
-			return
-		}
-		c.Next()
+
+	r.GET("/joke", api.Use(api.Plain), jokeHandler)
+
+	tmdbGroup := r.Group("/tmdb/v1/", api.Use(api.Plain))
+	{
+		tmdbGroup.GET("/search", movieSearchHandler)
+		tmdbGroup.GET("/movie/:id", tmdbMovieHandler)
+		tmdbGroup.GET("/tv/:id", tmdbTVHandler)
+		tmdbGroup.GET("/person/:id", tmdbPersonHandler)
+		tmdbGroup.GET("/movie/:id/providers", tmdbProvidersHandler(tmdb.TypeMovie))
+		tmdbGroup.GET("/tv/:id/providers", tmdbProvidersHandler(tmdb.TypeTV))
+	}
+
+	netflixGroup := r.Group("/netflix/v1/", api.Use(api.Plain))
+	{
+		netflixGroup.GET("/top", netflixTopHandler)
+		netflixGroup.GET("/top.rss", netflixFeedHandler(netflixFeedRSS))
+		netflixGroup.GET("/top.atom", netflixFeedHandler(netflixFeedAtom))
+		netflixGroup.GET("/archive", netflixArchiveHandler)
+		netflixGroup.GET("/archive/history", netflixArchiveHistoryHandler)
+		netflixGroup.GET("/archive/titles", netflixArchiveTitlesHandler)
+		netflixGroup.GET("/archive/movement", netflixArchiveMovementHandler)
+		netflixGroup.POST("/admin/archive/collect", netflixArchiveCollectHandler)
+	}
+
+	tilecalcGroup := r.Group("/tilecalc/v1/", api.Use(api.Flagged))
+	{
+		tilecalcGroup.GET("/arrange", tilecalcArrangeHandler)
+		tilecalcGroup.GET("/coverage", tilecalcCoverageHandler)
 	}
+
+	dmtGroup := r.Group("/dmt/v1/", api.Use(api.Flagged))
+	{
+		dmtGroup.GET("/timestamp", dmtTimestampHandler)
+		dmtGroup.GET("/formats", dmtFormatsHandler)
+	}
+
+	r.GET("/version", versionHandler)
+}
+
+func versionHandler(c *gin.Context) {
+	api.OK(c, gin.H{
+		"version": appVersion,
+	})
 }
 
 func rootHandler(c *gin.Context, r *gin.Engine) {
 	routes := r.Routes()
 	var endpoints []string
 	for _, route := range routes {
+		if route.Method == http.MethodHead || strings.HasPrefix(route.Path, "/doc/") {
+			continue // the docs file server; advertised as /doc below
+		}
 		endpoints = append(endpoints, fmt.Sprintf("%s - %s", route.Method, route.Path))
 	}
 	endpoints = append(endpoints, fmt.Sprintf("%s - %s", "GET", "/doc"))
-	c.JSON(http.StatusOK, gin.H{
+	api.OK(c, gin.H{
 		"endpoints": endpoints,
 	})
 }
@@ -0,0 +1,150 @@
+// Package metrics defines the Prometheus collectors earapi exports at /metrics:
+// HTTP traffic, calls to upstream services, cache effectiveness and jobs.
+//
+// Packages record through the helpers here rather than declaring their own
+// collectors, so every series name lives in one place.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Upstream outcomes. Retry marks an attempt that failed but will be retried,
+// so retries show up as their own series rather than hiding inside latency.
+const (
+	OutcomeOK    = "ok"
+	OutcomeError = "error"
+	OutcomeRetry = "retry"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "earapi_http_requests_total",
+		Help: "HTTP requests served, by route and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "earapi_http_request_duration_seconds",
+		Help:    "HTTP request latency, by route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	upstreamRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "earapi_upstream_requests_total",
+		Help: "Calls to upstream services, one per attempt, by outcome.",
+	}, []string{"service", "operation", "outcome"})
+
+	upstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "earapi_upstream_request_duration_seconds",
+		Help:    "Upstream call latency per attempt.",
+		Buckets: prometheus.ExponentialBuckets(0.05, 2, 10), // 50ms … ~25s
+	}, []string{"service", "operation"})
+
+	cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "earapi_cache_lookups_total",
+		Help: "Cache lookups, by cache and result (hit or miss).",
+	}, []string{"cache", "result"})
+)
+
+// Handler serves the Prometheus exposition format.
+func Handler() gin.HandlerFunc { return gin.WrapH(promhttp.Handler()) }
+
+// Middleware records every request against its route template, so
+// /watchlist/v1/:id is one series rather than one per id.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		httpRequests.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Upstream starts timing one call to service. Call the returned func with the
+// outcome once the attempt has finished.
+func Upstream(service, operation string) func(outcome string) {
+	start := time.Now()
+	return func(outcome string) {
+		upstreamRequests.WithLabelValues(service, operation, outcome).Inc()
+		upstreamDuration.WithLabelValues(service, operation).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Outcome maps an error onto OutcomeOK or OutcomeError.
+func Outcome(err error) string {
+	if err != nil {
+		return OutcomeError
+	}
+	return OutcomeOK
+}
+
+// Transport wraps next so every request through it is recorded against
+// service, with the last path segment as the operation
+// (".../youtube/v3/playlistItems" → "playlistItems").
+func Transport(service string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripper{service: service, next: next}
+}
+
+type roundTripper struct {
+	service string
+	next    http.RoundTripper
+}
+
+func (t roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	done := Upstream(t.service, path[strings.LastIndex(path, "/")+1:])
+	resp, err := t.next.RoundTrip(req)
+	if err == nil && resp.StatusCode >= 400 {
+		done(OutcomeError)
+	} else {
+		done(Outcome(err))
+	}
+	return resp, err
+}
+
+// CacheLookup records a hit or miss against cache.
+func CacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheLookups.WithLabelValues(cache, result).Inc()
+}
+
+// RegisterJobs exports earapi_jobs{state} from counts, read at scrape time so
+// the gauge always agrees with the job registry.
+func RegisterJobs(counts func() map[string]int) {
+	prometheus.MustRegister(jobsCollector{counts: counts})
+}
+
+var jobsDesc = prometheus.NewDesc("earapi_jobs",
+	"Background jobs in the registry, by state.", []string{"state"}, nil)
+
+type jobsCollector struct {
+	counts func() map[string]int
+}
+
+func (jc jobsCollector) Describe(ch chan<- *prometheus.Desc) { ch <- jobsDesc }
+
+func (jc jobsCollector) Collect(ch chan<- prometheus.Metric) {
+	for state, n := range jc.counts() {
+		ch <- prometheus.MustNewConstMetric(jobsDesc, prometheus.GaugeValue, float64(n), state)
+	}
+}
@@ -0,0 +1,538 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/earentir/netflixtudumscrapper"
+	"github.com/gin-gonic/gin"
+
+	"earapi/api"
+	"earapi/atomicfile"
+	"earapi/jellyfin"
+)
+
+// netflixArchiveDir holds one file per chart and week:
+// <country>/<type>/<week>.json, with "global" for the worldwide charts.
+const netflixArchiveDir = "netflixdata/top10"
+
+// netflixGlobal is the archive's name for the worldwide charts, which Tudum
+// serves without a country.
+const netflixGlobal = "global"
+
+// netflixTopTypes are the charts archived for every country. The most
+// popular list only exists worldwide.
+var netflixTopTypes = []string{"films", "tv"}
+
+// netflixTopSnapshot is one chart for one week as Tudum showed it.
+type netflixTopSnapshot struct {
+	Week        string                      `json:"week"` // the Sunday the chart week ends on
+	Country     string                      `json:"country"`
+	Type        string                      `json:"type"`
+	CollectedAt time.Time                   `json:"collected_at"`
+	Items       []netflixtudumscrapper.Item `json:"items"`
+}
+
+// netflixLatestWeek is the newest chart week Tudum has published at now.
+// Weeks run Monday to Sunday and go up on Tuesday; the week is named by
+// its Sunday.
+func netflixLatestWeek(now time.Time) string {
+	now = now.UTC()
+	sunday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -int(now.Weekday()))
+	if now.Before(sunday.Add(2*24*time.Hour + 20*time.Hour)) {
+		sunday = sunday.AddDate(0, 0, -7)
+	}
+	return sunday.Format(time.DateOnly)
+}
+
+// netflixArchive collects the weekly charts behind /netflix/v1/archive.
+var netflixArchive = &netflixArchiver{}
+
+type netflixArchiver struct {
+	mu        sync.Mutex
+	countries []string
+	interval  time.Duration // 0 disables scheduled collection
+	lastCheck time.Time
+
+	collecting sync.Mutex // held for the whole of a collection
+}
+
+// apply swaps in the archived countries and the schedule from cfg.
+func (a *netflixArchiver) apply(cfg earapiSettings) {
+	hours := cfg.Netflix.ArchiveHours
+	if hours == 0 {
+		hours = 6
+	}
+	var countries []string
+	for _, raw := range cfg.Netflix.ArchiveCountries {
+		if cc, err := netflixCountry(raw); err == nil && !slices.Contains(countries, cc) {
+			countries = append(countries, cc)
+		}
+	}
+	a.mu.Lock()
+	a.countries = countries
+	a.interval = time.Duration(max(hours, 0)) * time.Hour
+	a.mu.Unlock()
+}
+
+func (a *netflixArchiver) due(now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.interval > 0 && len(a.countries) > 0 && now.Sub(a.lastCheck) >= a.interval
+}
+
+// run collects the latest week on schedule until ctx ends. Charts already
+// on disk are skipped, so most checks cost nothing; one that isn't up yet
+// is retried at the next check.
+func (a *netflixArchiver) run(ctx context.Context) {
+	t := time.NewTicker(time.Minute)
+	defer t.Stop()
+	for {
+		if a.due(time.Now()) {
+			a.mu.Lock()
+			a.lastCheck = time.Now()
+			a.mu.Unlock()
+			if r, err := a.tryCollect(ctx, netflixLatestWeek(time.Now()), nil, false); err == nil && (r.Saved > 0 || len(r.Errors) > 0) {
+				slog.Info("netflix top 10 archived", "week", r.Week, "saved", r.Saved, "errors", len(r.Errors))
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// netflixCollectResult reports one collection. Errors are keyed by
+// country/type.
+type netflixCollectResult struct {
+	Week    string            `json:"week"`
+	Saved   int               `json:"saved"`
+	Skipped int               `json:"skipped"` // already archived
+	Errors  map[string]string `json:"errors"`
+}
+
+var errCollectRunning = errors.New("a Netflix Top 10 collection is already running")
+
+// tryCollect archives week for countries, every configured one when nil,
+// re-scraping charts already on disk only with force.
+func (a *netflixArchiver) tryCollect(ctx context.Context, week string, countries []string, force bool) (netflixCollectResult, error) {
+	if !a.collecting.TryLock() {
+		return netflixCollectResult{}, errCollectRunning
+	}
+	defer a.collecting.Unlock()
+
+	if countries == nil {
+		a.mu.Lock()
+		countries = slices.Clone(a.countries)
+		a.mu.Unlock()
+	}
+
+	r := netflixCollectResult{Week: week, Errors: map[string]string{}}
+	collect := func(country, typ string) {
+		if _, err := os.Stat(netflixSnapshotFile(country, typ, week)); err == nil && !force {
+			r.Skipped++
+			return
+		}
+		if err := ctx.Err(); err != nil {
+			r.Errors[country+"/"+typ] = err.Error()
+			return
+		}
+		if err := collectNetflixTop(ctx, country, typ, week); err != nil {
+			slog.Warn("netflix top 10 archive failed", "country", country, "type", typ, "week", week, "err", err)
+			r.Errors[country+"/"+typ] = err.Error()
+			return
+		}
+		r.Saved++
+	}
+	for _, country := range countries {
+		for _, typ := range netflixTopTypes {
+			collect(country, typ)
+		}
+		if country == netflixGlobal {
+			collect(country, "popular")
+		}
+	}
+	return r, nil
+}
+
+// collectNetflixTop scrapes one chart week and writes it to the archive.
+func collectNetflixTop(ctx context.Context, country, typ, week string) error {
+	items, err := scrapeNetflixTop(ctx, netflixTopURL(typ, country, week))
+	if err != nil {
+		return err
+	}
+	snap := netflixTopSnapshot{Week: week, Country: country, Type: typ, CollectedAt: time.Now().UTC(), Items: items}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	file := netflixSnapshotFile(country, typ, week)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return atomicfile.Write(file, data, 0644)
+}
+
+func netflixSnapshotFile(country, typ, week string) string {
+	return filepath.Join(netflixArchiveDir, country, typ, week+".json")
+}
+
+// netflixArchivedWeeks lists the weeks archived for a chart, oldest first.
+func netflixArchivedWeeks(country, typ string) []string {
+	files, _ := filepath.Glob(filepath.Join(netflixArchiveDir, country, typ, "*.json"))
+	weeks := make([]string, 0, len(files))
+	for _, f := range files {
+		weeks = append(weeks, strings.TrimSuffix(filepath.Base(f), ".json"))
+	}
+	slices.Sort(weeks)
+	return weeks
+}
+
+func readNetflixSnapshot(country, typ, week string) (*netflixTopSnapshot, error) {
+	data, err := os.ReadFile(netflixSnapshotFile(country, typ, week))
+	if err != nil {
+		return nil, err
+	}
+	s := &netflixTopSnapshot{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("%s: %w", netflixSnapshotFile(country, typ, week), err)
+	}
+	return s, nil
+}
+
+// netflixChartParams reads the country and type of an archived chart.
+// Country defaults to the worldwide chart, type to films; popular is only
+// archived worldwide.
+func netflixChartParams(c *gin.Context) (country, typ string, err error) {
+	if country, err = netflixCountry(c.Query("country")); err != nil {
+		return "", "", err
+	}
+	if typ, err = netflixChartType(c.Query("type")); err != nil {
+		return "", "", err
+	}
+	if typ == "popular" {
+		country = netflixGlobal
+	}
+	return country, typ, nil
+}
+
+// netflixWeeklyChart rejects the most popular list where a weekly history is
+// asked for: it is archived each week, but it ranks all time.
+func netflixWeeklyChart(typ string) error {
+	if typ != "popular" {
+		return nil
+	}
+	return api.New(api.KindInvalidInput, "the most popular list is all-time, so it has no weekly history",
+		"Use type=films or type=tv, or compare two of its snapshots with /netflix/v1/archive/movement.")
+}
+
+// netflixTitleKey matches a title across weeks, where Tudum's spelling of
+// punctuation and accents drifts. Titles in other scripts keep their letters,
+// and one that is all punctuation is its own key, so no title goes unkeyed.
+func netflixTitleKey(title string) string {
+	if key := jellyfin.NormalizeTitle(title); key != "" {
+		return key
+	}
+	return strings.ToLower(strings.TrimSpace(title))
+}
+
+// netflixTitleWeeks is a title's run in one chart.
+type netflixTitleWeeks struct {
+	Title     string          `json:"title"`
+	Type      string          `json:"type,omitempty"`
+	Weeks     int             `json:"weeks_in_top10"`
+	BestRank  int             `json:"best_rank"`
+	FirstWeek string          `json:"first_week"`
+	LastWeek  string          `json:"last_week"`
+	Ranks     []netflixWeekly `json:"ranks,omitempty"`
+}
+
+type netflixWeekly struct {
+	Week string `json:"week"`
+	Rank int    `json:"rank"`
+}
+
+// netflixChartRuns gathers every title in an archived weekly chart, oldest
+// week first, keyed by netflixTitleKey. Two entries of one week that share a
+// key count as one week, at the better rank.
+func netflixChartRuns(country, typ string) (map[string]*netflixTitleWeeks, int, error) {
+	runs := map[string]*netflixTitleWeeks{}
+	weeks := netflixArchivedWeeks(country, typ)
+	for _, week := range weeks {
+		snap, err := readNetflixSnapshot(country, typ, week)
+		if err != nil {
+			return nil, 0, err
+		}
+		seen := map[string]bool{}
+		for _, it := range snap.Items {
+			key := netflixTitleKey(it.Title)
+			if seen[key] {
+				continue // items are in rank order, so the first was the better
+			}
+			seen[key] = true
+			r := runs[key]
+			if r == nil {
+				r = &netflixTitleWeeks{Type: typ, FirstWeek: week, BestRank: it.Rank}
+				runs[key] = r
+			}
+			r.Title = it.Title // latest spelling
+			r.Weeks++
+			r.BestRank = min(r.BestRank, it.Rank)
+			r.LastWeek = week
+			r.Ranks = append(r.Ranks, netflixWeekly{Week: week, Rank: it.Rank})
+		}
+	}
+	return runs, len(weeks), nil
+}
+
+// netflixArchiveHandler lists what the archive holds.
+func netflixArchiveHandler(c *gin.Context) {
+	type chart struct {
+		Country   string `json:"country"`
+		Type      string `json:"type"`
+		Weeks     int    `json:"weeks"`
+		FirstWeek string `json:"first_week"`
+		LastWeek  string `json:"last_week"`
+	}
+	charts := []chart{}
+	countries, _ := os.ReadDir(netflixArchiveDir)
+	for _, cd := range countries {
+		types, _ := os.ReadDir(filepath.Join(netflixArchiveDir, cd.Name()))
+		for _, td := range types {
+			weeks := netflixArchivedWeeks(cd.Name(), td.Name())
+			if len(weeks) == 0 {
+				continue
+			}
+			charts = append(charts, chart{Country: cd.Name(), Type: td.Name(), Weeks: len(weeks), FirstWeek: weeks[0], LastWeek: weeks[len(weeks)-1]})
+		}
+	}
+	netflixArchive.mu.Lock()
+	configured := append([]string{}, netflixArchive.countries...)
+	netflixArchive.mu.Unlock()
+	api.OK(c, gin.H{
+		"countries":   configured,
+		"latest_week": netflixLatestWeek(time.Now()),
+		"charts":      charts,
+	})
+}
+
+// netflixArchiveHistoryHandler is one title's rank, week by week, in every
+// archived chart of a country, or just the one asked for.
+func netflixArchiveHistoryHandler(c *gin.Context) {
+	title := strings.TrimSpace(c.Query("title"))
+	if title == "" {
+		api.Fail(c, api.New(api.KindInvalidInput, "title is required", "Pass title=<as shown in the Top 10>."))
+		return
+	}
+	country, typ, err := netflixChartParams(c)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	if err := netflixWeeklyChart(typ); err != nil {
+		api.Fail(c, err)
+		return
+	}
+	types := []string{typ}
+	if c.Query("type") == "" {
+		types = netflixTopTypes
+	}
+
+	found := []netflixTitleWeeks{}
+	for _, t := range types {
+		runs, _, err := netflixChartRuns(country, t)
+		if err != nil {
+			api.Fail(c, err)
+			return
+		}
+		if r := runs[netflixTitleKey(title)]; r != nil {
+			found = append(found, *r)
+		}
+	}
+	if len(found) == 0 {
+		api.Fail(c, api.New(api.KindNotFound, fmt.Sprintf("%q is not in the archived %s charts", title, country),
+			"The archive only holds weeks collected since netflix.archive_countries was set."))
+		return
+	}
+	api.OK(c, gin.H{"title": title, "country": country, "charts": found})
+}
+
+// netflixArchiveTitlesHandler ranks the titles of a chart by weeks in the
+// Top 10.
+func netflixArchiveTitlesHandler(c *gin.Context) {
+	country, typ, err := netflixChartParams(c)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	if err := netflixWeeklyChart(typ); err != nil {
+		api.Fail(c, err)
+		return
+	}
+	limit, err := queryInt(c, "limit", 50, 1, 1000)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	runs, weeks, err := netflixChartRuns(country, typ)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	titles := make([]netflixTitleWeeks, 0, len(runs))
+	for _, r := range runs {
+		r.Ranks, r.Type = nil, ""
+		titles = append(titles, *r)
+	}
+	slices.SortFunc(titles, func(a, b netflixTitleWeeks) int {
+		return cmp.Or(cmp.Compare(b.Weeks, a.Weeks), cmp.Compare(a.BestRank, b.BestRank), strings.Compare(b.LastWeek, a.LastWeek), strings.Compare(a.Title, b.Title))
+	})
+	total := len(titles)
+	if len(titles) > limit {
+		titles = titles[:limit]
+	}
+	api.OK(c, gin.H{"country": country, "type": typ, "weeks": weeks, "total": total, "titles": titles})
+}
+
+// netflixMove is a title's change between two weeks. Change is positive for
+// a climb.
+type netflixMove struct {
+	Title        string `json:"title"`
+	Rank         int    `json:"rank,omitempty"`
+	PreviousRank int    `json:"previous_rank,omitempty"`
+	Change       int    `json:"change,omitempty"`
+}
+
+// netflixArchiveMovementHandler compares two archived weeks of a chart: by
+// default the latest against the one before it.
+func netflixArchiveMovementHandler(c *gin.Context) {
+	country, typ, err := netflixChartParams(c)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	from, err := netflixWeek("from", c.Query("from"))
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	to, err := netflixWeek("to", c.Query("to"))
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	weeks := netflixArchivedWeeks(country, typ)
+	if to == "" && len(weeks) > 0 {
+		to = weeks[len(weeks)-1]
+	}
+	if from == "" {
+		if i, _ := slices.BinarySearch(weeks, to); i > 0 {
+			from = weeks[i-1]
+		}
+	}
+	if from == "" || to == "" {
+		api.Fail(c, api.New(api.KindNotFound, fmt.Sprintf("the %s %s chart needs two archived weeks to compare", country, typ),
+			"The collector adds a week every Tuesday; POST /netflix/v1/admin/archive/collect?week= backfills one."))
+		return
+	}
+	if from >= to {
+		api.Fail(c, api.New(api.KindInvalidInput, "from must be an earlier week than to", ""))
+		return
+	}
+	prev, err := readNetflixSnapshot(country, typ, from)
+	if err == nil {
+		var cur *netflixTopSnapshot
+		if cur, err = readNetflixSnapshot(country, typ, to); err == nil {
+			api.OK(c, netflixMovement(prev, cur))
+			return
+		}
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		api.Fail(c, api.New(api.KindNotFound, fmt.Sprintf("week %s or %s is not archived for the %s %s chart", from, to, country, typ),
+			"GET /netflix/v1/archive lists the archived weeks."))
+		return
+	}
+	api.Fail(c, err)
+}
+
+// netflixMovement sorts cur's titles into new entries, titles that dropped
+// out since prev, and movers, biggest change first.
+func netflixMovement(prev, cur *netflixTopSnapshot) gin.H {
+	before := map[string]netflixtudumscrapper.Item{}
+	for _, it := range prev.Items {
+		before[netflixTitleKey(it.Title)] = it
+	}
+	entries, movers, dropped := []netflixMove{}, []netflixMove{}, []netflixMove{}
+	seen := map[string]bool{}
+	for _, it := range cur.Items {
+		key := netflixTitleKey(it.Title)
+		seen[key] = true
+		p, ok := before[key]
+		switch {
+		case !ok:
+			entries = append(entries, netflixMove{Title: it.Title, Rank: it.Rank})
+		case p.Rank != it.Rank:
+			movers = append(movers, netflixMove{Title: it.Title, Rank: it.Rank, PreviousRank: p.Rank, Change: p.Rank - it.Rank})
+		}
+	}
+	for _, it := range prev.Items {
+		if !seen[netflixTitleKey(it.Title)] {
+			dropped = append(dropped, netflixMove{Title: it.Title, PreviousRank: it.Rank})
+		}
+	}
+	abs := func(n int) int { return max(n, -n) }
+	slices.SortStableFunc(movers, func(a, b netflixMove) int { return cmp.Compare(abs(b.Change), abs(a.Change)) })
+	return gin.H{
+		"country": cur.Country,
+		"type":    cur.Type,
+		"from":    prev.Week,
+		"to":      cur.Week,
+		"new":     entries,
+		"dropped": dropped,
+		"movers":  movers,
+	}
+}
+
+// netflixArchiveCollectHandler collects a week now: the latest by default,
+// or an earlier one to backfill. country collects one country, configured
+// or not, instead of netflix.archive_countries.
+func netflixArchiveCollectHandler(c *gin.Context) {
+	week, err := netflixWeek("week", c.Query("week"))
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	if week == "" {
+		week = netflixLatestWeek(time.Now())
+	}
+	var countries []string
+	if raw := c.Query("country"); raw != "" {
+		cc, err := netflixCountry(raw)
+		if err != nil {
+			api.Fail(c, err)
+			return
+		}
+		countries = []string{cc}
+	}
+	r, err := netflixArchive.tryCollect(c.Request.Context(), week, countries, queryBool(c, "force"))
+	switch {
+	case errors.Is(err, errCollectRunning):
+		api.Fail(c, api.New(api.KindConflict, err.Error(), "Try again once it finishes."))
+	case err != nil:
+		api.Fail(c, err)
+	default:
+		api.OK(c, r)
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/earentir/netflixtudumscrapper"
+
+	"earapi/api"
+	"earapi/imdb"
+	"earapi/jellyfin"
+	"earapi/metrics"
+	wlpackage "earapi/watchlist"
+)
+
+const (
+	netflixIMDbWorkers = 4
+	netflixIMDbBudget  = 15 * time.Second // for all of a request's lookups
+
+	// A title matched stays matched for a month; one IMDb had nothing for
+	// is looked up again the next day. Ratings move, so they're refetched
+	// after a few hours.
+	netflixIMDbMatchTTL   = 30 * 24 * time.Hour
+	netflixIMDbNoMatchTTL = 24 * time.Hour
+	netflixIMDbRatingTTL  = 6 * time.Hour
+)
+
+// netflixTopRow is a Top 10 entry as /netflix/v1/top answers it when
+// asked to cross-reference IMDb or a watchlist.
+type netflixTopRow struct {
+	netflixtudumscrapper.Item
+	IMDb        *netflixIMDbTitle `json:"imdb,omitempty"`         // nil when no IMDb title matched
+	OnWatchlist *bool             `json:"on_watchlist,omitempty"` // set when a watchlist_id was given
+}
+
+// netflixIMDbTitle is what IMDb adds to a Top 10 entry.
+type netflixIMDbTitle struct {
+	IMDbID string   `json:"imdb_id"`
+	Title  string   `json:"title"`
+	Year   int      `json:"year,omitempty"`
+	Type   string   `json:"type,omitempty"`
+	Rating float64  `json:"rating,omitempty"`
+	Votes  int      `json:"votes,omitempty"`
+	Genres []string `json:"genres,omitempty"`
+	URL    string   `json:"url"`
+}
+
+// netflixIMDb matches Top 10 entries to IMDb titles for /netflix/v1/top.
+var netflixIMDb = &netflixIMDbMatcher{
+	client:  imdb.NewClient(),
+	matches: map[string]netflixIMDbMatch{},
+	titles:  map[string]netflixIMDbRating{},
+}
+
+// netflixIMDbMatcher remembers which IMDb title each chart entry is, and
+// each title's rating for a while, in memory. Stored watchlists come from
+// the watchlist service once it's up.
+type netflixIMDbMatcher struct {
+	mu      sync.Mutex
+	client  *imdb.Client
+	lists   *wlpackage.Store // nil until the watchlist service is up
+	matches map[string]netflixIMDbMatch
+	titles  map[string]netflixIMDbRating
+}
+
+type netflixIMDbMatch struct {
+	id        string // "" when IMDb had nothing that fits
+	checkedAt time.Time
+}
+
+type netflixIMDbRating struct {
+	title     imdb.Title
+	fetchedAt time.Time
+}
+
+// setWatchlist shares the watchlist service's IMDb client, so both stay
+// under one rate limit, and its stored lists.
+func (m *netflixIMDbMatcher) setWatchlist(svc *wlpackage.Service) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if svc.IMDb != nil {
+		m.client = svc.IMDb
+	}
+	m.lists = svc.Store
+}
+
+// watchlist returns the stored watchlist id.
+func (m *netflixIMDbMatcher) watchlist(id string) (*imdb.Watchlist, error) {
+	m.mu.Lock()
+	lists := m.lists
+	m.mu.Unlock()
+	if lists == nil {
+		return nil, api.New(api.KindUnavailable, "watchlists are unavailable", "The watchlist store failed to start; see /health.")
+	}
+	wl, ok, err := lists.Watchlist(id)
+	if err != nil {
+		return nil, api.New(api.KindInternal, "could not read the watchlist store: "+err.Error(), "")
+	}
+	if !ok {
+		return nil, api.New(api.KindNotFound, "that watchlist is no longer stored",
+			"Fetch it again through /watchlist/v1 and pass the id it returns.")
+	}
+	return wl, nil
+}
+
+// enrich matches items on chart typ (films, tv or popular) to IMDb titles.
+// year is the chart's, 0 for the all-time list; nothing released after the
+// following year can be on it. Entries that can't be matched, or not in
+// time, have no imdb; only an IMDb that answered no lookup at all is an
+// error.
+func (m *netflixIMDbMatcher) enrich(ctx context.Context, entries []netflixTopRow, typ string, year int) error {
+	lookupCtx, cancel := context.WithTimeout(ctx, netflixIMDbBudget)
+	defer cancel()
+
+	ids := make([]string, len(entries))
+	var (
+		next     = make(chan int)
+		mu       sync.Mutex
+		firstErr error
+		answered bool
+		wg       sync.WaitGroup
+	)
+	for range netflixIMDbWorkers {
+		wg.Go(func() {
+			for i := range next {
+				id, err := m.match(lookupCtx, entries[i].Title, typ, year)
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				answered = answered || err == nil
+				mu.Unlock()
+				ids[i] = id
+			}
+		})
+	}
+	for i := range entries {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	if !answered && firstErr != nil {
+		return netflixIMDbErr(firstErr)
+	}
+
+	titles, err := m.ratings(ctx, ids)
+	if err != nil {
+		return netflixIMDbErr(err)
+	}
+	for i, id := range ids {
+		if t, ok := titles[id]; ok {
+			entries[i].IMDb = &netflixIMDbTitle{
+				IMDbID: t.IMDbID, Title: t.Title, Year: t.Year, Type: t.Type,
+				Rating: t.Rating, Votes: t.Votes, Genres: t.Genres, URL: imdb.TitleURL(t.IMDbID),
+			}
+		}
+	}
+	return nil
+}
+
+// match returns the IMDb id for a chart entry, "" when nothing fits.
+func (m *netflixIMDbMatcher) match(ctx context.Context, title, typ string, year int) (string, error) {
+	norm := jellyfin.NormalizeTitle(title)
+	if norm == "" {
+		return "", nil
+	}
+	key := typ + "|" + norm
+	m.mu.Lock()
+	e, ok := m.matches[key]
+	client := m.client
+	m.mu.Unlock()
+	ttl := netflixIMDbNoMatchTTL
+	if e.id != "" {
+		ttl = netflixIMDbMatchTTL
+	}
+	metrics.CacheLookup("netflix_imdb", ok && time.Since(e.checkedAt) < ttl)
+	if ok && time.Since(e.checkedAt) < ttl {
+		return e.id, nil
+	}
+
+	// "Squid Game: Season 3" is listed on IMDb as "Squid Game", but
+	// "Mission: Impossible - Dead Reckoning" is listed whole, so the full
+	// title goes first.
+	queries := []string{title}
+	if short := tudumSubtitle.ReplaceAllString(title, ""); short != "" && short != title {
+		queries = append(queries, short)
+	}
+	id := ""
+	for _, q := range queries {
+		found, err := client.Suggest(ctx, q)
+		if err != nil {
+			return "", err
+		}
+		if id = bestSuggestion(found, q, typ, year); id != "" {
+			break
+		}
+	}
+	m.mu.Lock()
+	m.matches[key] = netflixIMDbMatch{id: id, checkedAt: time.Now()}
+	m.mu.Unlock()
+	return id, nil
+}
+
+// bestSuggestion picks IMDb's most popular suggestion whose title is query,
+// as jellyfin.NormalizeTitle compares them, of a kind that can chart as
+// typ and not released after year+1.
+func bestSuggestion(found []imdb.Suggestion, query, typ string, year int) string {
+	want := jellyfin.NormalizeTitle(query)
+	for _, s := range found {
+		if jellyfin.NormalizeTitle(s.Title) != want || !netflixChartable(s.Type, typ) {
+			continue
+		}
+		if year > 0 && s.Year > year+1 {
+			continue
+		}
+		return s.IMDbID
+	}
+	return ""
+}
+
+// netflixChartable reports whether an IMDb title type belongs on chart typ.
+// The all-time list mixes both.
+func netflixChartable(imdbType, typ string) bool {
+	switch imdbType {
+	case "movie", "tvMovie", "tvSpecial", "video":
+		return typ != "tv"
+	case "tvSeries", "tvMiniSeries":
+		return typ != "films"
+	}
+	return false
+}
+
+// ratings returns the titles for ids, fetching the ones not seen lately in
+// one go. Empty ids are skipped.
+func (m *netflixIMDbMatcher) ratings(ctx context.Context, ids []string) (map[string]imdb.Title, error) {
+	out := map[string]imdb.Title{}
+	var stale []string
+	m.mu.Lock()
+	client := m.client
+	for _, id := range ids {
+		if id == "" {
+			continue
+		}
+		if r, ok := m.titles[id]; ok && time.Since(r.fetchedAt) < netflixIMDbRatingTTL {
+			out[id] = r.title
+		} else if !slices.Contains(stale, id) {
+			stale = append(stale, id)
+		}
+	}
+	m.mu.Unlock()
+	if len(stale) == 0 {
+		return out, nil
+	}
+
+	fetched, err := client.FetchTitles(ctx, stale, nil)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range fetched {
+		m.titles[t.IMDbID] = netflixIMDbRating{title: t, fetchedAt: time.Now()}
+		out[t.IMDbID] = t
+	}
+	// Matches outlive ratings; drop what's too old to serve either way.
+	for id, r := range m.titles {
+		if time.Since(r.fetchedAt) >= netflixIMDbRatingTTL {
+			delete(m.titles, id)
+		}
+	}
+	for key, e := range m.matches {
+		if time.Since(e.checkedAt) >= netflixIMDbMatchTTL {
+			delete(m.matches, key)
+		}
+	}
+	return out, nil
+}
+
+// netflixIMDbErr reports an IMDb failure as the upstream's, whatever its
+// kind: the caller's input was the chart, not anything IMDb saw.
+func netflixIMDbErr(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+	return api.New(api.KindUpstream, "could not match the Top 10 on IMDb: "+err.Error(),
+		"IMDb may be rate limiting or down; try again later, or drop enrich=imdb.")
+}
+
+// flagWatchlist sets OnWatchlist on every entry: by IMDb id when the entry
+// was matched, otherwise by title, with or without its subtitle.
+func flagWatchlist(entries []netflixTopRow, wl *imdb.Watchlist) {
+	ids := map[string]bool{}
+	titles := map[string]bool{}
+	for _, t := range wl.Titles {
+		ids[t.IMDbID] = true
+		titles[jellyfin.NormalizeTitle(t.Title)] = true
+		if t.OrigTitle != "" {
+			titles[jellyfin.NormalizeTitle(t.OrigTitle)] = true
+		}
+	}
+	for i := range entries {
+		e := &entries[i]
+		on := false
+		if e.IMDb != nil {
+			on = ids[e.IMDb.IMDbID]
+		} else {
+			on = titles[jellyfin.NormalizeTitle(e.Title)] ||
+				titles[jellyfin.NormalizeTitle(tudumSubtitle.ReplaceAllString(e.Title, ""))]
+		}
+		e.OnWatchlist = &on
+	}
+}
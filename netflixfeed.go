@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/earentir/netflixtudumscrapper"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+
+	"earapi/api"
+)
+
+// Feed formats served by netflixFeedHandler.
+const (
+	netflixFeedRSS  = "rss"
+	netflixFeedAtom = "atom"
+)
+
+// netflixFeedItem is one Top 10 entry as a feed item.
+type netflixFeedItem struct {
+	ID      string // stable per chart, week and title
+	Title   string
+	Link    string
+	Summary string
+	Poster  string
+}
+
+// netflixFeed is a chart week ready to render as RSS or Atom.
+type netflixFeed struct {
+	ID        string
+	Title     string
+	Self      string // this feed's URL
+	Link      string // the chart on Tudum
+	Published time.Time
+	Items     []netflixFeedItem
+}
+
+// netflixFeedHandler serves /netflix/v1/top.rss and /top.atom: the chart
+// /netflix/v1/top would answer, one item per entry. Item ids name the
+// chart week, so a new week shows up as new items in a reader. Last-Modified
+// is the week's publication and ETag the body's hash, so an unchanged feed
+// is a 304.
+func netflixFeedHandler(format string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		typ, country, week, err := netflixTopParams(c)
+		if err != nil {
+			api.Fail(c, err)
+			return
+		}
+		movies, err := netflixChart(c, typ, country, week)
+		if err != nil {
+			api.Fail(c, err)
+			return
+		}
+		feed := buildNetflixFeed(movies, typ, country, netflixFeedWeek(week), feedSelf(c))
+
+		var body []byte
+		contentType := "application/rss+xml; charset=utf-8"
+		if format == netflixFeedAtom {
+			contentType = "application/atom+xml; charset=utf-8"
+			body, err = feed.atom()
+		} else {
+			body, err = feed.rss()
+		}
+		if err != nil {
+			api.Fail(c, api.Errorf(api.KindInternal, "could not render the feed: %v", err))
+			return
+		}
+		sum := sha256.Sum256(body)
+		c.Header("Content-Type", contentType)
+		c.Header("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+		http.ServeContent(c.Writer, c.Request, "", feed.Published, bytes.NewReader(body))
+	}
+}
+
+// netflixFeedWeek is the week a chart request is for: the one asked for, or
+// the latest published. The all-time list is named by the latest week too.
+func netflixFeedWeek(week string) string {
+	if week != "" {
+		return week
+	}
+	return netflixLatestWeek(time.Now())
+}
+
+// feedSelf is the URL the feed was requested at, for its self link.
+func feedSelf(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + c.Request.URL.RequestURI()
+}
+
+// netflixPublished is when Tudum publishes the chart for the week ending on
+// week: the Tuesday after, at 20:00 UTC.
+func netflixPublished(week string) time.Time {
+	sunday, err := time.Parse(time.DateOnly, week)
+	if err != nil {
+		return time.Time{}
+	}
+	return sunday.Add(2*24*time.Hour + 20*time.Hour)
+}
+
+// netflixChartName names a chart for people: "Netflix Top 10 TV, United
+// States".
+func netflixChartName(typ, country string) string {
+	if typ == "popular" {
+		return "Netflix Most Popular TV"
+	}
+	kind := "Films"
+	if typ == "tv" {
+		kind = "TV"
+	}
+	where := "Global"
+	if country != netflixGlobal {
+		where = cases.Title(language.English).String(strings.ReplaceAll(country, "-", " "))
+	}
+	return fmt.Sprintf("Netflix Top 10 %s, %s", kind, where)
+}
+
+func buildNetflixFeed(items []netflixtudumscrapper.Item, typ, country, week, self string) netflixFeed {
+	chart := fmt.Sprintf("urn:earapi:netflix:top10:%s:%s", country, typ)
+	name := netflixChartName(typ, country)
+	feed := netflixFeed{
+		ID:        chart,
+		Title:     name,
+		Self:      self,
+		Link:      netflixTopURL(typ, country, ""),
+		Published: netflixPublished(week),
+	}
+	for _, it := range items {
+		key := netflixFeedKey(it.Title)
+		link := it.DetailURL
+		if link == "" {
+			link = it.PlayURL
+		}
+		summary := fmt.Sprintf("#%d on the %s for the week ending %s.", it.Rank, name, week)
+		if typ == "popular" {
+			summary = fmt.Sprintf("#%d on Netflix's most popular TV of all time, as of the week ending %s.", it.Rank, week)
+		}
+		feed.Items = append(feed.Items, netflixFeedItem{
+			ID:      fmt.Sprintf("%s:%s:%s", chart, week, key),
+			Title:   fmt.Sprintf("#%d %s", it.Rank, it.Title),
+			Link:    link,
+			Summary: summary,
+			Poster:  it.Poster,
+		})
+	}
+	return feed
+}
+
+// netflixFeedKey names a title in item ids: its Tudum slug, or, for a title
+// with no Latin letters to slug, a hash of the title as the archive matches
+// it. Either way it follows the title, not its rank.
+func netflixFeedKey(title string) string {
+	if slug := slugifyForTudum(title); slug != "" {
+		return slug
+	}
+	sum := sha256.Sum256([]byte(netflixTitleKey(title)))
+	return "title-" + hex.EncodeToString(sum[:8])
+}
+
+// posterType guesses a poster's MIME type from its URL; Tudum's are JPEGs.
+func posterType(raw string) string {
+	if u, err := url.Parse(raw); err == nil {
+		if t := mime.TypeByExtension(path.Ext(u.Path)); strings.HasPrefix(t, "image/") {
+			return t
+		}
+	}
+	return "image/jpeg"
+}
+
+type rssDoc struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Self        atomLink  `xml:"atom:link"`
+	Description string    `xml:"description"`
+	PubDate     string    `xml:"pubDate"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link,omitempty"`
+	Description string        `xml:"description"`
+	GUID        rssGUID       `xml:"guid"`
+	PubDate     string        `xml:"pubDate"`
+	Enclosure   *rssEnclosure `xml:"enclosure"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int    `xml:"length,attr"` // unknown without fetching it; 0 by convention
+	Type   string `xml:"type,attr"`
+}
+
+func (f netflixFeed) rss() ([]byte, error) {
+	pub := f.Published.Format(time.RFC1123Z)
+	doc := rssDoc{Version: "2.0", Atom: "http://www.w3.org/2005/Atom", Channel: rssChannel{
+		Title:       f.Title,
+		Link:        f.Link,
+		Self:        atomLink{Href: f.Self, Rel: "self", Type: "application/rss+xml"},
+		Description: f.Title + ", weekly from Netflix Tudum.",
+		PubDate:     pub,
+	}}
+	for _, it := range f.Items {
+		item := rssItem{
+			Title:       it.Title,
+			Link:        it.Link,
+			Description: it.Summary,
+			GUID:        rssGUID{Value: it.ID},
+			PubDate:     pub,
+		}
+		if it.Poster != "" {
+			item.Enclosure = &rssEnclosure{URL: it.Poster, Type: posterType(it.Poster)}
+		}
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+	return marshalFeed(doc)
+}
+
+type atomDoc struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID      string     `xml:"id"`
+	Title   string     `xml:"title"`
+	Updated string     `xml:"updated"`
+	Summary string     `xml:"summary"`
+	Links   []atomLink `xml:"link"`
+}
+
+func (f netflixFeed) atom() ([]byte, error) {
+	updated := f.Published.Format(time.RFC3339)
+	doc := atomDoc{
+		ID:      f.ID,
+		Title:   f.Title,
+		Updated: updated,
+		Author:  atomAuthor{Name: "Netflix Tudum"},
+		Links: []atomLink{
+			{Href: f.Self, Rel: "self", Type: "application/atom+xml"},
+			{Href: f.Link, Rel: "alternate", Type: "text/html"},
+		},
+	}
+	for _, it := range f.Items {
+		e := atomEntry{ID: it.ID, Title: it.Title, Updated: updated, Summary: it.Summary}
+		if it.Link != "" {
+			e.Links = append(e.Links, atomLink{Href: it.Link, Rel: "alternate", Type: "text/html"})
+		}
+		if it.Poster != "" {
+			e.Links = append(e.Links, atomLink{Href: it.Poster, Rel: "enclosure", Type: posterType(it.Poster)})
+		}
+		doc.Entries = append(doc.Entries, e)
+	}
+	return marshalFeed(doc)
+}
+
+func marshalFeed(doc any) ([]byte, error) {
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(body, '\n')...), nil
+}
@@ -1,82 +1,128 @@
 package main
 
 import (
+	"cmp"
 	"fmt"
-	"net/http"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/earentir/netflixtudumscrapper"
 	"github.com/gin-gonic/gin"
+
+	"earapi/api"
+	"earapi/imdb"
 )
 
 func netflixTopHandler(c *gin.Context) {
-	var url string
-	mediaType := c.DefaultQuery("type", "films")
-	country := c.Query("country")
-	week := c.Query("week")
-
-	switch mediaType {
-	case "films", "movies":
-		if country == "" {
-			url = "https://www.netflix.com/tudum/top10"
-		} else {
-			url = fmt.Sprintf("https://www.netflix.com/tudum/top10/%s", country)
+	typ, country, week, err := netflixTopParams(c)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	enrich := strings.ToLower(strings.TrimSpace(c.Query("enrich")))
+	if enrich != "" && enrich != "imdb" {
+		api.Fail(c, api.New(api.KindInvalidInput, fmt.Sprintf("enrich %q is not supported", c.Query("enrich")), "Use enrich=imdb."))
+		return
+	}
+	var wl *imdb.Watchlist
+	if id := strings.TrimSpace(c.Query("watchlist_id")); id != "" {
+		if wl, err = netflixIMDb.watchlist(id); err != nil {
+			api.Fail(c, err)
+			return
 		}
+	}
+
+	movies, err := netflixChart(c, typ, country, week)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
 
-	case "series", "tv":
-		if country == "" {
-			url = "https://www.netflix.com/tudum/top10/tv"
-		} else {
-			url = fmt.Sprintf("https://www.netflix.com/tudum/top10/%s/tv", country)
+	if enrich == "" && wl == nil {
+		api.OK(c, movies)
+		return
+	}
+	entries := make([]netflixTopRow, len(movies))
+	for i, m := range movies {
+		entries[i].Item = m
+	}
+	if enrich == "imdb" {
+		year := 0 // the all-time list
+		if typ != "popular" {
+			year = netflixChartYear(week)
+		}
+		if err := netflixIMDb.enrich(c.Request.Context(), entries, typ, year); err != nil {
+			api.Fail(c, err)
+			return
 		}
+	}
+	if wl != nil {
+		flagWatchlist(entries, wl)
+	}
+	api.OK(c, entries)
+}
 
-	case "pop", "popular":
-		url = "https://www.netflix.com/tudum/top10/most-popular/tv"
+// netflixTopParams reads the chart /netflix/v1/top and its feeds serve:
+// type, country and week, checked against each other.
+func netflixTopParams(c *gin.Context) (typ, country, week string, err error) {
+	if typ, err = netflixChartType(c.Query("type")); err != nil {
+		return "", "", "", err
+	}
+	if country, err = netflixCountry(c.Query("country")); err != nil {
+		return "", "", "", err
 	}
+	if week, err = netflixWeek("week", c.Query("week")); err != nil {
+		return "", "", "", err
+	}
+	if typ == "popular" && (country != netflixGlobal || week != "") {
+		return "", "", "", api.New(api.KindInvalidInput, "the most popular list is global and all-time",
+			"Drop country and week, or ask for type=films or tv.")
+	}
+	return typ, country, week, nil
+}
 
-	if week != "" {
-		url = fmt.Sprintf("%s?week=%s", url, week)
+// netflixChart returns a chart through the cache, reporting how in X-Cache.
+func netflixChart(c *gin.Context, typ, country, week string) ([]netflixtudumscrapper.Item, error) {
+	movies, cache, err := netflixTop.get(c.Request.Context(), netflixTopURL(typ, country, week))
+	if err != nil {
+		return nil, err
 	}
+	c.Header("X-Cache", cache)
 
-	fmt.Println(url)
+	// Best-effort: fill missing detailUrl by guessing the Tudum slug from the
+	// title. Whatever can't be resolved in time is left empty.
+	netflixSlugs.fill(c.Request.Context(), movies, netflixChartYear(week))
+	return movies, nil
+}
 
-	movies, err := netflixtudumscrapper.ScrapeNetflix(url)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+// netflixChartYear is the year of a chart week, "" being the latest.
+func netflixChartYear(week string) int {
+	if t, err := time.Parse(time.DateOnly, cmp.Or(week, netflixLatestWeek(time.Now()))); err == nil {
+		return t.Year()
 	}
+	return time.Now().Year()
+}
 
-	// Best-effort: fill missing detailUrl by guessing Tudum slug from title.
-	// Only attempts a lightweight HEAD request; if it fails, leaves value empty.
-	client := &http.Client{Timeout: 3 * time.Second}
-	for i := range movies {
-		// Access exported fields if available; ignore if not present in struct.
-		// We rely on the netflixtudumscrapper types exposing Title and DetailUrl.
-		title := movies[i].Title
-		if title == "" {
-			continue
-		}
-		if movies[i].DetailURL != "" {
-			continue
-		}
-		slug := slugifyForTudum(title)
-		if slug == "" {
-			continue
-		}
-		candidate := fmt.Sprintf("https://www.netflix.com/tudum/%s", slug)
-		req, _ := http.NewRequest(http.MethodHead, candidate, nil)
-		resp, err := client.Do(req)
-		if err == nil && resp != nil && resp.StatusCode >= 200 && resp.StatusCode < 400 {
-			movies[i].DetailURL = candidate
-		}
-		if resp != nil {
-			_ = resp.Body.Close()
-		}
+// netflixTopURL is the Tudum page for a chart, as checked by
+// netflixChartType, netflixCountry and netflixWeek. An empty week is the
+// latest.
+func netflixTopURL(typ, country, week string) string {
+	url := "https://www.netflix.com/tudum/top10"
+	if country != netflixGlobal {
+		url += "/" + country
+	}
+	switch typ {
+	case "tv":
+		url += "/tv"
+	case "popular":
+		url = "https://www.netflix.com/tudum/top10/most-popular/tv"
 	}
 
-	c.JSON(http.StatusOK, movies)
+	if week != "" {
+		url = fmt.Sprintf("%s?week=%s", url, week)
+	}
+	return url
 }
 
 // slugifyForTudum converts a title into a Tudum-like slug.
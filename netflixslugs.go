@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/earentir/netflixtudumscrapper"
+	"golang.org/x/text/unicode/norm"
+
+	"earapi/atomicfile"
+	"earapi/metrics"
+)
+
+// netflixSlugsFile remembers which Tudum page each title resolved to, and
+// which titles have none.
+const netflixSlugsFile = "netflixdata/slugs.json"
+
+const (
+	netflixSlugWorkers = 4
+	netflixSlugTimeout = 3 * time.Second // per HEAD request
+	netflixSlugBudget  = 8 * time.Second // for all of a request's misses
+
+	// A page found stays found for a month; a title with none is tried
+	// again after a week, in case Tudum adds one.
+	netflixSlugFoundTTL    = 30 * 24 * time.Hour
+	netflixSlugNotFoundTTL = 7 * 24 * time.Hour
+)
+
+// netflixSlugs resolves Top 10 titles to their Tudum pages for
+// /netflix/v1/top.
+var netflixSlugs = &netflixSlugCache{
+	client:   &http.Client{Timeout: netflixSlugTimeout},
+	inflight: map[string]*netflixSlugCall{},
+}
+
+// netflixSlugCache keeps resolved and unresolvable titles on disk, keyed by
+// the title's plain slug. Concurrent requests for the same title share one
+// resolution.
+type netflixSlugCache struct {
+	client *http.Client
+
+	mu       sync.Mutex
+	entries  map[string]netflixSlugEntry
+	loaded   bool
+	dirty    bool
+	inflight map[string]*netflixSlugCall
+
+	files sync.Mutex // held while the cache file is rewritten
+}
+
+type netflixSlugEntry struct {
+	URL       string    `json:"url,omitempty"` // "" when no candidate had a page
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+func (e netflixSlugEntry) fresh(now time.Time) bool {
+	ttl := netflixSlugNotFoundTTL
+	if e.URL != "" {
+		ttl = netflixSlugFoundTTL
+	}
+	return now.Sub(e.CheckedAt) < ttl
+}
+
+type netflixSlugCall struct {
+	done chan struct{}
+	url  string
+	err  error
+}
+
+// errSlugUnsure is a resolution cut short by a timeout or a network error:
+// nothing is known, so nothing is remembered.
+var errSlugUnsure = errors.New("tudum page check inconclusive")
+
+// fill sets DetailURL on the items that lack one, trying netflixSlugWorkers
+// titles at a time within netflixSlugBudget. year is the chart's, for the
+// year-suffixed candidates. Titles that can't be resolved in time are left
+// empty and tried again next time.
+func (s *netflixSlugCache) fill(ctx context.Context, items []netflixtudumscrapper.Item, year int) {
+	ctx, cancel := context.WithTimeout(ctx, netflixSlugBudget)
+	defer cancel()
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range netflixSlugWorkers {
+		wg.Go(func() {
+			for i := range next {
+				if url, err := s.resolve(ctx, items[i].Title, year); err == nil {
+					items[i].DetailURL = url
+				}
+			}
+		})
+	}
+	for i := range items {
+		if items[i].DetailURL == "" && slugifyForTudum(items[i].Title) != "" {
+			next <- i
+		}
+	}
+	close(next)
+	wg.Wait()
+	s.save()
+}
+
+// resolve returns title's Tudum page, "" when it has none.
+func (s *netflixSlugCache) resolve(ctx context.Context, title string, year int) (string, error) {
+	key := slugifyForTudum(title)
+	s.mu.Lock()
+	s.load()
+	e, ok := s.entries[key]
+	if ok && e.fresh(time.Now()) {
+		s.mu.Unlock()
+		metrics.CacheLookup("netflix_slugs", true)
+		return e.URL, nil
+	}
+	metrics.CacheLookup("netflix_slugs", false)
+	call, ok := s.inflight[key]
+	if !ok {
+		call = &netflixSlugCall{done: make(chan struct{})}
+		s.inflight[key] = call
+	}
+	s.mu.Unlock()
+	if ok {
+		select {
+		case <-call.done:
+			return call.url, call.err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+
+	call.url, call.err = s.probe(ctx, title, year)
+	s.mu.Lock()
+	delete(s.inflight, key)
+	if call.err == nil {
+		s.entries[key] = netflixSlugEntry{URL: call.url, CheckedAt: time.Now().UTC()}
+		s.dirty = true
+	}
+	s.mu.Unlock()
+	close(call.done)
+	return call.url, call.err
+}
+
+// probe tries title's candidate slugs in turn. "" with no error means
+// Tudum answered for every one and none has a page.
+func (s *netflixSlugCache) probe(ctx context.Context, title string, year int) (string, error) {
+	for _, slug := range tudumSlugCandidates(title, year) {
+		url := "https://www.netflix.com/tudum/" + slug
+		ok, err := s.exists(ctx, url)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return url, nil
+		}
+	}
+	return "", nil
+}
+
+// exists reports whether Tudum has a page at url. Anything but a clear
+// yes or no is errSlugUnsure.
+func (s *netflixSlugCache) exists(ctx context.Context, url string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return false, err
+	}
+	done := metrics.Upstream("netflix", "tudum_page")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		done(metrics.OutcomeError)
+		return false, fmt.Errorf("%w: %v", errSlugUnsure, err)
+	}
+	_ = resp.Body.Close()
+	switch {
+	case resp.StatusCode < 400:
+		done(metrics.OutcomeOK)
+		return true, nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		done(metrics.OutcomeOK)
+		return false, nil
+	default:
+		done(metrics.OutcomeError)
+		return false, fmt.Errorf("%w: HTTP %d", errSlugUnsure, resp.StatusCode)
+	}
+}
+
+// load reads the cache file once. Called with mu held.
+func (s *netflixSlugCache) load() {
+	if s.loaded {
+		return
+	}
+	s.loaded = true
+	s.entries = map[string]netflixSlugEntry{}
+	data, err := os.ReadFile(netflixSlugsFile)
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, &s.entries); err != nil {
+		slog.Warn("netflix slug cache unreadable, starting empty", "path", netflixSlugsFile, "err", err)
+		s.entries = map[string]netflixSlugEntry{}
+	}
+}
+
+// save writes the cache back when something was learned, dropping entries
+// too old to be used. files is taken before the entries are read, so two
+// saves write in the order they saw the cache and the newer one lands last.
+func (s *netflixSlugCache) save() {
+	s.files.Lock()
+	defer s.files.Unlock()
+
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	maps.DeleteFunc(s.entries, func(_ string, e netflixSlugEntry) bool { return !e.fresh(now) })
+	data, err := json.Marshal(s.entries)
+	s.dirty = false
+	s.mu.Unlock()
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(netflixSlugsFile), 0755); err == nil {
+			err = atomicfile.Write(netflixSlugsFile, data, 0644)
+		}
+	}
+	if err != nil {
+		slog.Warn("netflix slug cache write failed", "path", netflixSlugsFile, "err", err)
+		s.mu.Lock()
+		s.dirty = true // try again on the next save
+		s.mu.Unlock()
+	}
+}
+
+var (
+	// tudumSubtitle is what follows the show or film's own name: ": Season 2",
+	// " - Limited Series", " (2024)".
+	tudumSubtitle = regexp.MustCompile(`\s*(?::|\s[-–—]\s|\().*$`)
+
+	// tudumFolds covers the letters that don't decompose into a base letter
+	// and an accent.
+	tudumFolds = strings.NewReplacer("ß", "ss", "æ", "ae", "Æ", "ae", "œ", "oe", "Œ", "oe",
+		"ø", "o", "Ø", "o", "ł", "l", "Ł", "l", "đ", "d", "Đ", "d", "þ", "th", "Þ", "th", "ı", "i")
+)
+
+// transliterate folds title to ASCII where it can: "Amélie" -> "Amelie".
+func transliterate(title string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(tudumFolds.Replace(title)) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// tudumSlugCandidates lists the slugs worth trying for title, most likely
+// first: as given, transliterated, without its subtitle, then each of those
+// with the chart's year and the year before, which Tudum appends when a
+// name is taken.
+func tudumSlugCandidates(title string, year int) []string {
+	var bases []string
+	add := func(list *[]string, slug string) {
+		if slug != "" && !slices.Contains(*list, slug) {
+			*list = append(*list, slug)
+		}
+	}
+	add(&bases, slugifyForTudum(title))
+	add(&bases, slugifyForTudum(transliterate(title)))
+	add(&bases, slugifyForTudum(transliterate(tudumSubtitle.ReplaceAllString(title, ""))))
+
+	out := slices.Clone(bases)
+	if year > 0 {
+		for _, b := range bases {
+			add(&out, fmt.Sprintf("%s-%d", b, year))
+			add(&out, fmt.Sprintf("%s-%d", b, year-1))
+		}
+	}
+	return out
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/earentir/netflixtudumscrapper"
+
+	"earapi/api"
+	"earapi/metrics"
+)
+
+// netflixCountries maps the country slugs Tudum publishes charts for to
+// their ISO 3166-1 codes, which are accepted too.
+var netflixCountries = map[string]string{
+	"argentina": "ar", "australia": "au", "austria": "at", "bahamas": "bs", "bahrain": "bh",
+	"bangladesh": "bd", "belgium": "be", "bolivia": "bo", "brazil": "br", "bulgaria": "bg",
+	"canada": "ca", "chile": "cl", "colombia": "co", "costa-rica": "cr", "croatia": "hr",
+	"cyprus": "cy", "czech-republic": "cz", "denmark": "dk", "dominican-republic": "do", "ecuador": "ec",
+	"egypt": "eg", "el-salvador": "sv", "estonia": "ee", "finland": "fi", "france": "fr",
+	"germany": "de", "greece": "gr", "guadeloupe": "gp", "guatemala": "gt", "honduras": "hn",
+	"hong-kong": "hk", "hungary": "hu", "iceland": "is", "india": "in", "indonesia": "id",
+	"ireland": "ie", "israel": "il", "italy": "it", "jamaica": "jm", "japan": "jp",
+	"jordan": "jo", "kenya": "ke", "kuwait": "kw", "latvia": "lv", "lebanon": "lb",
+	"lithuania": "lt", "luxembourg": "lu", "malaysia": "my", "maldives": "mv", "malta": "mt",
+	"martinique": "mq", "mauritius": "mu", "mexico": "mx", "morocco": "ma", "netherlands": "nl",
+	"new-caledonia": "nc", "new-zealand": "nz", "nicaragua": "ni", "nigeria": "ng", "norway": "no",
+	"oman": "om", "pakistan": "pk", "panama": "pa", "paraguay": "py", "peru": "pe",
+	"philippines": "ph", "poland": "pl", "portugal": "pt", "qatar": "qa", "reunion": "re",
+	"romania": "ro", "saudi-arabia": "sa", "serbia": "rs", "singapore": "sg", "slovakia": "sk",
+	"slovenia": "si", "south-africa": "za", "south-korea": "kr", "spain": "es", "sri-lanka": "lk",
+	"sweden": "se", "switzerland": "ch", "taiwan": "tw", "thailand": "th", "trinidad": "tt",
+	"turkey": "tr", "ukraine": "ua", "united-arab-emirates": "ae", "united-kingdom": "gb", "united-states": "us",
+	"uruguay": "uy", "venezuela": "ve", "vietnam": "vn",
+}
+
+// netflixCountry returns the Tudum slug for a slug or ISO code, and
+// netflixGlobal for "" or "global".
+func netflixCountry(raw string) (string, error) {
+	cc := strings.ToLower(strings.TrimSpace(raw))
+	switch cc {
+	case "", netflixGlobal:
+		return netflixGlobal, nil
+	case "uk":
+		return "united-kingdom", nil
+	}
+	if _, ok := netflixCountries[cc]; ok {
+		return cc, nil
+	}
+	for slug, iso := range netflixCountries {
+		if iso == cc {
+			return slug, nil
+		}
+	}
+	return "", api.New(api.KindInvalidInput, fmt.Sprintf("Netflix publishes no Top 10 for country %q", raw),
+		"Use a Tudum slug such as united-states or an ISO code such as us; omit it for the global chart.")
+}
+
+// netflixChartType returns the canonical chart for a type and its aliases:
+// films, tv or popular.
+func netflixChartType(raw string) (string, error) {
+	switch t := strings.ToLower(strings.TrimSpace(raw)); t {
+	case "", "films", "movies":
+		return "films", nil
+	case "series", "tv":
+		return "tv", nil
+	case "pop", "popular":
+		return "popular", nil
+	default:
+		return "", api.New(api.KindInvalidInput, fmt.Sprintf("type %q is not a Top 10 chart", raw), "Use films, tv or popular.")
+	}
+}
+
+// netflixFirstWeek is the first week Tudum has a chart for.
+const netflixFirstWeek = "2021-07-04"
+
+var isoWeekPattern = regexp.MustCompile(`^(\d{4})-W(\d{2})$`)
+
+// netflixWeek reads a chart week as a date (YYYY-MM-DD, any day of the
+// week) or an ISO week (YYYY-Www), and returns the Sunday that ends it,
+// which is how Tudum names weeks. "" is returned as is.
+func netflixWeek(name, raw string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+	bad := api.New(api.KindInvalidInput, fmt.Sprintf("%s %q is not a date or ISO week", name, raw),
+		"Use YYYY-MM-DD (any day of the chart week) or YYYY-Www, e.g. 2026-W41.")
+	var day time.Time
+	if m := isoWeekPattern.FindStringSubmatch(raw); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		week, _ := strconv.Atoi(m[2])
+		// 4 January is always in ISO week 1.
+		jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.UTC)
+		day = jan4.AddDate(0, 0, 7*(week-1))
+		if y, w := day.ISOWeek(); y != year || w != week {
+			return "", bad
+		}
+	} else {
+		var err error
+		if day, err = time.Parse(time.DateOnly, raw); err != nil {
+			return "", bad
+		}
+	}
+	// ISO weeks run Monday to Sunday, as Tudum's do.
+	sunday := day.AddDate(0, 0, (7-int(day.Weekday()))%7).Format(time.DateOnly)
+	if latest := netflixLatestWeek(time.Now()); sunday < netflixFirstWeek || sunday > latest {
+		return "", api.New(api.KindInvalidInput, fmt.Sprintf("%s %s has no chart", name, raw),
+			fmt.Sprintf("Charts run from the week ending %s to the week ending %s.", netflixFirstWeek, latest))
+	}
+	return sunday, nil
+}
+
+// scrapeNetflixTop scrapes one Tudum chart page.
+func scrapeNetflixTop(ctx context.Context, url string) ([]netflixtudumscrapper.Item, error) {
+	slog.DebugContext(ctx, "scraping netflix top 10", "url", url)
+	done := metrics.Upstream("netflix", "top10")
+	items, err := netflixtudumscrapper.ScrapeNetflix(url)
+	done(metrics.Outcome(err))
+	if err != nil {
+		slog.WarnContext(ctx, "netflix top 10 scrape failed", "url", url, "err", err)
+		return nil, api.New(api.KindUpstream, "could not read the Top 10 from Tudum: "+err.Error(),
+			"Tudum may be down or have changed its page; try again later.")
+	}
+	return items, nil
+}
+
+// Cache states reported by netflixTop.get, and in X-Cache.
+const (
+	netflixCacheHit   = "hit"
+	netflixCacheMiss  = "miss"
+	netflixCacheStale = "stale"
+)
+
+// netflixStaleFor is how long past its TTL a chart is still served while a
+// fresh copy is fetched in the background.
+const netflixStaleFor = 24 * time.Hour
+
+// netflixMaxCharts bounds the cache; the oldest chart goes first.
+const netflixMaxCharts = 512
+
+// netflixTop is the chart cache behind /netflix/v1/top.
+var netflixTop = &netflixTopCache{charts: map[string]netflixTopEntry{}, inflight: map[string]*netflixTopCall{}}
+
+// netflixTopCache keeps scraped charts in memory by URL for a TTL. A chart
+// a little past it is served at once and refreshed behind the request.
+// Concurrent requests for the same URL share one scrape.
+type netflixTopCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration // 0 disables the cache
+	charts   map[string]netflixTopEntry
+	inflight map[string]*netflixTopCall
+}
+
+type netflixTopEntry struct {
+	fetchedAt time.Time
+	items     []netflixtudumscrapper.Item
+}
+
+type netflixTopCall struct {
+	done  chan struct{}
+	items []netflixtudumscrapper.Item
+	err   error
+}
+
+// apply swaps in the cache TTL from cfg.
+func (n *netflixTopCache) apply(cfg earapiSettings) {
+	minutes := cfg.Netflix.CacheMinutes
+	if minutes == 0 {
+		minutes = 60
+	}
+	n.mu.Lock()
+	n.ttl = time.Duration(max(minutes, 0)) * time.Minute
+	n.mu.Unlock()
+}
+
+// get returns the chart at url and how it was served: netflixCacheHit, netflixCacheStale
+// or netflixCacheMiss. The items are the caller's to change. When Tudum can't be
+// scraped, any cached copy is better than nothing.
+func (n *netflixTopCache) get(ctx context.Context, url string) ([]netflixtudumscrapper.Item, string, error) {
+	n.mu.Lock()
+	ttl := n.ttl
+	e, ok := n.charts[url]
+	n.mu.Unlock()
+
+	if ttl > 0 && ok {
+		age := time.Since(e.fetchedAt)
+		metrics.CacheLookup("netflix", age < ttl)
+		if age < ttl {
+			return slices.Clone(e.items), netflixCacheHit, nil
+		}
+		if age < ttl+netflixStaleFor {
+			go func() {
+				ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+				defer cancel()
+				_, _ = n.shared(ctx, url)
+			}()
+			return slices.Clone(e.items), netflixCacheStale, nil
+		}
+	} else if ttl > 0 {
+		metrics.CacheLookup("netflix", false)
+	}
+
+	items, err := n.shared(ctx, url)
+	if err != nil && ok {
+		slog.DebugContext(ctx, "netflix top 10: serving expired chart", "url", url, "err", err)
+		return slices.Clone(e.items), netflixCacheStale, nil
+	}
+	return slices.Clone(items), netflixCacheMiss, err
+}
+
+// shared scrapes url, joining a scrape already under way, and caches the
+// result.
+func (n *netflixTopCache) shared(ctx context.Context, url string) ([]netflixtudumscrapper.Item, error) {
+	n.mu.Lock()
+	call, ok := n.inflight[url]
+	if !ok {
+		call = &netflixTopCall{done: make(chan struct{})}
+		n.inflight[url] = call
+	}
+	n.mu.Unlock()
+	if ok {
+		select {
+		case <-call.done:
+			return call.items, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	call.items, call.err = scrapeNetflixTop(ctx, url)
+	n.mu.Lock()
+	delete(n.inflight, url)
+	if call.err == nil && n.ttl > 0 {
+		n.charts[url] = netflixTopEntry{fetchedAt: time.Now(), items: call.items}
+		n.evict()
+	}
+	n.mu.Unlock()
+	close(call.done)
+	return call.items, call.err
+}
+
+// evict drops the oldest charts past netflixMaxCharts. Called with mu held.
+func (n *netflixTopCache) evict() {
+	for len(n.charts) > netflixMaxCharts {
+		var oldest string
+		for url, e := range n.charts {
+			if oldest == "" || e.fetchedAt.Before(n.charts[oldest].fetchedAt) {
+				oldest = url
+			}
+		}
+		delete(n.charts, oldest)
+	}
+}
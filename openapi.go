@@ -0,0 +1,681 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"earapi/api"
+)
+
+// The docs UI under doc/ is compiled into the binary and served at /doc/.
+//
+//go:embed doc
+var docFiles embed.FS
+
+// schema is a JSON Schema fragment as OpenAPI 3.0 spells it.
+type schema = map[string]any
+
+func ref(name string) schema { return schema{"$ref": "#/components/schemas/" + name} }
+
+func arrayOf(items schema) schema { return schema{"type": "array", "items": items} }
+
+func object(props schema, required ...string) schema {
+	s := schema{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+var (
+	tString  = schema{"type": "string"}
+	tInt     = schema{"type": "integer"}
+	tNumber  = schema{"type": "number"}
+	tBool    = schema{"type": "boolean"}
+	tAny     = schema{}
+	tStrings = arrayOf(tString)
+)
+
+type apiParam struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Schema      schema `json:"schema"`
+}
+
+func query(name, desc string) apiParam {
+	return apiParam{Name: name, In: "query", Description: desc, Schema: tString}
+}
+
+func queryReq(name, desc string) apiParam {
+	p := query(name, desc)
+	p.Required = true
+	return p
+}
+
+func pathParam(name, desc string) apiParam {
+	return apiParam{Name: name, In: "path", Description: desc, Required: true, Schema: tString}
+}
+
+// Error dialects, one per api.Dialect. Each v1 route answers failures in one
+// of these shapes; the spec says which so clients know what to parse. v2
+// requests always get the Envelope schema instead.
+const (
+	errStructured = "Error"       // {"error":{"kind","message","hint"}}
+	errLegacy     = "LegacyError" // {"error":"…"}
+	errFlagged    = "Flagged"     // {"success":false,"msg":"…"} with HTTP 200
+)
+
+// steamUserParamDesc covers every form resolveSteamUser accepts.
+const steamUserParamDesc = "SteamID64, STEAM_1:Y:Z, [U:1:N], custom URL name, or a steamcommunity.com/id/… or /profiles/… link"
+
+// apiOp describes one route for the OpenAPI document.
+type apiOp struct {
+	Method  string
+	Path    string // gin syntax, :id for path parameters
+	Tag     string
+	Summary string
+	Params  []apiParam
+	Body    schema // JSON request body
+	Form    schema // multipart/form-data request body
+	Status  int    // success status; 0 means 200
+	Result  schema // success body; nil means any JSON
+	Errors  string // error dialect; "" means none documented
+	Stream  bool   // text/event-stream rather than JSON
+	Media   string // media type of a success body that isn't JSON
+}
+
+var listInput = object(schema{
+	"owner":  tString,
+	"titles": arrayOf(ref("Title")),
+})
+
+// titleSource is the body shared by the Jellyfin match and sync calls: which
+// titles to work on, by stored handle or inline.
+var titleSource = schema{
+	"url":          schema{"type": "string", "description": "Jellyfin server; omit to use the connected session"},
+	"api_key":      tString,
+	"watchlist_id": tString,
+	"compare_id":   tString,
+	"view":         schema{"type": "string", "description": "common | partial | all | unique:<owner>"},
+	"titles":       arrayOf(ref("Title")),
+	"movies_only":  tBool,
+}
+
+// tilecalcOptions are the query options both tilecalc endpoints share.
+var tilecalcOptions = []apiParam{
+	query("minsplit", "smallest rows/columns to consider"), query("maxsplit", "largest rows/columns to consider"),
+	query("price", "price amount"), query("per", "tiles the price covers (default 1)"),
+	query("graph", "true to include an ASCII grid"),
+	query("singledimensionpattern", "true to lay tiles in one orientation only"),
+	query("meter", "true to report in metres"), query("inches", "true to report in inches"),
+}
+
+// netflixChartOptions pick an archived Netflix chart.
+var netflixChartOptions = []apiParam{
+	query("country", "Tudum country slug or ISO code, e.g. united-states or us; default global"),
+	query("type", "films (default) | tv | popular"),
+}
+
+// netflixFeedOptions pick the chart a Top 10 feed follows.
+var netflixFeedOptions = []apiParam{
+	query("type", "films (default) | series | popular; movies/tv are aliases"),
+	query("country", "Tudum country slug or ISO code, e.g. united-states or us; omit for global"),
+	query("week", "any day of the chart week as YYYY-MM-DD, or an ISO week as YYYY-Www; omit for the latest"),
+}
+
+// apiOps is every documented route. checkOpenAPI compares it with the router on
+// startup, so a route added without an entry here shows up in the log.
+var apiOps = []apiOp{
+	{Method: "GET", Path: "/", Tag: "core", Summary: "List registered routes",
+		Result: object(schema{"endpoints": tStrings})},
+	{Method: "GET", Path: "/version", Tag: "core", Summary: "Current API version",
+		Result: object(schema{"version": tString})},
+	{Method: "GET", Path: "/metrics", Tag: "core", Summary: "Prometheus metrics (text exposition format)"},
+	{Method: "GET", Path: "/healthz", Tag: "core", Summary: "Liveness: 200 while the process serves HTTP",
+		Result: object(schema{"status": tString})},
+	{Method: "GET", Path: "/readyz", Tag: "core", Summary: "Readiness with per-dependency checks; 503 when a required one fails",
+		Result: ref("Readiness")},
+	{Method: "GET", Path: "/openapi.json", Tag: "core", Summary: "This OpenAPI document"},
+	{Method: "GET", Path: "/joke", Tag: "core", Summary: "Geek joke or BOFH excuse",
+		Params: []apiParam{query("type", "geek (default) | excuse")}, Errors: errLegacy},
+
+	{Method: "GET", Path: "/steam/v1/top", Tag: "steam", Summary: "Top apps for a user by playtime or last played",
+		Params: []apiParam{queryReq("userid", steamUserParamDesc), query("count", "how many to return (default 10)"),
+			query("sortby", "playtime (default) | lastplayed")},
+		Result: ref("Flagged"), Errors: errFlagged},
+	{Method: "GET", Path: "/steam/v1/getuserid", Tag: "steam", Summary: "Resolve any form of Steam user to a SteamID64 and the other ID formats",
+		Params: []apiParam{queryReq("username", steamUserParamDesc)},
+		Result: ref("Flagged"), Errors: errFlagged},
+	{Method: "GET", Path: "/steam/v1/appsused", Tag: "steam", Summary: "All owned and played apps for a user",
+		Params: []apiParam{queryReq("userid", steamUserParamDesc)},
+		Result: ref("Flagged"), Errors: errFlagged},
+	{Method: "GET", Path: "/steam/v1/appdata", Tag: "steam", Summary: "Store details for an app id",
+		Params: []apiParam{queryReq("appid", "numeric app id")},
+		Result: ref("Flagged"), Errors: errFlagged},
+	{Method: "POST", Path: "/steam/v1/appdata/batch", Tag: "steam", Summary: "Store details for up to 100 app ids, each succeeding or failing on its own",
+		Body:   object(schema{"appids": arrayOf(tInt)}, "appids"),
+		Result: ref("Flagged"), Errors: errFlagged},
+	{Method: "GET", Path: "/steam/v1/search", Tag: "steam", Summary: "Find apps by name, ranked",
+		Params: []apiParam{
+			queryReq("app", "app name or id; partial words and small typos are allowed"),
+			query("limit", "candidates to return, 1-50 (default 10)"),
+			query("type", "game, dlc, software, video or hardware; comma-separated"),
+		},
+		Result: ref("Flagged"), Errors: errFlagged},
+	{Method: "GET", Path: "/steam/v1/library/stats", Tag: "steam", Summary: "Playtime totals and genre/developer breakdown of a library",
+		Params: []apiParam{
+			queryReq("userid", steamUserParamDesc),
+			query("details", "store lookups for games missing from the details cache, 0-50 (default 10)"),
+			query("top", "genres and developers to return, 1-100 (default 10)"),
+		},
+		Result: ref("Flagged"), Errors: errFlagged},
+	{Method: "GET", Path: "/steam/v1/library/history", Tag: "steam", Summary: "Playtime deltas between library snapshots",
+		Params: []apiParam{
+			queryReq("userid", steamUserParamDesc),
+			query("weeks", "how far back, 1-520 (default 12)"),
+			query("bucket", "week (default; last snapshot per ISO week) | snapshot"),
+			query("top", "most-played games per point, 0-50 (default 5)"),
+		},
+		Result: ref("Flagged"), Errors: errFlagged},
+	{Method: "POST", Path: "/steam/v1/compare", Tag: "steam", Summary: "Games two or more users own in common, alone, or own but never played",
+		Body: object(schema{
+			"users":         schema{"type": "array", "items": tString, "description": "2-8 users, in any form getuserid accepts"},
+			"heavy_minutes": schema{"type": "integer", "description": "playtime that counts as heavily played (default 600)"},
+		}, "users"),
+		Result: ref("Flagged"), Errors: errFlagged},
+	{Method: "GET", Path: "/steam/v1/prices", Tag: "steam", Summary: "Tracked apps with their current and lowest prices",
+		Result: ref("Flagged"), Errors: errFlagged},
+	{Method: "POST", Path: "/steam/v1/prices", Tag: "steam", Summary: "Track an app's price in a store country, or change its alert threshold",
+		Body: object(schema{
+			"appid":     tInt,
+			"country":   schema{"type": "string", "description": "two-letter store country (default steam.price_country)"},
+			"threshold": schema{"type": "integer", "description": "alert at or below this final price, in minor units (2999 = 29.99)"},
+		}, "appid"),
+		Result: ref("Flagged"), Errors: errFlagged},
+	{Method: "DELETE", Path: "/steam/v1/prices/:appid", Tag: "steam", Summary: "Stop tracking an app; its history is kept",
+		Params: []apiParam{pathParam("appid", "numeric app id"), query("country", "two-letter store country (default steam.price_country)")},
+		Result: ref("Flagged"), Errors: errFlagged},
+	{Method: "GET", Path: "/steam/v1/prices/:appid/history", Tag: "steam", Summary: "Every price change seen for an app, with its low and high",
+		Params: []apiParam{pathParam("appid", "numeric app id"), query("country", "two-letter store country (default steam.price_country)")},
+		Result: ref("Flagged"), Errors: errFlagged},
+
+	{Method: "GET", Path: "/steam/v1/admin/applist", Tag: "steam", Summary: "Age, size and last refresh of the app list cache",
+		Result: ref("Flagged"), Errors: errFlagged},
+	{Method: "POST", Path: "/steam/v1/admin/applist/refresh", Tag: "steam", Summary: "Refresh the app list cache now",
+		Params: []apiParam{query("full", "true to refetch the whole catalogue instead of apps past last_appid")},
+		Result: ref("Flagged"), Errors: errFlagged},
+	{Method: "POST", Path: "/steam/v1/admin/library/snapshot", Tag: "steam", Summary: "Snapshot a library now",
+		Params: []apiParam{queryReq("userid", steamUserParamDesc)},
+		Result: ref("Flagged"), Errors: errFlagged},
+	{Method: "POST", Path: "/steam/v1/admin/prices/poll", Tag: "steam", Summary: "Check every tracked price now",
+		Result: ref("Flagged"), Errors: errFlagged},
+
+	{Method: "GET", Path: "/tmdb/v1/search", Tag: "tmdb", Summary: "Search movies, TV and people, a page at a time",
+		Params: []apiParam{
+			queryReq("q", "title or name; query= is accepted too"),
+			query("type", "multi (default) | movie | tv | person"),
+			query("year", "release year for movies, first air year for TV"),
+			query("language", "ISO 639-1 code, optionally with a region: en, de, pt-BR"),
+			query("page", "1-500 (default 1)"),
+			query("adult", "true to include adult titles"),
+		}, Errors: errLegacy},
+	{Method: "GET", Path: "/tmdb/v1/movie/:id", Tag: "tmdb", Summary: "Movie details with cast and crew",
+		Params: []apiParam{pathParam("id", "TMDB movie id"), query("language", "ISO 639-1 code, optionally with a region"),
+			query("cast", "cast members to return, 0-500 (default 20)"), query("crew", "crew members to return, key jobs first, 0-500 (default 20)")},
+		Errors: errLegacy},
+	{Method: "GET", Path: "/tmdb/v1/tv/:id", Tag: "tmdb", Summary: "Show details with the latest season's cast and crew",
+		Params: []apiParam{pathParam("id", "TMDB show id"), query("language", "ISO 639-1 code, optionally with a region"),
+			query("cast", "cast members to return, 0-500 (default 20)"), query("crew", "crew members to return, key jobs first, 0-500 (default 20)")},
+		Errors: errLegacy},
+	{Method: "GET", Path: "/tmdb/v1/person/:id", Tag: "tmdb", Summary: "Person details with their movie and TV credits, newest first",
+		Params: []apiParam{pathParam("id", "TMDB person id"), query("language", "ISO 639-1 code, optionally with a region"),
+			query("credits", "credits to return, 0-1000 (default 50)")},
+		Errors: errLegacy},
+	{Method: "GET", Path: "/tmdb/v1/movie/:id/providers", Tag: "tmdb", Summary: "Where a movie can be streamed, rented or bought, by region",
+		Params: []apiParam{pathParam("id", "TMDB movie id"), query("region", "two-letter country code; omit for every region")},
+		Errors: errLegacy},
+	{Method: "GET", Path: "/tmdb/v1/tv/:id/providers", Tag: "tmdb", Summary: "Where a show can be streamed, rented or bought, by region",
+		Params: []apiParam{pathParam("id", "TMDB show id"), query("region", "two-letter country code; omit for every region")},
+		Errors: errLegacy},
+
+	{Method: "GET", Path: "/netflix/v1/top", Tag: "netflix", Summary: "Weekly Top 10 for a country and type",
+		Params: []apiParam{query("type", "films (default) | series | popular; movies/tv are aliases"),
+			query("country", "Tudum country slug or ISO code, e.g. united-states or us; omit for global"),
+			query("week", "any day of the chart week as YYYY-MM-DD, or an ISO week as YYYY-Www; omit for the latest"),
+			query("enrich", "imdb adds each entry's IMDb title, rating, votes and genres"),
+			query("watchlist_id", "stored watchlist id; flags entries with on_watchlist")},
+		Errors: errLegacy},
+	{Method: "GET", Path: "/netflix/v1/top.rss", Tag: "netflix", Summary: "Weekly Top 10 as an RSS 2.0 feed; honours If-None-Match and If-Modified-Since",
+		Params: netflixFeedOptions, Media: "application/rss+xml", Errors: errLegacy},
+	{Method: "GET", Path: "/netflix/v1/top.atom", Tag: "netflix", Summary: "Weekly Top 10 as an Atom feed; honours If-None-Match and If-Modified-Since",
+		Params: netflixFeedOptions, Media: "application/atom+xml", Errors: errLegacy},
+	{Method: "GET", Path: "/netflix/v1/archive", Tag: "netflix", Summary: "Archived Top 10 charts and their weeks",
+		Errors: errLegacy},
+	{Method: "GET", Path: "/netflix/v1/archive/history", Tag: "netflix", Summary: "A title's weekly rank in the archived charts",
+		Params: append([]apiParam{queryReq("title", "title as shown in the Top 10")}, netflixChartOptions...),
+		Errors: errLegacy},
+	{Method: "GET", Path: "/netflix/v1/archive/titles", Tag: "netflix", Summary: "Titles of an archived chart by weeks in the Top 10",
+		Params: append(slices.Clone(netflixChartOptions), query("limit", "1-1000, default 50")),
+		Errors: errLegacy},
+	{Method: "GET", Path: "/netflix/v1/archive/movement", Tag: "netflix", Summary: "New entries, drop-outs and movers between two archived weeks",
+		Params: append(slices.Clone(netflixChartOptions), query("from", "YYYY-MM-DD or YYYY-Www; default the week before to"),
+			query("to", "YYYY-MM-DD or YYYY-Www; default the latest archived")),
+		Errors: errLegacy},
+	{Method: "POST", Path: "/netflix/v1/admin/archive/collect", Tag: "netflix", Summary: "Archive a chart week now",
+		Params: []apiParam{query("week", "YYYY-MM-DD or YYYY-Www; default the latest published"),
+			query("country", "one country instead of netflix.archive_countries"),
+			query("force", "true re-scrapes weeks already archived")},
+		Errors: errLegacy},
+
+	{Method: "GET", Path: "/tilecalc/v1/arrange", Tag: "tilecalc", Summary: "Grid arrangements for a fixed tile count",
+		Params: append([]apiParam{query("size", "tile WxH in cm; or width= and height="),
+			queryReq("count", "number of tiles")}, tilecalcOptions...),
+		Result: ref("Flagged"), Errors: errFlagged},
+	{Method: "GET", Path: "/tilecalc/v1/coverage", Tag: "tilecalc", Summary: "Tiles and cuts needed to fill a space",
+		Params: append([]apiParam{query("size", "tile WxH in cm; or width= and height="),
+			queryReq("space", "space WxH in cm")}, tilecalcOptions...),
+		Result: ref("Flagged"), Errors: errFlagged},
+
+	{Method: "GET", Path: "/dmt/v1/formats", Tag: "dmt", Summary: "Discord timestamp styles",
+		Result: ref("Flagged"), Errors: errFlagged},
+	{Method: "GET", Path: "/dmt/v1/timestamp", Tag: "dmt", Summary: "Convert a date and time to Discord <t:…> tags",
+		Params: []apiParam{query("unix", "unix seconds"), query("epoch", "alias of unix"),
+			query("datetime", "RFC 3339 or YYYY-MM-DD HH:MM[:SS]"), query("year", ""), query("month", ""),
+			query("day", ""), query("hour", ""), query("minute", ""), query("second", ""),
+			query("offset", "zone for the component and datetime forms, e.g. +03:00"),
+			query("format", "f F d D t T R or 0-6; style= is accepted too"),
+			query("complete", "true to round up to the next 5 minutes")},
+		Result: ref("Flagged"), Errors: errFlagged},
+
+	{Method: "POST", Path: "/youtube/v1/playlist/add", Tag: "youtube", Summary: "Add a video to a playlist by name",
+		Body: object(schema{"playlistName": tString, "video": schema{"type": "string", "description": "URL or video id"},
+			"force": tBool, "user": tString}, "playlistName", "video"),
+		Errors: errLegacy},
+	{Method: "POST", Path: "/youtube/v1/playlist/create", Tag: "youtube", Summary: "Create a playlist",
+		Body:   object(schema{"name": tString, "privacy": schema{"type": "string", "enum": []string{"private", "unlisted", "public"}}}, "name"),
+		Result: object(schema{"playlistId": tString, "title": tString}), Errors: errLegacy},
+	{Method: "GET", Path: "/youtube/v1/playlist/items", Tag: "youtube", Summary: "Videos in a playlist",
+		Params: []apiParam{queryReq("name", "playlist name"), query("fuzzy", "true to match the closest name"),
+			query("metadata", "true to include who added each video and when")},
+		Errors: errLegacy},
+	{Method: "GET", Path: "/youtube/v1/playlist/video/meta", Tag: "youtube", Summary: "Who added a video to a playlist, and when",
+		Params: []apiParam{queryReq("name", "playlist name"), queryReq("videoId", "video id"),
+			query("fuzzy", "false for an exact playlist name (default true)")},
+		Errors: errLegacy},
+
+	{Method: "POST", Path: "/imdb/v1/resolve", Tag: "imdb", Summary: "Parse an IMDb list URL, id or alias",
+		Body: object(schema{"input": tString}, "input"), Result: ref("ListRef"), Errors: errStructured},
+	{Method: "POST", Path: "/imdb/v1/fetch", Tag: "imdb", Summary: "Fetch a list in the background",
+		Body:   object(schema{"input": tString, "owner": tString, "refresh": tBool}, "input"),
+		Status: http.StatusAccepted, Result: ref("JobAccepted"), Errors: errStructured},
+	{Method: "POST", Path: "/imdb/v1/import-csv", Tag: "imdb", Summary: "Import an IMDb CSV export",
+		Form: object(schema{"file": schema{"type": "string", "format": "binary"}, "owner": tString,
+			"hydrate": schema{"type": "boolean", "description": "fill in metadata from IMDb (default true)"}}, "file"),
+		Result: object(schema{"watchlist_id": tString, "watchlist": ref("Watchlist")}), Errors: errStructured},
+	{Method: "POST", Path: "/imdb/v1/titles/hydrate", Tag: "imdb", Summary: "Metadata for title ids",
+		Body: object(schema{"ids": tStrings}, "ids"), Errors: errStructured},
+
+	{Method: "GET", Path: "/watchlist/v1/:id", Tag: "watchlist", Summary: "A stored watchlist",
+		Params: []apiParam{pathParam("id", "watchlist_id")}, Result: ref("Watchlist"), Errors: errStructured},
+	{Method: "GET", Path: "/watchlist/v1/:id/export", Tag: "watchlist", Summary: "Export a stored watchlist",
+		Params: []apiParam{pathParam("id", "watchlist_id"), query("format", "json (default) | csv")}, Errors: errStructured},
+	{Method: "DELETE", Path: "/watchlist/v1/:id", Tag: "watchlist", Summary: "Delete a stored watchlist",
+		Params: []apiParam{pathParam("id", "watchlist_id")},
+		Result: object(schema{"watchlist_id": tString, "deleted": tBool}), Errors: errStructured},
+	{Method: "POST", Path: "/watchlist/v1/:id/enrich", Tag: "watchlist", Summary: "Add TMDB ids, artwork and streaming providers in the background",
+		Params: []apiParam{pathParam("id", "watchlist_id"), query("source", "tmdb (default, the only source)"),
+			query("regions", "comma-separated regions for providers, e.g. US,GB (default US)")},
+		Status: http.StatusAccepted, Result: object(schema{"job_id": tString, "watchlist_id": tString}, "job_id"), Errors: errStructured},
+	{Method: "GET", Path: "/watchlistsync/v1/capabilities", Tag: "watchlist", Summary: "Feature flags for the tools UI"},
+
+	{Method: "POST", Path: "/compare/v1", Tag: "compare", Summary: "Compare two or more watchlists",
+		Body:   object(schema{"watchlist_ids": tStrings, "lists": arrayOf(listInput)}),
+		Result: schema{"allOf": []schema{ref("CompareResult"), object(schema{"compare_id": tString})}},
+		Errors: errStructured},
+	{Method: "GET", Path: "/compare/v1/:id", Tag: "compare", Summary: "A stored comparison",
+		Params: []apiParam{pathParam("id", "compare_id")}, Result: ref("CompareResult"), Errors: errStructured},
+	{Method: "GET", Path: "/compare/v1/:id/export", Tag: "compare", Summary: "Export one view of a comparison",
+		Params: []apiParam{pathParam("id", "compare_id"), query("view", "common | partial | all | unique:<owner>"),
+			query("format", "json (default) | csv")}, Errors: errStructured},
+	{Method: "DELETE", Path: "/compare/v1/:id", Tag: "compare", Summary: "Delete a stored comparison",
+		Params: []apiParam{pathParam("id", "compare_id")},
+		Result: object(schema{"compare_id": tString, "deleted": tBool}), Errors: errStructured},
+
+	{Method: "POST", Path: "/jellyfin/v1/connect", Tag: "jellyfin", Summary: "Connect a Jellyfin server for this session",
+		Body: object(schema{"url": tString, "api_key": tString}, "url", "api_key"), Errors: errStructured},
+	{Method: "POST", Path: "/jellyfin/v1/disconnect", Tag: "jellyfin", Summary: "Forget the session's Jellyfin server"},
+	{Method: "GET", Path: "/jellyfin/v1/status", Tag: "jellyfin", Summary: "Connection and library scan status"},
+	{Method: "POST", Path: "/jellyfin/v1/scan", Tag: "jellyfin", Summary: "Index the library in the background",
+		Body:   object(schema{"url": tString, "api_key": tString}),
+		Status: http.StatusAccepted, Result: ref("JobAccepted"), Errors: errStructured},
+	{Method: "POST", Path: "/jellyfin/v1/match", Tag: "jellyfin", Summary: "Match titles against the library",
+		Body: object(titleSource), Errors: errStructured},
+	{Method: "POST", Path: "/jellyfin/v1/sync", Tag: "jellyfin", Summary: "Create or update a playlist from titles",
+		Body: object(mergeSchema(titleSource, schema{
+			"playlist_name": tString,
+			"mode":          schema{"type": "string", "description": "create | replace | append"},
+			"public":        tBool,
+			"confirm":       schema{"type": "boolean", "description": "false previews the change"},
+			"item_ids":      tStrings,
+		})), Errors: errStructured},
+	{Method: "GET", Path: "/jellyfin/v1/playlists", Tag: "jellyfin", Summary: "Playlists on the server",
+		Params: []apiParam{query("name", "exact playlist name"), query("url", ""), query("api_key", "")},
+		Errors: errStructured},
+	{Method: "GET", Path: "/jellyfin/v1/playlists/items", Tag: "jellyfin", Summary: "Items in a playlist",
+		Params: []apiParam{queryReq("id", "playlist id"), query("url", ""), query("api_key", "")},
+		Errors: errStructured},
+
+	{Method: "GET", Path: "/jobs/v1/:id", Tag: "jobs", Summary: "Job snapshot",
+		Params: []apiParam{pathParam("id", "job_id")}, Result: ref("JobUpdate"), Errors: errStructured},
+	{Method: "GET", Path: "/jobs/v1/:id/events", Tag: "jobs", Summary: "Job progress as server-sent events",
+		Params: []apiParam{pathParam("id", "job_id")}, Stream: true, Errors: errStructured},
+}
+
+func mergeSchema(a, b schema) schema {
+	out := schema{}
+	for k, v := range a {
+		out[k] = v
+	}
+	for k, v := range b {
+		out[k] = v
+	}
+	return out
+}
+
+var apiTags = []schema{
+	{"name": "core", "description": "Service discovery, version, metrics and jokes."},
+	{"name": "steam", "description": "Steam libraries, app lookup and store details."},
+	{"name": "tmdb", "description": "Movie, TV and people search, details and watch providers via TMDB."},
+	{"name": "netflix", "description": "Netflix weekly Top 10 charts."},
+	{"name": "tilecalc", "description": "Tile layouts, coverage and pricing."},
+	{"name": "dmt", "description": "Discord <t:…> timestamp tags."},
+	{"name": "youtube", "description": "YouTube playlist helpers."},
+	{"name": "imdb", "description": "IMDb list parsing, fetching and CSV import."},
+	{"name": "watchlist", "description": "Stored watchlists."},
+	{"name": "compare", "description": "Watchlist comparisons."},
+	{"name": "jellyfin", "description": "Jellyfin library matching and playlist sync."},
+	{"name": "jobs", "description": "Progress of background fetches and scans."},
+}
+
+var apiSchemas = schema{
+	"Error":       object(schema{"error": ref("ErrorDetail")}, "error"),
+	"LegacyError": object(schema{"error": tString}, "error"),
+	"Flagged": object(schema{
+		"success": tBool,
+		"msg":     tString,
+		"data":    tAny,
+	}, "success", "msg"),
+	"Envelope": object(schema{
+		"ok":         tBool,
+		"data":       schema{"description": "the v1 success body"},
+		"error":      ref("ErrorDetail"),
+		"request_id": tString,
+	}, "ok"),
+	"ErrorDetail": object(schema{
+		"kind": schema{"type": "string", "description": "invalid_input, unauthorized, forbidden, not_found, conflict, " +
+			"rate_limited, upstream, unavailable, internal, or a group-specific kind such as private"},
+		"message": tString,
+		"hint":    tString,
+	}, "kind", "message"),
+	"Readiness": object(schema{
+		"status":         schema{"type": "string", "enum": []string{"ok", "degraded", "fail"}},
+		"version":        tString,
+		"uptime_seconds": tInt,
+		"checks": arrayOf(object(schema{
+			"name":       tString,
+			"status":     schema{"type": "string", "enum": []string{"ok", "disabled", "degraded", "fail"}},
+			"required":   tBool,
+			"detail":     tString,
+			"checked_at": schema{"type": "string", "format": "date-time"},
+		}, "name", "status")),
+	}, "status", "checks"),
+	"ListRef": object(schema{
+		"kind":  schema{"type": "string", "enum": []string{"watchlist", "list", "alias", "csv"}},
+		"id":    tString,
+		"label": tString,
+	}),
+	"Title": object(schema{
+		"imdb_id":         tString,
+		"title":           tString,
+		"original_title":  tString,
+		"year":            tInt,
+		"type":            tString,
+		"runtime_seconds": tInt,
+		"rating":          tNumber,
+		"votes":           tInt,
+		"genres":          tStrings,
+		"plot":            tString,
+		"poster_url":      tString,
+		"imdb_url":        tString,
+		"tmdb": object(schema{
+			"id":                tInt,
+			"type":              tString,
+			"backdrop_url":      tString,
+			"original_language": tString,
+			"providers": schema{"type": "object", "description": "by region",
+				"additionalProperties": object(schema{"link": tString, "stream": tStrings, "free": tStrings, "ads": tStrings})},
+			"enriched_at": schema{"type": "string", "format": "date-time"},
+		}),
+	}, "imdb_id"),
+	"Watchlist": object(schema{
+		"id":         tString,
+		"source":     ref("ListRef"),
+		"owner":      tString,
+		"name":       tString,
+		"fetched_at": schema{"type": "string", "format": "date-time"},
+		"count":      tInt,
+		"titles":     arrayOf(ref("Title")),
+	}),
+	"CompareEntry": object(schema{"title": ref("Title"), "owners": tStrings}),
+	"CompareResult": object(schema{
+		"owners":  tStrings,
+		"common":  arrayOf(ref("Title")),
+		"unique":  schema{"type": "object", "additionalProperties": arrayOf(ref("CompareEntry"))},
+		"partial": arrayOf(ref("CompareEntry")),
+		"all":     arrayOf(ref("CompareEntry")),
+		"stats":   schema{"type": "object"},
+		"sources": tStrings,
+	}),
+	"JobAccepted": object(schema{"job_id": tString, "source": ref("ListRef")}, "job_id"),
+	"JobUpdate": object(schema{
+		"id":       tString,
+		"state":    schema{"type": "string", "enum": []string{"running", "done", "failed"}},
+		"phase":    tString,
+		"current":  tInt,
+		"total":    tInt,
+		"message":  tString,
+		"result":   tAny,
+		"error":    ref("ErrorDetail"),
+		"finished": tBool,
+	}),
+}
+
+const v2Description = "Responses below are the v1 shapes. Send `Accept: " + api.MediaTypeV2 +
+	"` or prefix any path with /v2 to get every JSON response wrapped in the Envelope schema instead, " +
+	"with `data` holding the v1 success body and `error` an ErrorDetail served with its real HTTP status."
+
+// openAPIPath turns gin's /watchlist/v1/:id into OpenAPI's /watchlist/v1/{id}.
+func openAPIPath(p string) string {
+	segs := strings.Split(p, "/")
+	for i, s := range segs {
+		if name, ok := strings.CutPrefix(s, ":"); ok {
+			segs[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segs, "/")
+}
+
+func errorResponse(dialect string) schema {
+	return schema{
+		"description": "Error",
+		"content":     schema{"application/json": schema{"schema": ref(dialect)}},
+	}
+}
+
+// takesSteamUser reports whether op resolves Steam users, which answer a 400
+// even in the Flagged dialect when they don't resolve.
+func takesSteamUser(op apiOp) bool {
+	if op.Path == "/steam/v1/compare" {
+		return true
+	}
+	return slices.ContainsFunc(op.Params, func(p apiParam) bool { return p.Description == steamUserParamDesc })
+}
+
+// buildOpenAPI renders apiOps as an OpenAPI 3.0 document.
+func buildOpenAPI() schema {
+	paths := schema{}
+	for _, op := range apiOps {
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		ok := schema{"description": http.StatusText(status)}
+		switch {
+		case op.Stream:
+			ok["content"] = schema{"text/event-stream": schema{"schema": ref("JobUpdate")}}
+		case op.Media != "":
+			ok["content"] = schema{op.Media: schema{"schema": tString}}
+		case op.Result != nil:
+			ok["content"] = schema{"application/json": schema{"schema": op.Result}}
+		}
+		responses := schema{strconv.Itoa(status): ok}
+		switch op.Errors {
+		case errStructured, errLegacy:
+			responses["4XX"] = errorResponse(op.Errors)
+			responses["5XX"] = errorResponse(op.Errors)
+		case errFlagged:
+			ok["description"] = "OK; failures are reported with success=false and HTTP 200"
+			if takesSteamUser(op) {
+				responses["400"] = errorResponse(errFlagged) // a user that doesn't resolve
+			}
+		}
+
+		scope := requiredScope(op.Method, op.Path+"/")
+		if scope != scopeRead {
+			responses["401"] = errorResponse(errStructured)
+			responses["403"] = errorResponse(errStructured)
+		}
+		responses["429"] = errorResponse(errStructured)
+
+		o := schema{
+			"tags":        []string{op.Tag},
+			"summary":     op.Summary,
+			"operationId": strings.ToLower(op.Method) + strings.NewReplacer("/", "_", ":", "", ".", "_", "-", "_").Replace(op.Path),
+			"responses":   responses,
+		}
+		if len(op.Params) > 0 {
+			o["parameters"] = op.Params
+		}
+		switch {
+		case op.Body != nil:
+			o["requestBody"] = schema{"required": true, "content": schema{"application/json": schema{"schema": op.Body}}}
+		case op.Form != nil:
+			o["requestBody"] = schema{"required": true, "content": schema{"multipart/form-data": schema{"schema": op.Form}}}
+		}
+		if scope != scopeRead {
+			o["security"] = []schema{{"bearer": []string{}}, {"apiKey": []string{}}}
+			o["description"] = "Needs an API key with the " + scope + " scope when auth is enabled."
+		}
+
+		p := openAPIPath(op.Path)
+		item, _ := paths[p].(schema)
+		if item == nil {
+			item = schema{}
+			paths[p] = item
+		}
+		item[strings.ToLower(op.Method)] = o
+	}
+
+	return schema{
+		"openapi": "3.0.3",
+		"info": schema{
+			"title":       "earapi",
+			"version":     appVersion,
+			"description": v2Description,
+		},
+		"servers": []schema{{"url": "https://api.earentir.dev"}},
+		"tags":    apiTags,
+		"paths":   paths,
+		"components": schema{
+			"schemas": apiSchemas,
+			"securitySchemes": schema{
+				"bearer": schema{"type": "http", "scheme": "bearer"},
+				"apiKey": schema{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+	}
+}
+
+// registerDocs serves the embedded docs UI at /doc/ and the spec at
+// /openapi.json.
+func registerDocs(r *gin.Engine) {
+	spec := buildOpenAPI()
+	r.GET("/openapi.json", func(c *gin.Context) { c.JSON(http.StatusOK, spec) })
+
+	sub, err := fs.Sub(docFiles, "doc")
+	if err != nil {
+		panic(err) // the embed pattern guarantees the directory
+	}
+	r.StaticFS("/doc/", http.FS(sub))
+}
+
+// routeKey normalises a route for comparison: gin registers /compare/v1 and
+// /compare/v1/ separately, the spec lists it once.
+func routeKey(method, path string) string {
+	if len(path) > 1 {
+		path = strings.TrimSuffix(path, "/")
+	}
+	return method + " " + path
+}
+
+// checkOpenAPI logs routes the router serves but apiOps does not describe,
+// and documented routes the router lacks. The latter is expected for groups
+// that are switched off (YouTube without credentials), so it is only debug.
+// openapi_test.go holds the full route set to both.
+func checkOpenAPI(routes gin.RoutesInfo) {
+	missing, unserved := openAPIDiff(routes)
+	if len(missing) > 0 {
+		slog.Warn("routes missing from the OpenAPI document; add them to apiOps", "routes", missing)
+	}
+	if len(unserved) > 0 {
+		slog.Debug("documented routes not registered in this configuration", "routes", unserved)
+	}
+}
+
+// openAPIDiff returns, sorted, the routes served but not in apiOps and the
+// apiOps entries not served. HEAD routes and the docs file server are not
+// API operations.
+func openAPIDiff(routes gin.RoutesInfo) (missing, unserved []string) {
+	documented := map[string]bool{}
+	for _, op := range apiOps {
+		documented[routeKey(op.Method, op.Path)] = true
+	}
+	served := map[string]bool{}
+	for _, rt := range routes {
+		if rt.Method == http.MethodHead || strings.HasPrefix(rt.Path, "/doc/") {
+			continue
+		}
+		k := routeKey(rt.Method, rt.Path)
+		served[k] = true
+		if !documented[k] {
+			missing = append(missing, k)
+		}
+	}
+	for k := range documented {
+		if !served[k] {
+			unserved = append(unserved, k)
+		}
+	}
+	slices.Sort(missing)
+	slices.Sort(unserved)
+	return missing, unserved
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	wlpackage "earapi/watchlist"
+	ytpackage "earapi/youtube"
+)
+
+// TestOpenAPIMatchesRouter builds the router with every optional service
+// registered and fails on any route apiOps and the router disagree about.
+func TestOpenAPIMatchesRouter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, newReadiness())
+	ytpackage.RegisterRoutes(r, &ytpackage.Service{})
+	wlpackage.RegisterRoutes(r, &wlpackage.Service{})
+
+	missing, unserved := openAPIDiff(r.Routes())
+	for _, k := range missing {
+		t.Errorf("served but not in apiOps: %s", k)
+	}
+	for _, k := range unserved {
+		t.Errorf("in apiOps but not served: %s", k)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"earapi/api"
+)
+
+// rateLimitRule is one token bucket shape: PerMinute tokens refill evenly over
+// a minute, up to Burst held at once.
+type rateLimitRule struct {
+	PerMinute int `json:"per_minute"` // 0 = unlimited
+	Burst     int `json:"burst"`      // 0 = same as per_minute
+}
+
+func (r rateLimitRule) capacity() float64 {
+	if r.Burst > 0 {
+		return float64(r.Burst)
+	}
+	return float64(r.PerMinute)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter hands out tokens per (route group, client). Clients are the
+// authenticated API key when there is one, else the client IP — so a key
+// shared across machines shares one budget.
+type rateLimiter struct {
+	mu      sync.Mutex
+	enabled bool
+	def     rateLimitRule
+	groups  map[string]rateLimitRule
+	buckets map[string]*bucket
+}
+
+func newRateLimiter() *rateLimiter {
+	l := &rateLimiter{buckets: map[string]*bucket{}}
+	l.apply(*currentConfig())
+	go l.sweep()
+	return l
+}
+
+// apply swaps in the rate limit settings from cfg. Existing buckets keep their
+// balance and are clamped to the new capacity on their next request.
+func (l *rateLimiter) apply(cfg earapiSettings) {
+	l.mu.Lock()
+	l.enabled = cfg.RateLimit.Enabled
+	l.def = cfg.RateLimit.Default
+	l.groups = map[string]rateLimitRule{}
+	for k, v := range cfg.RateLimit.Groups {
+		l.groups[k] = v
+	}
+	l.mu.Unlock()
+}
+
+// take spends one token. It returns the rule applied, the tokens left, and how
+// long to wait when the bucket is empty.
+func (l *rateLimiter) take(group, client string, now time.Time) (rule rateLimitRule, ok bool, remaining int, wait time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rule, found := l.groups[group]
+	if !found {
+		rule = l.def
+	}
+	if !l.enabled || rule.PerMinute <= 0 {
+		return rule, true, -1, 0
+	}
+
+	capacity := rule.capacity()
+	perSec := float64(rule.PerMinute) / 60
+	key := group + "|" + client
+	b, found := l.buckets[key]
+	if !found {
+		b = &bucket{tokens: capacity, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*perSec)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait = time.Duration((1 - b.tokens) / perSec * float64(time.Second))
+		return rule, false, 0, wait
+	}
+	b.tokens--
+	return rule, true, int(b.tokens), 0
+}
+
+// sweep drops buckets that have been idle long enough to be full again; a
+// fresh bucket behaves identically, so this only bounds memory.
+func (l *rateLimiter) sweep() {
+	for range time.Tick(5 * time.Minute) {
+		cutoff := time.Now().Add(-10 * time.Minute)
+		l.mu.Lock()
+		for k, b := range l.buckets {
+			if b.last.Before(cutoff) {
+				delete(l.buckets, k)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
+
+// routeGroup names the group a path belongs to: "/steam/v1/top" → "steam".
+func routeGroup(path string) string {
+	seg, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	return seg
+}
+
+// rateLimitMiddleware answers 429 with Retry-After once a client's bucket for
+// the route group is empty, and reports the remaining quota on every response.
+func rateLimitMiddleware(l *rateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodOptions || healthPaths[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+		client := c.ClientIP()
+		if v, ok := c.Get(ctxAPIKey); ok {
+			client = "key:" + v.(*apiKeyRecord).ID
+		}
+
+		rule, ok, remaining, wait := l.take(routeGroup(c.Request.URL.Path), client, time.Now())
+		if remaining < 0 && ok {
+			c.Next() // unlimited
+			return
+		}
+		c.Header("X-RateLimit-Limit", strconv.Itoa(rule.PerMinute))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		if !ok {
+			secs := int(math.Ceil(wait.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(secs))
+			api.Fail(c, api.New(api.KindRateLimited, "too many requests",
+				"Retry in "+strconv.Itoa(secs)+"s."))
+			return
+		}
+		c.Next()
+	}
+}
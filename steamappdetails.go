@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/earentir/steamapidata"
+	"github.com/gin-gonic/gin"
+
+	"earapi/api"
+	"earapi/atomicfile"
+)
+
+// steamDetailsDir holds one store details file per app id.
+const steamDetailsDir = "steamdata/appdetails"
+
+// Limits for POST /steam/v1/appdata/batch.
+const (
+	steamBatchMax     = 100
+	steamBatchWorkers = 4
+	steamBatchTimeout = 30 * time.Second // misses still waiting on the store budget after this fail
+)
+
+// steamSingleTimeout bounds how long /steam/v1/appdata waits for store budget.
+const steamSingleTimeout = 10 * time.Second
+
+// errNotCached is returned by steamDetails.get for a miss it may not fetch.
+var errNotCached = errors.New("not cached")
+
+// steamDetails is the store details cache behind /steam/v1/appdata and the
+// library breakdowns.
+var steamDetails = &steamDetailsCache{inflight: map[int]*steamDetailsCall{}}
+
+// steamDetailsCache keeps store details on disk for a TTL and paces fetches
+// to the store, which starts refusing at around 200 calls per 5 minutes.
+// Concurrent requests for the same app share one fetch.
+type steamDetailsCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration // 0 disables the cache
+	inflight map[int]*steamDetailsCall
+
+	limit upstreamLimiter
+}
+
+type steamDetailsCall struct {
+	done    chan struct{}
+	details *steamapidata.SteamAppData
+	err     error
+}
+
+// steamDetailsEntry is one cache file. Apps the store has no page for are
+// kept too, with Found unset, so they aren't asked for again until expiry.
+type steamDetailsEntry struct {
+	FetchedAt time.Time       `json:"fetched_at"`
+	Found     bool            `json:"found"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// apply swaps in the cache TTL and store budget from cfg.
+func (s *steamDetailsCache) apply(cfg earapiSettings) {
+	hours := cfg.Steam.AppDetailsCacheHours
+	if hours == 0 {
+		hours = 24
+	}
+	s.mu.Lock()
+	s.ttl = time.Duration(max(hours, 0)) * time.Hour
+	s.mu.Unlock()
+	s.limit.apply(cfg.Steam.StoreRateLimit)
+}
+
+// get returns appID's store details, from disk when fresh enough. Otherwise
+// it fetches them, unless allowFetch is unset, which gives errNotCached.
+// When the store can't be reached an expired entry is better than nothing.
+// cached reports an answer from disk.
+func (s *steamDetailsCache) get(ctx context.Context, appID int, allowFetch bool) (details *steamapidata.SteamAppData, cached bool, err error) {
+	s.mu.Lock()
+	ttl := s.ttl
+	s.mu.Unlock()
+
+	var stale *steamDetailsEntry
+	if ttl > 0 {
+		if e, err := readSteamDetails(appID); err == nil {
+			if time.Since(e.FetchedAt) < ttl {
+				details, err := e.details(appID)
+				return details, true, err
+			}
+			stale = &e
+		}
+	}
+	if !allowFetch {
+		return nil, false, errNotCached
+	}
+
+	details, err = s.shared(ctx, appID)
+	if err != nil && stale != nil && stale.Found && api.From(err).Kind != api.KindNotFound {
+		slog.DebugContext(ctx, "steam app details: serving expired entry", "appid", appID, "err", err)
+		details, err := stale.details(appID)
+		return details, true, err
+	}
+	return details, false, err
+}
+
+// shared fetches appID, joining a fetch already under way. The fetch serves
+// everyone waiting on it, so it doesn't stop when the caller that started it
+// goes away: it keeps that caller's deadline, capped at steamBatchTimeout,
+// and each caller gives up only on its own ctx.
+func (s *steamDetailsCache) shared(ctx context.Context, appID int) (*steamapidata.SteamAppData, error) {
+	s.mu.Lock()
+	call, ok := s.inflight[appID]
+	if !ok {
+		call = &steamDetailsCall{done: make(chan struct{})}
+		s.inflight[appID] = call
+		deadline := time.Now().Add(steamBatchTimeout)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		fetchCtx, cancel := context.WithDeadline(context.WithoutCancel(ctx), deadline)
+		go func() {
+			defer cancel()
+			call.details, call.err = s.fetch(fetchCtx, appID)
+			s.mu.Lock()
+			delete(s.inflight, appID)
+			s.mu.Unlock()
+			close(call.done)
+		}()
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.details, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *steamDetailsCache) fetch(ctx context.Context, appID int) (*steamapidata.SteamAppData, error) {
+	if wait, err := s.limit.wait(ctx); err != nil {
+		return nil, api.New(api.KindRateLimited, "steam store lookup budget used up",
+			fmt.Sprintf("Retry in %ds.", int(math.Ceil(wait.Seconds()))))
+	}
+	body, err := steamGet(ctx, "appdetails", fmt.Sprintf("https://store.steampowered.com/api/appdetails?appids=%d&l=english", appID))
+	if err != nil {
+		return nil, api.New(api.KindUpstream, "steam store request failed: "+err.Error(), "")
+	}
+	var resp map[string]struct {
+		Success bool            `json:"success"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, api.New(api.KindUpstream, "steam store response: "+err.Error(), "")
+	}
+	r := resp[strconv.Itoa(appID)]
+	e := steamDetailsEntry{FetchedAt: time.Now().UTC(), Found: r.Success, Data: r.Data}
+	details, err := e.details(appID)
+	if err == nil || api.From(err).Kind == api.KindNotFound {
+		if werr := writeSteamDetails(appID, e); werr != nil {
+			slog.WarnContext(ctx, "steam app details not cached", "appid", appID, "err", werr)
+		}
+	}
+	return details, err
+}
+
+func (e *steamDetailsEntry) details(appID int) (*steamapidata.SteamAppData, error) {
+	if !e.Found {
+		return nil, api.Errorf(api.KindNotFound, "no store page for app %d", appID)
+	}
+	var d steamapidata.SteamAppData
+	if err := json.Unmarshal(e.Data, &d); err != nil {
+		return nil, api.Errorf(api.KindUpstream, "store details for app %d: %v", appID, err)
+	}
+	return &d, nil
+}
+
+func steamDetailsFile(appID int) string {
+	return filepath.Join(steamDetailsDir, strconv.Itoa(appID)+".json")
+}
+
+// readSteamDetails reads an app's cache file, falling back to the undated
+// steamdata/<appid>.json files steamapidata used to write, aged by mtime.
+func readSteamDetails(appID int) (steamDetailsEntry, error) {
+	var e steamDetailsEntry
+	file, err := os.ReadFile(steamDetailsFile(appID))
+	if err == nil {
+		err = json.Unmarshal(file, &e)
+		return e, err
+	}
+	legacy := fmt.Sprintf("steamdata/%d.json", appID)
+	info, lerr := os.Stat(legacy)
+	if lerr != nil {
+		return e, err
+	}
+	if file, lerr = os.ReadFile(legacy); lerr != nil {
+		return e, lerr
+	}
+	var resp map[string]struct {
+		Success bool            `json:"success"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if lerr = json.Unmarshal(file, &resp); lerr != nil {
+		return e, lerr
+	}
+	r := resp[strconv.Itoa(appID)]
+	return steamDetailsEntry{FetchedAt: info.ModTime(), Found: r.Success, Data: r.Data}, nil
+}
+
+func writeSteamDetails(appID int, e steamDetailsEntry) error {
+	if err := os.MkdirAll(steamDetailsDir, 0755); err != nil {
+		return err
+	}
+	file, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return atomicfile.Write(steamDetailsFile(appID), file, 0644)
+}
+
+// upstreamLimiter is a token bucket for calls we make, shaped like the
+// inbound rate limit rules. A zero PerMinute leaves calls unpaced.
+type upstreamLimiter struct {
+	mu   sync.Mutex
+	rule rateLimitRule
+	b    bucket
+}
+
+func (u *upstreamLimiter) apply(rule rateLimitRule) {
+	u.mu.Lock()
+	u.rule = rule
+	u.mu.Unlock()
+}
+
+// wait takes a token, sleeping until one is free. It gives up straight away
+// when ctx would end first, returning how long the caller would have waited.
+func (u *upstreamLimiter) wait(ctx context.Context) (time.Duration, error) {
+	for {
+		u.mu.Lock()
+		rule, now := u.rule, time.Now()
+		if rule.PerMinute <= 0 {
+			u.mu.Unlock()
+			return 0, nil
+		}
+		perSec := float64(rule.PerMinute) / 60
+		if u.b.last.IsZero() {
+			u.b.tokens = rule.capacity()
+		} else {
+			u.b.tokens = math.Min(rule.capacity(), u.b.tokens+now.Sub(u.b.last).Seconds()*perSec)
+		}
+		u.b.last = now
+		if u.b.tokens >= 1 {
+			u.b.tokens--
+			u.mu.Unlock()
+			return 0, nil
+		}
+		d := time.Duration((1 - u.b.tokens) / perSec * float64(time.Second))
+		u.mu.Unlock()
+
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+			return d, context.DeadlineExceeded
+		}
+		select {
+		case <-ctx.Done():
+			return d, ctx.Err()
+		case <-time.After(d):
+		}
+	}
+}
+
+// steamAppIDParam parses an app id from a query parameter.
+func steamAppIDParam(raw string) (int, error) {
+	id, err := strconv.Atoi(raw)
+	if err != nil || id <= 0 {
+		return 0, api.New(api.KindInvalidInput, fmt.Sprintf("appid %q is not a positive whole number", raw), "")
+	}
+	return id, nil
+}
+
+// steamAppSummary is the /steam/v1/appdata view of an app's store details.
+func steamAppSummary(d *steamapidata.SteamAppData) gin.H {
+	return gin.H{
+		"appid":            d.SteamAppid,
+		"storeurl":         "https://store.steampowered.com/app/" + strconv.Itoa(d.SteamAppid) + "/",
+		"price":            d.PriceOverview,
+		"name":             d.Name,
+		"type":             d.Type,
+		"free":             d.IsFree,
+		"dlc":              d.Dlc,
+		"shortdescription": d.ShortDescription,
+		"headerimage:":     d.HeaderImage,
+		"capsuleimagev5":   d.CapsuleImagev5,
+		"releasedate":      d.ReleaseDate.Date,
+		"genres":           d.Genres,
+		"tags":             d.Categories,
+		"metacritic":       d.Metacritic,
+		"developers":       d.Developers,
+		"publishers":       d.Publishers,
+		"website":          d.Website,
+	}
+}
+
+type steamBatchResult struct {
+	AppID  int        `json:"appid"`
+	Cached bool       `json:"cached"`
+	Data   gin.H      `json:"data,omitempty"`
+	Error  *api.Error `json:"error,omitempty"`
+}
+
+// steamAppDataBatchHandler looks up to steamBatchMax apps at once. Each app
+// succeeds or fails on its own; the batch only fails on a bad request.
+func steamAppDataBatchHandler(c *gin.Context) {
+	var req struct {
+		AppIDs []int `json:"appids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		api.Fail(c, api.New(api.KindInvalidInput, "malformed request", `Send {"appids":[440,570]}.`))
+		return
+	}
+	var ids []int
+	seen := map[int]bool{}
+	for _, id := range req.AppIDs {
+		if id <= 0 {
+			api.Fail(c, api.Errorf(api.KindInvalidInput, "appid %d is not a positive whole number", id))
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	switch {
+	case len(ids) == 0:
+		api.Fail(c, api.New(api.KindInvalidInput, "appids is required", `Send {"appids":[440,570]}.`))
+		return
+	case len(ids) > steamBatchMax:
+		api.Fail(c, api.Errorf(api.KindInvalidInput, "at most %d appids per batch", steamBatchMax))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), steamBatchTimeout)
+	defer cancel()
+	results := make([]steamBatchResult, len(ids))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(steamBatchWorkers, len(ids)) {
+		wg.Go(func() {
+			for i := range next {
+				r := steamBatchResult{AppID: ids[i]}
+				details, cached, err := steamDetails.get(ctx, ids[i], true)
+				if err != nil {
+					r.Error = api.From(err)
+				} else {
+					r.Cached, r.Data = cached, steamAppSummary(details)
+				}
+				results[i] = r
+			}
+		})
+	}
+	for i := range ids {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	var hits, failed int
+	for _, r := range results {
+		switch {
+		case r.Error != nil:
+			failed++
+		case r.Cached:
+			hits++
+		}
+	}
+	api.OK(c, gin.H{
+		"results": results,
+		"cached":  hits,
+		"fetched": len(results) - hits - failed,
+		"failed":  failed,
+	})
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"earapi/api"
+	"earapi/atomicfile"
+)
+
+// steamApps is the app list behind /steam/v1/search.
+var steamApps = &steamAppCache{}
+
+// steamAppCache holds the app list in memory, backed by steamGamesCacheFile.
+// A refresh swaps in a new list rather than editing the current one, so
+// readers never need the lock for longer than it takes to copy the pointer.
+type steamAppCache struct {
+	mu       sync.RWMutex
+	apps     *steamSearchAppList
+	index    *steamIndex   // built from apps whenever they change
+	interval time.Duration // 0 disables scheduled refreshes
+	last     steamRefresh
+
+	refreshing sync.Mutex // held for the whole of a refresh
+}
+
+// steamRefresh is the outcome of the most recent refresh attempt.
+type steamRefresh struct {
+	At    time.Time `json:"at"`
+	Full  bool      `json:"full"`
+	Added int       `json:"added"`
+	Error string    `json:"error,omitempty"`
+}
+
+// apply swaps in the refresh schedule from cfg.
+func (s *steamAppCache) apply(cfg earapiSettings) {
+	minutes := cfg.Steam.AppListRefreshMinutes
+	if minutes == 0 {
+		minutes = 360
+	}
+	s.mu.Lock()
+	s.interval = time.Duration(max(minutes, 0)) * time.Minute
+	s.mu.Unlock()
+}
+
+// list returns the cached app list, reading the cache file on first use and
+// fetching the whole catalogue when there is no usable file.
+func (s *steamAppCache) list(apiKey string) (*steamSearchAppList, error) {
+	s.mu.RLock()
+	apps := s.apps
+	s.mu.RUnlock()
+	if apps != nil {
+		return apps, nil
+	}
+
+	s.refreshing.Lock()
+	defer s.refreshing.Unlock()
+	if apps := s.current(); apps != nil {
+		return apps, nil // loaded while we waited
+	}
+	if apps, err := readSteamAppList(); err == nil {
+		s.set(apps)
+		return apps, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("steam app list cache unreadable, fetching it again", "path", steamGamesCacheFile, "err", err)
+	}
+	if _, err := s.refreshLocked(context.Background(), apiKey, true); err != nil {
+		return nil, err
+	}
+	return s.current(), nil
+}
+
+// set swaps in a new list along with its search index.
+func (s *steamAppCache) set(list *steamSearchAppList) {
+	idx := buildSteamIndex(list)
+	s.mu.Lock()
+	s.apps, s.index = list, idx
+	s.mu.Unlock()
+}
+
+// searchIndex returns the index over the cached list, loading it first if
+// need be.
+func (s *steamAppCache) searchIndex(apiKey string) (*steamIndex, error) {
+	if _, err := s.list(apiKey); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.index, nil
+}
+
+func (s *steamAppCache) current() *steamSearchAppList {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.apps
+}
+
+// name returns an app's name from the cached list, or "" when the list isn't
+// loaded or doesn't have it.
+func (s *steamAppCache) name(appID int) string {
+	s.mu.RLock()
+	idx := s.index
+	s.mu.RUnlock()
+	if idx == nil {
+		return ""
+	}
+	if i, ok := idx.byID[appID]; ok {
+		return idx.apps[i].Name
+	}
+	return ""
+}
+
+// errRefreshRunning is returned by tryRefresh while another refresh holds the cache.
+var errRefreshRunning = errors.New("a refresh is already running")
+
+// tryRefresh refreshes unless another refresh is in progress.
+func (s *steamAppCache) tryRefresh(ctx context.Context, apiKey string, full bool) (steamRefresh, error) {
+	if !s.refreshing.TryLock() {
+		return steamRefresh{}, errRefreshRunning
+	}
+	defer s.refreshing.Unlock()
+	if s.current() == nil && !full {
+		if apps, err := readSteamAppList(); err == nil {
+			s.set(apps)
+		}
+	}
+	return s.refreshLocked(ctx, apiKey, full)
+}
+
+// refreshLocked fetches apps newer than the cached last_appid, or the whole
+// catalogue when full is set or the cache is missing or from an older
+// version, and writes the merged list back to disk. The caller holds
+// s.refreshing.
+func (s *steamAppCache) refreshLocked(ctx context.Context, apiKey string, full bool) (steamRefresh, error) {
+	old := s.current()
+	full = full || old == nil || old.Version < steamAppListVersion
+	var after uint32
+	if !full {
+		after = old.LastAppID
+	}
+
+	r := steamRefresh{At: time.Now().UTC(), Full: full}
+	fetched, last, err := fetchSteamStoreAppList(ctx, apiKey, after)
+	if err == nil {
+		next := &steamSearchAppList{Version: steamAppListVersion, FetchedAt: r.At, LastAppID: last, Apps: fetched}
+		if !full {
+			next.Apps, r.Added = mergeSteamApps(old.Apps, fetched)
+			next.LastAppID = max(last, old.LastAppID)
+		} else {
+			slices.SortFunc(next.Apps, func(a, b steamSearchApp) int { return cmp.Compare(a.AppID, b.AppID) })
+			r.Added = len(fetched)
+		}
+		if err = writeSteamAppList(next); err == nil {
+			s.set(next)
+		}
+	}
+	if err != nil {
+		r.Error = err.Error()
+		slog.Warn("steam app list refresh failed", "full", full, "err", err)
+	} else {
+		slog.Info("steam app list refreshed", "full", full, "added", r.Added, "apps", len(s.current().Apps))
+	}
+	s.mu.Lock()
+	s.last = r
+	s.mu.Unlock()
+	return r, err
+}
+
+// mergeSteamApps adds the apps in fresh to base, replacing entries with the
+// same id, and returns the result sorted by id with the number of new ids.
+func mergeSteamApps(base, fresh []steamSearchApp) ([]steamSearchApp, int) {
+	byID := make(map[int]int, len(base))
+	out := slices.Clone(base)
+	for i, a := range out {
+		byID[a.AppID] = i
+	}
+	added := 0
+	for _, a := range fresh {
+		if i, ok := byID[a.AppID]; ok {
+			out[i] = a
+			continue
+		}
+		byID[a.AppID] = len(out)
+		out = append(out, a)
+		added++
+	}
+	slices.SortFunc(out, func(a, b steamSearchApp) int { return cmp.Compare(a.AppID, b.AppID) })
+	return out, added
+}
+
+// due reports whether a scheduled refresh should run now: the list is older
+// than the interval, or from an older version, and the last attempt, failed
+// or not, is older than the interval too.
+func (s *steamAppCache) due(now time.Time) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.interval <= 0 || now.Sub(s.last.At) < s.interval {
+		return false
+	}
+	return s.apps == nil || s.apps.Version < steamAppListVersion || now.Sub(s.apps.FetchedAt) >= s.interval
+}
+
+// run refreshes the list on schedule until ctx ends. It checks once a minute
+// so a reloaded interval takes effect without restarting the loop.
+func (s *steamAppCache) run(ctx context.Context, apiKey string) {
+	if apiKey == "" {
+		slog.Info("steam app list refresh off: no steam API key")
+		return
+	}
+	if s.current() == nil {
+		if apps, err := readSteamAppList(); err == nil {
+			s.set(apps)
+		}
+	}
+	t := time.NewTicker(time.Minute)
+	defer t.Stop()
+	for {
+		if s.due(time.Now()) {
+			_, _ = s.tryRefresh(ctx, apiKey, false)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// status is what the admin endpoint reports.
+func (s *steamAppCache) status() gin.H {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := gin.H{
+		"file":                     steamGamesCacheFile,
+		"cached":                   s.apps != nil,
+		"refresh_interval_minutes": int(s.interval / time.Minute),
+	}
+	if s.apps != nil {
+		out["apps"] = len(s.apps.Apps)
+		out["version"] = s.apps.Version
+		out["last_appid"] = s.apps.LastAppID
+		if !s.apps.FetchedAt.IsZero() {
+			out["fetched_at"] = s.apps.FetchedAt
+			out["age_seconds"] = int(time.Since(s.apps.FetchedAt).Seconds())
+			if s.interval > 0 {
+				out["next_refresh_at"] = s.apps.FetchedAt.Add(s.interval)
+			}
+		}
+	}
+	if !s.last.At.IsZero() {
+		out["last_refresh"] = s.last
+	}
+	return out
+}
+
+func readSteamAppList() (*steamSearchAppList, error) {
+	file, err := os.ReadFile(steamGamesCacheFile)
+	if err != nil {
+		return nil, err
+	}
+	list := &steamSearchAppList{}
+	if err := json.Unmarshal(file, list); err != nil {
+		return nil, err
+	}
+	if len(list.Apps) == 0 {
+		return nil, fmt.Errorf("%s has no apps", steamGamesCacheFile)
+	}
+	if list.LastAppID == 0 { // written before last_appid was recorded
+		for _, a := range list.Apps {
+			list.LastAppID = max(list.LastAppID, uint32(a.AppID))
+		}
+	}
+	return list, nil
+}
+
+func writeSteamAppList(list *steamSearchAppList) error {
+	file, err := json.MarshalIndent(list, "", " ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.Write(steamGamesCacheFile, file, 0644)
+}
+
+func steamAppListStatusHandler(c *gin.Context) {
+	api.OK(c, steamApps.status())
+}
+
+// steamAppListRefreshHandler runs a refresh now: a delta by default, the
+// whole catalogue with full=true.
+func steamAppListRefreshHandler(c *gin.Context) {
+	if currentConfig().Apikeys.Steamapikey == "" {
+		api.Fail(c, api.New(api.KindUnavailable, "steam API key is not configured",
+			"Set apikeys.steamapikey (or EARAPI_APIKEYS_STEAMAPIKEY)."))
+		return
+	}
+	_, err := steamApps.tryRefresh(c.Request.Context(), currentConfig().Apikeys.Steamapikey, queryBool(c, "full"))
+	switch {
+	case errors.Is(err, errRefreshRunning):
+		api.Fail(c, api.New(api.KindConflict, err.Error(), "Watch last_refresh on GET /steam/v1/admin/applist."))
+	case err != nil:
+		api.Fail(c, api.New(api.KindUpstream, err.Error(), ""))
+	default:
+		api.OK(c, steamApps.status())
+	}
+}
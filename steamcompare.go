@@ -0,0 +1,219 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"earapi/api"
+	"earapi/compare"
+)
+
+const (
+	steamCompareMaxUsers = 8
+	steamHeavyMinutes    = 600 // playtime that counts as "played heavily"
+)
+
+// steamCompareResult is /steam/v1/compare: the compare package's common /
+// unique / partial split over Steam libraries, keyed by SteamID64, plus the
+// games some of the group own but never started while another sank hours in.
+type steamCompareResult struct {
+	Users   []steamCompareUser             `json:"users"`
+	Common  []steamCompareEntry            `json:"common"`  // owned by everyone
+	Unique  map[string][]steamCompareEntry `json:"unique"`  // owned by exactly one, by steamid
+	Partial []steamCompareEntry            `json:"partial"` // owned by some, not all
+	Untried []steamCompareEntry            `json:"untried"` // unplayed by an owner, heavily played by another
+	Stats   compare.Stats                  `json:"stats"`
+}
+
+type steamCompareUser struct {
+	SteamID         string `json:"steamid"`
+	Input           string `json:"input"` // as the request named them
+	Games           int    `json:"games"`
+	PlaytimeMinutes int    `json:"playtime_minutes"`
+}
+
+// steamCompareEntry is one game and what each owner has played of it.
+type steamCompareEntry struct {
+	AppID      int            `json:"appid"`
+	Name       string         `json:"name"`
+	Owners     []string       `json:"owners"`
+	Playtime   map[string]int `json:"playtime_minutes"` // by owner
+	UnplayedBy []string       `json:"unplayed_by,omitempty"`
+}
+
+func (e *steamCompareEntry) mostPlayed() int {
+	most := 0
+	for _, m := range e.Playtime {
+		most = max(most, m)
+	}
+	return most
+}
+
+// compareSteamLibraries splits libs, keyed by the owners in order, into the
+// compare package's buckets. heavy is the playtime that makes an owner's
+// game worth recommending to the others who own it.
+func compareSteamLibraries(owners []string, libs map[string][]steamOwnedGame, heavy int) steamCompareResult {
+	res := steamCompareResult{
+		Common:  []steamCompareEntry{},
+		Unique:  map[string][]steamCompareEntry{},
+		Partial: []steamCompareEntry{},
+		Untried: []steamCompareEntry{},
+		Stats: compare.Stats{
+			ListCount:   len(owners),
+			PerOwner:    map[string]int{},
+			UniqueCount: map[string]int{},
+		},
+	}
+
+	sets := make(map[string]map[int]bool, len(owners))
+	entries := map[int]*steamCompareEntry{}
+	for _, o := range owners {
+		set := make(map[int]bool, len(libs[o]))
+		for _, g := range libs[o] {
+			if set[g.AppID] {
+				continue
+			}
+			set[g.AppID] = true
+			e, ok := entries[g.AppID]
+			if !ok {
+				e = &steamCompareEntry{AppID: g.AppID, Name: g.Name, Playtime: map[string]int{}}
+				entries[g.AppID] = e
+			}
+			e.Owners = append(e.Owners, o) // owners are walked in order
+			e.Playtime[o] = g.PlaytimeForever
+		}
+		sets[o] = set
+		res.Stats.PerOwner[o] = len(set)
+	}
+
+	all := make([]*steamCompareEntry, 0, len(entries))
+	for _, e := range entries {
+		all = append(all, e)
+	}
+	slices.SortFunc(all, func(a, b *steamCompareEntry) int {
+		return cmp.Or(cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)), cmp.Compare(a.AppID, b.AppID))
+	})
+
+	for _, e := range all {
+		switch {
+		case len(e.Owners) == len(owners):
+			res.Common = append(res.Common, *e)
+		case len(e.Owners) == 1:
+			res.Unique[e.Owners[0]] = append(res.Unique[e.Owners[0]], *e)
+		default:
+			res.Partial = append(res.Partial, *e)
+		}
+		if len(e.Owners) < 2 {
+			continue
+		}
+		var unplayed []string
+		for _, o := range e.Owners {
+			if e.Playtime[o] == 0 {
+				unplayed = append(unplayed, o)
+			}
+		}
+		if len(unplayed) > 0 && e.mostPlayed() >= heavy {
+			u := *e
+			u.UnplayedBy = unplayed
+			res.Untried = append(res.Untried, u)
+		}
+	}
+	// The most-played first: the likeliest to be worth the others' time.
+	slices.SortStableFunc(res.Untried, func(a, b steamCompareEntry) int {
+		return cmp.Compare(b.mostPlayed(), a.mostPlayed())
+	})
+
+	for _, o := range owners {
+		if _, ok := res.Unique[o]; !ok {
+			res.Unique[o] = []steamCompareEntry{}
+		}
+		res.Stats.UniqueCount[o] = len(res.Unique[o])
+	}
+	res.Stats.CommonCount = len(res.Common)
+	res.Stats.PartialCnt = len(res.Partial)
+	res.Stats.UnionCount = len(all)
+	res.Stats.Pairwise = compare.Pairwise(owners, sets)
+	return res
+}
+
+// steamCompareHandler compares the libraries of two or more Steam users.
+func steamCompareHandler(c *gin.Context) {
+	var req struct {
+		Users        []string `json:"users"`
+		HeavyMinutes int      `json:"heavy_minutes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		api.Fail(c, api.New(api.KindInvalidInput, "malformed request", `Send {"users":["76561198011985757","gaben"]}.`))
+		return
+	}
+	if req.HeavyMinutes < 0 {
+		api.Fail(c, api.New(api.KindInvalidInput, "heavy_minutes cannot be negative", ""))
+		return
+	}
+	heavy := cmp.Or(req.HeavyMinutes, steamHeavyMinutes)
+	if len(req.Users) > steamCompareMaxUsers {
+		api.Fail(c, api.Errorf(api.KindInvalidInput, "comparing needs 2 to %d different users", steamCompareMaxUsers))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), steamBatchTimeout)
+	defer cancel()
+	apiKey := currentConfig().Apikeys.Steamapikey
+
+	var users []steamCompareUser
+	for _, in := range req.Users {
+		id, err := resolveSteamUser(ctx, apiKey, in)
+		if err != nil {
+			api.Fail(c, err)
+			return
+		}
+		if !slices.ContainsFunc(users, func(u steamCompareUser) bool { return u.SteamID == id }) {
+			users = append(users, steamCompareUser{SteamID: id, Input: in})
+		}
+	}
+	if len(users) < 2 {
+		api.Fail(c, api.Errorf(api.KindInvalidInput, "comparing needs 2 to %d different users", steamCompareMaxUsers))
+		return
+	}
+
+	libs := make([][]steamOwnedGame, len(users))
+	errs := make([]error, len(users))
+	var wg sync.WaitGroup
+	for i, u := range users {
+		wg.Go(func() { libs[i], errs[i] = fetchSteamOwnedGames(ctx, apiKey, u.SteamID) })
+	}
+	wg.Wait()
+
+	owners := make([]string, len(users))
+	byOwner := make(map[string][]steamOwnedGame, len(users))
+	for i, u := range users {
+		if errs[i] != nil {
+			api.Fail(c, steamCompareErr(u.SteamID, errs[i]))
+			return
+		}
+		owners[i] = u.SteamID
+		byOwner[u.SteamID] = libs[i]
+		users[i].Games = len(libs[i])
+		for _, g := range libs[i] {
+			users[i].PlaytimeMinutes += g.PlaytimeForever
+		}
+	}
+
+	res := compareSteamLibraries(owners, byOwner, heavy)
+	res.Users = users
+	api.OK(c, res)
+}
+
+// steamCompareErr says which of the users a library fetch failed for.
+func steamCompareErr(steamID string, err error) error {
+	e := api.From(err)
+	if strings.Contains(e.Message, steamID) {
+		return e
+	}
+	return api.New(e.Kind, steamID+": "+e.Message, e.Hint)
+}
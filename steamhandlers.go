@@ -1,135 +1,139 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"net/http"
+	"log/slog"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/earentir/steamapidata"
 	"github.com/gin-gonic/gin"
+
+	"earapi/api"
+	"earapi/metrics"
 )
 
+// steamUserIDHandler resolves any form of Steam user to its SteamID64 and
+// the other ID formats.
 func steamUserIDHandler(c *gin.Context) {
-	usernameToLookup := c.DefaultQuery("username", "earentir")
-	fmt.Println("username provided", usernameToLookup)
-	steamID, _, err := steamapidata.GetSteamID(config.Apikeys.Steamapikey, usernameToLookup)
+	input := c.Query("username")
+	slog.DebugContext(c.Request.Context(), "resolving steam user", "username", input)
+	steamID, err := steamUserParam(c, "username")
 	if err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"success": false,
-			"msg":     err.Error(),
-		})
-	} else {
-		c.JSON(http.StatusOK, gin.H{
-			"success": true,
-			"msg":     "",
-			"data":    gin.H{"steamID": steamID},
-		})
+		api.Fail(c, err)
+		return
 	}
+	out := steamIDForms(steamID)
+	out["steamID"] = steamID
+	api.OK(c, out)
 }
 
 func steamAppDataHandler(c *gin.Context) {
-	appIDStr := c.DefaultQuery("appid", "1086940")
-
-	appID, err := strconv.Atoi(appIDStr)
+	appID, err := steamAppIDParam(c.DefaultQuery("appid", "1086940"))
 	if err != nil {
-		fmt.Println("appid needs to be an int")
+		api.Fail(c, err)
+		return
 	}
 
-	gameDetails, err := steamapidata.SteamAppDetails(appID)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), steamSingleTimeout)
+	defer cancel()
+	gameDetails, _, err := steamDetails.get(ctx, appID, true)
 	if err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusOK, gin.H{
-			"success": false,
-			"msg":     err.Error(),
-		})
-	} else {
-		c.JSON(http.StatusOK, gin.H{
-			"success": true,
-			"msg":     "",
-			"data": gin.H{
-				"appid":            gameDetails.SteamAppid,
-				"storeurl":         "https://store.steampowered.com/app/" + appIDStr + "/",
-				"price":            gameDetails.PriceOverview,
-				"name":             gameDetails.Name,
-				"type":             gameDetails.Type,
-				"free":             gameDetails.IsFree,
-				"dlc":              gameDetails.Dlc,
-				"shortdescription": gameDetails.ShortDescription,
-				"headerimage:":     gameDetails.HeaderImage,
-				"capsuleimagev5":   gameDetails.CapsuleImagev5,
-				"releasedate":      gameDetails.ReleaseDate.Date,
-				"genres":           gameDetails.Genres,
-				"tags":             gameDetails.Categories,
-				"metacritic":       gameDetails.Metacritic,
-				"developers":       gameDetails.Developers,
-				"publishers":       gameDetails.Publishers,
-				"website":          gameDetails.Website,
-			},
-		})
+		slog.WarnContext(c.Request.Context(), "steam app details failed", "appid", appID, "err", err)
+		api.Fail(c, err)
+		return
 	}
+	api.OK(c, steamAppSummary(gameDetails))
 }
 
 func steamUserAppsUsedHandler(c *gin.Context) {
-	userID := c.DefaultQuery("userid", "76561198011985757")
+	userID, err := steamUserParam(c, "userid")
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
 
-	games, err := steamapidata.SteamUserAppsUsed(config.Apikeys.Steamapikey, userID)
+	done := metrics.Upstream("steam", "SteamUserAppsUsed")
+	games, err := steamapidata.SteamUserAppsUsed(currentConfig().Apikeys.Steamapikey, userID)
+	done(metrics.Outcome(err))
 	if err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusOK, gin.H{
-			"success": false,
-			"msg":     err.Error(),
-		})
+		err = steamErr(err)
+		slog.WarnContext(c.Request.Context(), "steam apps used failed", "userid", userID, "err", err)
+		api.Fail(c, api.New(api.KindUpstream, err.Error(), ""))
 	} else {
-		c.JSON(http.StatusOK, gin.H{
-			"success": true,
-			"msg":     "",
-			"data":    games})
+		api.OK(c, games)
 	}
 }
 
 func steamTopHandler(c *gin.Context) {
-	userID := c.DefaultQuery("userid", "76561198011985757")
+	userID, err := steamUserParam(c, "userid")
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
 	topCount := c.DefaultQuery("count", "10")
 	sortOn := c.DefaultQuery("sortby", "playtime")
 
 	topCountInt, err := strconv.Atoi(topCount)
 	if err != nil {
-		fmt.Println("count needs to be an int")
+		slog.DebugContext(c.Request.Context(), "count needs to be an int", "count", topCount)
 	}
 
-	games, err := steamapidata.SteamUserAppsUsed(config.Apikeys.Steamapikey, userID)
+	done := metrics.Upstream("steam", "SteamUserAppsUsed")
+	games, err := steamapidata.SteamUserAppsUsed(currentConfig().Apikeys.Steamapikey, userID)
+	done(metrics.Outcome(err))
 	if err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusOK, gin.H{
-			"success": false,
-			"msg":     err.Error(),
-		})
+		err = steamErr(err)
+		slog.WarnContext(c.Request.Context(), "steam apps used failed", "userid", userID, "err", err)
+		api.Fail(c, api.New(api.KindUpstream, err.Error(), ""))
 	} else {
 		response := steamapidata.SortApps(games, sortOn, topCountInt)
 
-		c.JSON(http.StatusOK, gin.H{
-			"success": true,
-			"msg":     "",
-			"data":    response})
+		api.OK(c, response)
 	}
 }
 
+// searchSteamAppHandler ranks apps against app=. The v1 "app" field keeps
+// its old meaning for the top candidate: the app id for a name, the name for
+// an app id.
 func searchSteamAppHandler(c *gin.Context) {
 	app := c.DefaultQuery("app", "Baldur's Gate 3")
 
-	foundApp, err := searchSteamApp(config.Apikeys.Steamapikey, app)
+	opts, err := parseSteamSearchOptions(c)
 	if err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusOK, gin.H{
-			"success": false,
-			"msg":     err.Error(),
-		})
-	} else {
-		c.JSON(http.StatusOK, gin.H{
-			"success": true,
-			"msg":     "",
-			"data":    gin.H{"app": foundApp},
-		})
+		api.Fail(c, err)
+		return
+	}
+	found, err := searchSteamApp(currentConfig().Apikeys.Steamapikey, app, opts)
+	if err != nil {
+		slog.WarnContext(c.Request.Context(), "steam app search failed", "app", app, "err", err)
+		api.Fail(c, err)
+		return
+	}
+	top := strconv.Itoa(found[0].AppID)
+	if found[0].Match == steamMatchID {
+		top = found[0].Name
 	}
+	api.OK(c, gin.H{"app": top, "candidates": found})
+}
 
+func parseSteamSearchOptions(c *gin.Context) (steamSearchOptions, error) {
+	var opts steamSearchOptions
+	var err error
+	if opts.Limit, err = queryInt(c, "limit", 10, 1, 50); err != nil {
+		return opts, err
+	}
+	for t := range strings.SplitSeq(c.Query("type"), ",") {
+		if t = strings.TrimSpace(strings.ToLower(t)); t == "" {
+			continue
+		}
+		if !slices.ContainsFunc(steamAppTypes, func(k steamAppType) bool { return k.name == t }) {
+			return opts, api.New(api.KindInvalidInput, fmt.Sprintf("unknown app type %q", t),
+				"Use game, dlc, software, video or hardware; separate several with commas.")
+		}
+		opts.Types = append(opts.Types, t)
+	}
+	return opts, nil
 }
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"earapi/api"
+)
+
+// steamID64Base is the SteamID64 of individual account 0; an account id is
+// added to it.
+const steamID64Base = 76561197960265728
+
+const steamUserHint = "Pass a SteamID64, STEAM_1:Y:Z, [U:1:N], a custom URL name, or a steamcommunity.com/id/… or /profiles/… link."
+
+var (
+	steam2Pattern  = regexp.MustCompile(`(?i)^STEAM_[0-5]:([01]):(\d+)$`)
+	steam3Pattern  = regexp.MustCompile(`(?i)^\[?U:1:(\d+)\]?$`)
+	vanityPattern  = regexp.MustCompile(`^[A-Za-z0-9_-]{2,32}$`)
+	steamUserHosts = map[string]bool{"steamcommunity.com": true, "www.steamcommunity.com": true}
+)
+
+// steamID64 parses s as a SteamID64 of an individual account.
+func steamID64(s string) (uint64, bool) {
+	if len(s) != 17 {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || n < steamID64Base || n-steamID64Base > 1<<32-1 {
+		return 0, false
+	}
+	return n, true
+}
+
+func validSteamID(id string) bool {
+	_, ok := steamID64(id)
+	return ok
+}
+
+// parseSteamUser normalises what users paste for a Steam account. It returns
+// the SteamID64, or failing that the custom URL name still to be resolved.
+func parseSteamUser(input string) (id64, vanity string, err error) {
+	s := strings.TrimSpace(input)
+	if s == "" {
+		return "", "", api.New(api.KindInvalidInput, "a Steam user is required", steamUserHint)
+	}
+
+	if strings.Contains(s, "/") {
+		raw := s
+		if !strings.Contains(raw, "://") {
+			raw = "https://" + raw
+		}
+		u, err := url.Parse(raw)
+		if err != nil || !steamUserHosts[strings.ToLower(u.Hostname())] {
+			return "", "", api.New(api.KindInvalidInput, fmt.Sprintf("%q is not a steamcommunity.com profile link", input), steamUserHint)
+		}
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		if len(parts) < 2 {
+			return "", "", api.New(api.KindInvalidInput, fmt.Sprintf("%q is not a steamcommunity.com profile link", input), steamUserHint)
+		}
+		switch parts[0] {
+		case "profiles":
+			s = parts[1]
+			if _, ok := steamID64(s); !ok {
+				return "", "", api.New(api.KindInvalidInput, fmt.Sprintf("%q is not a valid profile id", s), steamUserHint)
+			}
+		case "id":
+			s = parts[1]
+			if !vanityPattern.MatchString(s) {
+				return "", "", api.New(api.KindInvalidInput, fmt.Sprintf("%q is not a valid custom URL name", s), steamUserHint)
+			}
+			return "", s, nil
+		default:
+			return "", "", api.New(api.KindInvalidInput, fmt.Sprintf("%q is not a steamcommunity.com profile link", input), steamUserHint)
+		}
+	}
+
+	if _, ok := steamID64(s); ok {
+		return s, "", nil
+	}
+	if m := steam2Pattern.FindStringSubmatch(s); m != nil {
+		y, _ := strconv.ParseUint(m[1], 10, 64)
+		z, err := strconv.ParseUint(m[2], 10, 64)
+		if err != nil || z > 1<<31-1 {
+			return "", "", api.New(api.KindInvalidInput, fmt.Sprintf("%q is out of range", input), steamUserHint)
+		}
+		return strconv.FormatUint(steamID64Base+z*2+y, 10), "", nil
+	}
+	if m := steam3Pattern.FindStringSubmatch(s); m != nil {
+		n, err := strconv.ParseUint(m[1], 10, 64)
+		if err != nil || n > 1<<32-1 {
+			return "", "", api.New(api.KindInvalidInput, fmt.Sprintf("%q is out of range", input), steamUserHint)
+		}
+		return strconv.FormatUint(steamID64Base+n, 10), "", nil
+	}
+	if vanityPattern.MatchString(s) {
+		return "", s, nil
+	}
+	return "", "", api.New(api.KindInvalidInput, fmt.Sprintf("%q is not a Steam user", input), steamUserHint)
+}
+
+// steamVanity caches custom URL lookups. Misses are kept too, for less time,
+// so a typo isn't sent to Steam on every retry.
+var steamVanity = &steamVanityCache{entries: map[string]steamVanityEntry{}}
+
+const (
+	steamVanityTTL     = 24 * time.Hour
+	steamVanityMissTTL = 10 * time.Minute
+	steamVanityMax     = 10000
+)
+
+type steamVanityCache struct {
+	mu      sync.Mutex
+	entries map[string]steamVanityEntry
+}
+
+type steamVanityEntry struct {
+	id      string // empty for a miss
+	expires time.Time
+}
+
+func (v *steamVanityCache) get(name string) (steamVanityEntry, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	e, ok := v.entries[name]
+	if !ok || time.Now().After(e.expires) {
+		return steamVanityEntry{}, false
+	}
+	return e, true
+}
+
+func (v *steamVanityCache) put(name, id string) {
+	ttl := steamVanityTTL
+	if id == "" {
+		ttl = steamVanityMissTTL
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(v.entries) >= steamVanityMax {
+		now := time.Now()
+		for k, e := range v.entries {
+			if now.After(e.expires) {
+				delete(v.entries, k)
+			}
+		}
+		if len(v.entries) >= steamVanityMax {
+			clear(v.entries)
+		}
+	}
+	v.entries[name] = steamVanityEntry{id: id, expires: time.Now().Add(ttl)}
+}
+
+// resolveSteamUser turns any form parseSteamUser accepts into a SteamID64,
+// asking ISteamUser/ResolveVanityURL for custom URL names.
+func resolveSteamUser(ctx context.Context, apiKey, input string) (string, error) {
+	id, vanity, err := parseSteamUser(input)
+	if err != nil {
+		return "", badSteamUser(err)
+	}
+	if id != "" {
+		return id, nil
+	}
+
+	key := strings.ToLower(vanity) // custom URLs are case-insensitive
+	if e, ok := steamVanity.get(key); ok {
+		if e.id == "" {
+			return "", steamVanityNotFound(vanity)
+		}
+		return e.id, nil
+	}
+	if apiKey == "" {
+		return "", api.New(api.KindUnavailable, "steam API key is not configured",
+			"Set apikeys.steamapikey (or EARAPI_APIKEYS_STEAMAPIKEY), or pass a SteamID64.")
+	}
+
+	body, err := steamGet(ctx, "ResolveVanityURL", fmt.Sprintf(
+		"https://api.steampowered.com/ISteamUser/ResolveVanityURL/v1/?key=%s&vanityurl=%s",
+		apiKey,
+		url.QueryEscape(vanity),
+	))
+	if err != nil {
+		return "", api.New(api.KindUpstream, "steam vanity URL lookup failed: "+err.Error(), "")
+	}
+	var resp struct {
+		Response struct {
+			SteamID string `json:"steamid"`
+			Success int    `json:"success"` // 1 found, 42 no match
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", api.New(api.KindUpstream, "steam vanity URL response: "+err.Error(), "")
+	}
+	if resp.Response.Success != 1 || !validSteamID(resp.Response.SteamID) {
+		steamVanity.put(key, "")
+		return "", steamVanityNotFound(vanity)
+	}
+	steamVanity.put(key, resp.Response.SteamID)
+	return resp.Response.SteamID, nil
+}
+
+func steamVanityNotFound(vanity string) error {
+	return badSteamUser(api.New(api.KindNotFound, fmt.Sprintf("no Steam profile at steamcommunity.com/id/%s", vanity),
+		"Check the custom URL on the profile page, or pass the SteamID64."))
+}
+
+// badSteamUser marks a user that doesn't resolve as the caller's mistake: a
+// 400 in v1, where the steam group otherwise answers failures with HTTP 200.
+func badSteamUser(err error) error {
+	return api.From(err).WithV1Status(http.StatusBadRequest)
+}
+
+// steamUserParam resolves the Steam user named by the query parameter. It is
+// required: no endpoint falls back to a default account.
+func steamUserParam(c *gin.Context, name string) (string, error) {
+	v := c.Query(name)
+	if strings.TrimSpace(v) == "" {
+		return "", badSteamUser(api.New(api.KindInvalidInput, name+" is required", steamUserHint))
+	}
+	return resolveSteamUser(c.Request.Context(), currentConfig().Apikeys.Steamapikey, v)
+}
+
+// steamIDForms lists the other ways of writing id64, which must be valid.
+func steamIDForms(id64 string) gin.H {
+	n, _ := steamID64(id64)
+	account := n - steamID64Base
+	return gin.H{
+		"steam2":  fmt.Sprintf("STEAM_1:%d:%d", account&1, account>>1),
+		"steam3":  fmt.Sprintf("[U:1:%d]", account),
+		"profile": "https://steamcommunity.com/profiles/" + id64,
+	}
+}
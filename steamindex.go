@@ -0,0 +1,302 @@
+package main
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Match types, best first. A candidate reports the best way it matched.
+const (
+	steamMatchID     = "id"     // the query is the app id
+	steamMatchExact  = "exact"  // same name once case and punctuation are dropped
+	steamMatchPrefix = "prefix" // the name starts with the query
+	steamMatchToken  = "token"  // every query word is a word of the name; the last may be a prefix
+	steamMatchEdit   = "edit"   // as token, but some words are misspelt
+)
+
+// Bounds that keep a short or common query from walking most of the index.
+const (
+	steamPrefixNames  = 2000 // names checked for a whole-name prefix match
+	steamPrefixTokens = 200  // vocabulary words a trailing partial word may expand to
+)
+
+type steamSearchOptions struct {
+	Limit int
+	Types []string // app types to keep; empty keeps all
+}
+
+type steamCandidate struct {
+	AppID int     `json:"appid"`
+	Name  string  `json:"name"`
+	Type  string  `json:"type,omitempty"`
+	Score float64 `json:"score"` // 0-1, comparable across match types
+	Match string  `json:"match"`
+}
+
+// steamIndex answers searches over one app list without scanning it: whole
+// names are looked up by key or by sorted prefix range, words through an
+// inverted index, and misspellings against the vocabulary bucketed by length.
+type steamIndex struct {
+	apps     []steamSearchApp
+	words    []uint16      // word count per app
+	byID     map[int]int32 // app id -> position in apps
+	exact    map[string][]int32
+	names    []steamNameKey // every app's key, sorted
+	postings map[string][]int32
+	vocab    []string         // every word, sorted
+	byLen    map[int][]string // vocabulary by rune count
+}
+
+type steamNameKey struct {
+	key string
+	app int32
+}
+
+// steamWords lower-cases s and splits it into runs of letters and digits.
+// Apostrophes join rather than split, so "Baldur's" is one word, "baldurs".
+func steamWords(s string) []string {
+	var words []string
+	var cur strings.Builder
+	flush := func() {
+		if cur.Len() > 0 {
+			words = append(words, cur.String())
+			cur.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			cur.WriteRune(r)
+		case r == '\'' || r == '’':
+		default:
+			flush()
+		}
+	}
+	flush()
+	return words
+}
+
+func buildSteamIndex(list *steamSearchAppList) *steamIndex {
+	x := &steamIndex{
+		apps:     list.Apps,
+		words:    make([]uint16, len(list.Apps)),
+		byID:     make(map[int]int32, len(list.Apps)),
+		exact:    make(map[string][]int32, len(list.Apps)),
+		names:    make([]steamNameKey, 0, len(list.Apps)),
+		postings: map[string][]int32{},
+		byLen:    map[int][]string{},
+	}
+	for i, app := range list.Apps {
+		id := int32(i)
+		x.byID[app.AppID] = id
+		words := steamWords(app.Name)
+		if len(words) == 0 {
+			continue
+		}
+		x.words[i] = uint16(min(len(words), math.MaxUint16))
+		key := strings.Join(words, "")
+		x.exact[key] = append(x.exact[key], id)
+		x.names = append(x.names, steamNameKey{key, id})
+		for _, w := range words {
+			p := x.postings[w]
+			if len(p) > 0 && p[len(p)-1] == id {
+				continue // repeated word in one name
+			}
+			x.postings[w] = append(p, id)
+		}
+	}
+	slices.SortFunc(x.names, func(a, b steamNameKey) int { return strings.Compare(a.key, b.key) })
+	x.vocab = make([]string, 0, len(x.postings))
+	for w := range x.postings {
+		x.vocab = append(x.vocab, w)
+		n := utf8.RuneCountInString(w)
+		x.byLen[n] = append(x.byLen[n], w)
+	}
+	slices.Sort(x.vocab)
+	return x
+}
+
+// steamMaxEdits is how many typos a query word of this length may carry.
+func steamMaxEdits(word string) int {
+	switch n := utf8.RuneCountInString(word); {
+	case n <= 3:
+		return 0
+	case n <= 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func (x *steamIndex) search(query string, opts steamSearchOptions) []steamCandidate {
+	best := map[int32]steamCandidate{}
+	add := func(i int32, score float64, match string) {
+		app := x.apps[i]
+		if len(opts.Types) > 0 && !slices.Contains(opts.Types, app.Type) {
+			return
+		}
+		if cur, ok := best[i]; ok && cur.Score >= score {
+			return
+		}
+		best[i] = steamCandidate{AppID: app.AppID, Name: app.Name, Type: app.Type, Score: score, Match: match}
+	}
+
+	if id, err := strconv.Atoi(strings.TrimSpace(query)); err == nil {
+		if i, ok := x.byID[id]; ok {
+			add(i, 1, steamMatchID)
+		}
+	}
+
+	words := steamWords(query)
+	if len(words) > 0 {
+		key := strings.Join(words, "")
+		for _, i := range x.exact[key] {
+			add(i, 1, steamMatchExact)
+		}
+		x.prefixMatches(key, add)
+		x.wordMatches(words, add)
+	}
+
+	out := make([]steamCandidate, 0, len(best))
+	for _, c := range best {
+		c.Score = math.Round(c.Score*1000) / 1000
+		out = append(out, c)
+	}
+	slices.SortFunc(out, func(a, b steamCandidate) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(len(a.Name), len(b.Name)); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.AppID, b.AppID)
+	})
+	if opts.Limit > 0 && len(out) > opts.Limit {
+		out = out[:opts.Limit]
+	}
+	return out
+}
+
+// prefixMatches adds names that start with key. The closer the name is to
+// the query in length, the higher it scores.
+func (x *steamIndex) prefixMatches(key string, add func(int32, float64, string)) {
+	lo := sort.Search(len(x.names), func(k int) bool { return x.names[k].key >= key })
+	for k := lo; k < len(x.names) && k-lo < steamPrefixNames; k++ {
+		n := x.names[k]
+		if !strings.HasPrefix(n.key, key) {
+			break
+		}
+		if n.key != key {
+			add(n.app, 0.8+0.15*float64(len(key))/float64(len(n.key)), steamMatchPrefix)
+		}
+	}
+}
+
+// wordMatches adds apps containing every query word, exactly, as a prefix
+// (the last word only, as the user may still be typing), or within
+// steamMaxEdits typos.
+func (x *steamIndex) wordMatches(words []string, add func(int32, float64, string)) {
+	// cost per app for each query word: 0 exact, 1 prefix, 1+n for n edits
+	costs := make([]map[int32]int, len(words))
+	for j, w := range words {
+		m := map[int32]int{}
+		mark := func(word string, cost int) {
+			for _, i := range x.postings[word] {
+				if c, ok := m[i]; !ok || cost < c {
+					m[i] = cost
+				}
+			}
+		}
+		mark(w, 0)
+		if j == len(words)-1 && utf8.RuneCountInString(w) >= 2 {
+			lo := sort.SearchStrings(x.vocab, w)
+			for k := lo; k < len(x.vocab) && k-lo < steamPrefixTokens && strings.HasPrefix(x.vocab[k], w); k++ {
+				if x.vocab[k] != w {
+					mark(x.vocab[k], 1)
+				}
+			}
+		}
+		if d := steamMaxEdits(w); d > 0 {
+			rw := []rune(w)
+			for n := len(rw) - d; n <= len(rw)+d; n++ {
+				for _, v := range x.byLen[n] {
+					if v == w {
+						continue
+					}
+					if e := editDistance(rw, []rune(v), d); e <= d {
+						mark(v, 1+e)
+					}
+				}
+			}
+		}
+		costs[j] = m
+	}
+
+	smallest := slices.MinFunc(costs, func(a, b map[int32]int) int { return cmp.Compare(len(a), len(b)) })
+	for i := range smallest {
+		edits, prefixes, ok := 0, 0, true
+		for _, m := range costs {
+			c, found := m[i]
+			if !found {
+				ok = false
+				break
+			}
+			switch {
+			case c == 1:
+				prefixes++
+			case c > 1:
+				edits += c - 1
+			}
+		}
+		if !ok {
+			continue
+		}
+		coverage := min(1, float64(len(words))/float64(max(x.words[i], 1)))
+		if edits == 0 {
+			add(i, 0.55+0.3*coverage-0.02*float64(prefixes), steamMatchToken)
+		} else {
+			add(i, max(0.01, 0.25+0.3*coverage-0.05*float64(edits)), steamMatchEdit)
+		}
+	}
+}
+
+// editDistance is the edit distance between a and b, counting a swap of
+// neighbouring letters as one edit, or limit+1 as soon as it is certain to
+// exceed limit.
+func editDistance(a, b []rune, limit int) int {
+	if d := len(a) - len(b); d > limit || -d > limit {
+		return limit + 1
+	}
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			sub := prev[j-1]
+			if a[i-1] != b[j-1] {
+				sub++
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, sub)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
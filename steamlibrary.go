@@ -0,0 +1,507 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"earapi/api"
+	"earapi/atomicfile"
+)
+
+// steamLibraryDir holds one snapshot history file per tracked steam id.
+const steamLibraryDir = "steamdata/library"
+
+// steamSnapshotKeep caps a history file: two years of daily snapshots.
+const steamSnapshotKeep = 730
+
+// steamOwnedGame is one entry of IPlayerService/GetOwnedGames. steamapidata's
+// AppsUsedInfo drops the two-week playtime, so the library endpoints fetch the
+// list themselves. Playtimes are in minutes.
+type steamOwnedGame struct {
+	AppID           int    `json:"appid"`
+	Name            string `json:"name"`
+	PlaytimeForever int    `json:"playtime_forever"`
+	Playtime2Weeks  int    `json:"playtime_2weeks,omitempty"`
+	RtimeLastPlayed int64  `json:"rtime_last_played"`
+}
+
+func fetchSteamOwnedGames(ctx context.Context, apiKey, steamID string) ([]steamOwnedGame, error) {
+	if apiKey == "" {
+		return nil, api.New(api.KindUnavailable, "steam API key is not configured",
+			"Set apikeys.steamapikey (or EARAPI_APIKEYS_STEAMAPIKEY).")
+	}
+	url := fmt.Sprintf(
+		"https://api.steampowered.com/IPlayerService/GetOwnedGames/v1/?key=%s&steamid=%s&include_appinfo=true&include_played_free_games=true&format=json",
+		apiKey,
+		steamID,
+	)
+	body, err := steamGet(ctx, "GetOwnedGames", url)
+	if err != nil {
+		return nil, api.New(api.KindUpstream, "steam owned games request failed: "+err.Error(), "")
+	}
+	var resp struct {
+		Response struct {
+			GameCount int              `json:"game_count"`
+			Games     []steamOwnedGame `json:"games"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, api.New(api.KindUpstream, "steam owned games response: "+err.Error(), "")
+	}
+	if resp.Response.GameCount == 0 {
+		return nil, api.New(api.KindNotFound, "no games found for Steam ID "+steamID,
+			"The profile or its game details may be private.")
+	}
+	return resp.Response.Games, nil
+}
+
+type steamLibraryStats struct {
+	SteamID               string               `json:"steamid"`
+	Games                 int                  `json:"games"`
+	Played                int                  `json:"played"`
+	NeverPlayed           int                  `json:"never_played"`
+	PlayedShare           float64              `json:"played_share"` // played / games
+	PlayedLast2Weeks      int                  `json:"played_last_2weeks"`
+	PlaytimeMinutes       int                  `json:"playtime_minutes"`
+	Playtime2WeeksMinutes int                  `json:"playtime_2weeks_minutes"`
+	Genres                []steamPlaytimeGroup `json:"genres"`
+	Developers            []steamPlaytimeGroup `json:"developers"`
+	Details               steamDetailsCoverage `json:"details"`
+}
+
+// steamPlaytimeGroup is the playtime of the games sharing a genre or
+// developer. A game with several counts towards each.
+type steamPlaytimeGroup struct {
+	Name            string  `json:"name"`
+	Games           int     `json:"games"`
+	PlaytimeMinutes int     `json:"playtime_minutes"`
+	Share           float64 `json:"share"` // of details.playtime_minutes
+}
+
+// steamDetailsCoverage says how much of the library the genre and developer
+// breakdowns could see: they only use store details already in steamDetails,
+// plus a few fetched per request.
+type steamDetailsCoverage struct {
+	Known           int `json:"known"`
+	Fetched         int `json:"fetched"` // store lookups made for this request
+	Unknown         int `json:"unknown"`
+	PlaytimeMinutes int `json:"playtime_minutes"` // playtime of the known games
+}
+
+// steamLibraryStatsFor aggregates games. Store details missing from the cache
+// are fetched for at most fetch games, most played first, so repeated calls
+// fill in the breakdowns where they matter most.
+func steamLibraryStatsFor(ctx context.Context, steamID string, games []steamOwnedGame, fetch, top int) steamLibraryStats {
+	s := steamLibraryStats{SteamID: steamID, Games: len(games)}
+	for _, g := range games {
+		s.PlaytimeMinutes += g.PlaytimeForever
+		s.Playtime2WeeksMinutes += g.Playtime2Weeks
+		if g.PlaytimeForever > 0 {
+			s.Played++
+		}
+		if g.Playtime2Weeks > 0 {
+			s.PlayedLast2Weeks++
+		}
+	}
+	s.NeverPlayed = s.Games - s.Played
+	s.PlayedShare = steamRatio(s.Played, s.Games)
+
+	byPlaytime := slices.Clone(games)
+	slices.SortStableFunc(byPlaytime, func(a, b steamOwnedGame) int { return cmp.Compare(b.PlaytimeForever, a.PlaytimeForever) })
+
+	ctx, cancel := context.WithTimeout(ctx, steamBatchTimeout)
+	defer cancel()
+	genres, developers := map[string]*steamPlaytimeGroup{}, map[string]*steamPlaytimeGroup{}
+	count := func(groups map[string]*steamPlaytimeGroup, name string, minutes int) {
+		g := groups[name]
+		if g == nil {
+			g = &steamPlaytimeGroup{Name: name}
+			groups[name] = g
+		}
+		g.Games++
+		g.PlaytimeMinutes += minutes
+	}
+	for _, g := range byPlaytime {
+		allowFetch := s.Details.Fetched < fetch
+		details, cached, err := steamDetails.get(ctx, g.AppID, allowFetch)
+		if !cached && allowFetch {
+			s.Details.Fetched++
+		}
+		if err != nil {
+			s.Details.Unknown++
+			continue
+		}
+		s.Details.Known++
+		s.Details.PlaytimeMinutes += g.PlaytimeForever
+		for _, genre := range details.Genres {
+			count(genres, genre.Description, g.PlaytimeForever)
+		}
+		for _, dev := range details.Developers {
+			count(developers, dev, g.PlaytimeForever)
+		}
+	}
+	s.Genres = steamTopGroups(genres, s.Details.PlaytimeMinutes, top)
+	s.Developers = steamTopGroups(developers, s.Details.PlaytimeMinutes, top)
+	return s
+}
+
+func steamTopGroups(groups map[string]*steamPlaytimeGroup, total, top int) []steamPlaytimeGroup {
+	out := make([]steamPlaytimeGroup, 0, len(groups))
+	for _, g := range groups {
+		g.Share = steamRatio(g.PlaytimeMinutes, total)
+		out = append(out, *g)
+	}
+	slices.SortFunc(out, func(a, b steamPlaytimeGroup) int {
+		if c := cmp.Compare(b.PlaytimeMinutes, a.PlaytimeMinutes); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(b.Games, a.Games); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return out[:min(top, len(out))]
+}
+
+// steamRatio is n/d to three places, 0 when d is.
+func steamRatio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return math.Round(float64(n)/float64(d)*1000) / 1000
+}
+
+// steamLibraryHistory is one steam id's snapshot file.
+type steamLibraryHistory struct {
+	SteamID   string                 `json:"steamid"`
+	Names     map[int]string         `json:"names"`     // app names as last seen
+	Snapshots []steamLibrarySnapshot `json:"snapshots"` // oldest first
+}
+
+type steamLibrarySnapshot struct {
+	At                    time.Time   `json:"at"`
+	Games                 int         `json:"games"`
+	PlaytimeMinutes       int         `json:"playtime_minutes"`
+	Playtime2WeeksMinutes int         `json:"playtime_2weeks_minutes"`
+	Apps                  map[int]int `json:"apps,omitempty"` // app id -> playtime_forever, played apps only
+}
+
+// steamLibrary takes the scheduled library snapshots behind
+// /steam/v1/library/history.
+var steamLibrary = &steamSnapshotter{last: map[string]time.Time{}}
+
+type steamSnapshotter struct {
+	mu       sync.Mutex
+	users    []string
+	interval time.Duration // 0 disables scheduled snapshots
+	last     map[string]time.Time
+
+	files sync.Mutex // held while a history file is read and rewritten
+}
+
+// apply swaps in the tracked users and the schedule from cfg.
+func (s *steamSnapshotter) apply(cfg earapiSettings) {
+	hours := cfg.Steam.LibrarySnapshotHours
+	if hours == 0 {
+		hours = 24
+	}
+	s.mu.Lock()
+	s.users = slices.Clone(cfg.Steam.LibrarySnapshotUsers)
+	s.interval = time.Duration(max(hours, 0)) * time.Hour
+	s.mu.Unlock()
+}
+
+// due lists the tracked users whose newest snapshot is older than the
+// interval. A user seen for the first time is looked up on disk.
+func (s *steamSnapshotter) due(now time.Time) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.interval <= 0 {
+		return nil
+	}
+	var out []string
+	for _, id := range s.users {
+		last, ok := s.last[id]
+		if !ok {
+			if h, err := readSteamLibraryHistory(id); err == nil && len(h.Snapshots) > 0 {
+				last = h.Snapshots[len(h.Snapshots)-1].At
+			}
+			s.last[id] = last
+		}
+		if now.Sub(last) >= s.interval {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// run snapshots the tracked users on schedule until ctx ends. Like the app
+// list refresh it checks once a minute, so reloads apply without a restart.
+func (s *steamSnapshotter) run(ctx context.Context, apiKey string) {
+	if apiKey == "" {
+		slog.Info("steam library snapshots off: no steam API key")
+		return
+	}
+	t := time.NewTicker(time.Minute)
+	defer t.Stop()
+	for {
+		for _, id := range s.due(time.Now()) {
+			if _, err := s.snapshot(ctx, apiKey, id); err != nil {
+				slog.Warn("steam library snapshot failed", "steamid", id, "err", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// snapshot records steamID's library now. A failed attempt still counts
+// towards the schedule, so an unreachable profile is retried next interval
+// rather than every minute.
+func (s *steamSnapshotter) snapshot(ctx context.Context, apiKey, steamID string) (steamLibrarySnapshot, error) {
+	now := time.Now().UTC()
+	s.mu.Lock()
+	s.last[steamID] = now
+	s.mu.Unlock()
+
+	games, err := fetchSteamOwnedGames(ctx, apiKey, steamID)
+	if err != nil {
+		return steamLibrarySnapshot{}, err
+	}
+	snap := steamLibrarySnapshot{At: now, Games: len(games), Apps: map[int]int{}}
+	for _, g := range games {
+		snap.PlaytimeMinutes += g.PlaytimeForever
+		snap.Playtime2WeeksMinutes += g.Playtime2Weeks
+		if g.PlaytimeForever > 0 {
+			snap.Apps[g.AppID] = g.PlaytimeForever
+		}
+	}
+
+	s.files.Lock()
+	defer s.files.Unlock()
+	h, err := readSteamLibraryHistory(steamID)
+	if errors.Is(err, os.ErrNotExist) {
+		h, err = &steamLibraryHistory{SteamID: steamID}, nil
+	}
+	if err != nil {
+		return steamLibrarySnapshot{}, err
+	}
+	if h.Names == nil {
+		h.Names = map[int]string{}
+	}
+	for _, g := range games {
+		if g.PlaytimeForever > 0 && g.Name != "" {
+			h.Names[g.AppID] = g.Name
+		}
+	}
+	h.Snapshots = append(h.Snapshots, snap)
+	if n := len(h.Snapshots); n > steamSnapshotKeep {
+		h.Snapshots = slices.Delete(h.Snapshots, 0, n-steamSnapshotKeep)
+	}
+	if err := writeSteamLibraryHistory(h); err != nil {
+		return steamLibrarySnapshot{}, err
+	}
+	slog.Info("steam library snapshot taken", "steamid", steamID, "games", snap.Games, "playtime_minutes", snap.PlaytimeMinutes)
+	return snap, nil
+}
+
+func (s *steamSnapshotter) tracked(steamID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Contains(s.users, steamID)
+}
+
+func steamLibraryHistoryFile(steamID string) string {
+	return filepath.Join(steamLibraryDir, steamID+".json")
+}
+
+func readSteamLibraryHistory(steamID string) (*steamLibraryHistory, error) {
+	file, err := os.ReadFile(steamLibraryHistoryFile(steamID))
+	if err != nil {
+		return nil, err
+	}
+	h := &steamLibraryHistory{}
+	if err := json.Unmarshal(file, h); err != nil {
+		return nil, fmt.Errorf("%s: %w", steamLibraryHistoryFile(steamID), err)
+	}
+	return h, nil
+}
+
+func writeSteamLibraryHistory(h *steamLibraryHistory) error {
+	if err := os.MkdirAll(steamLibraryDir, 0755); err != nil {
+		return err
+	}
+	file, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return atomicfile.Write(steamLibraryHistoryFile(h.SteamID), file, 0644)
+}
+
+// steamHistoryPoint is one point of a playtime chart. Deltas are against the
+// point before, which may fall outside the requested window.
+type steamHistoryPoint struct {
+	At                    time.Time        `json:"at"`
+	Games                 int              `json:"games"`
+	PlaytimeMinutes       int              `json:"playtime_minutes"`
+	Playtime2WeeksMinutes int              `json:"playtime_2weeks_minutes"`
+	DeltaMinutes          *int             `json:"delta_minutes,omitempty"` // unset on the oldest snapshot
+	Top                   []steamGameDelta `json:"top,omitempty"`
+}
+
+type steamGameDelta struct {
+	AppID        int    `json:"appid"`
+	Name         string `json:"name,omitempty"`
+	DeltaMinutes int    `json:"delta_minutes"`
+}
+
+// steamHistoryPoints turns the snapshots into chart points: every snapshot,
+// or with weekly set the last one of each ISO week. Only points at or after
+// since are returned.
+func steamHistoryPoints(h *steamLibraryHistory, weekly bool, since time.Time, top int) []steamHistoryPoint {
+	snaps := h.Snapshots
+	if weekly {
+		snaps = nil
+		for _, s := range h.Snapshots {
+			if n := len(snaps); n > 0 && sameISOWeek(snaps[n-1].At, s.At) {
+				snaps[n-1] = s
+				continue
+			}
+			snaps = append(snaps, s)
+		}
+	}
+
+	var out []steamHistoryPoint
+	for i, s := range snaps {
+		if s.At.Before(since) {
+			continue
+		}
+		p := steamHistoryPoint{At: s.At, Games: s.Games, PlaytimeMinutes: s.PlaytimeMinutes, Playtime2WeeksMinutes: s.Playtime2WeeksMinutes}
+		if i > 0 {
+			prev := snaps[i-1]
+			delta := s.PlaytimeMinutes - prev.PlaytimeMinutes
+			p.DeltaMinutes = &delta
+			for app, minutes := range s.Apps {
+				if d := minutes - prev.Apps[app]; d > 0 {
+					p.Top = append(p.Top, steamGameDelta{AppID: app, Name: h.Names[app], DeltaMinutes: d})
+				}
+			}
+			slices.SortFunc(p.Top, func(a, b steamGameDelta) int {
+				if c := cmp.Compare(b.DeltaMinutes, a.DeltaMinutes); c != 0 {
+					return c
+				}
+				return cmp.Compare(a.AppID, b.AppID)
+			})
+			p.Top = p.Top[:min(top, len(p.Top))]
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+func sameISOWeek(a, b time.Time) bool {
+	ay, aw := a.ISOWeek()
+	by, bw := b.ISOWeek()
+	return ay == by && aw == bw
+}
+
+// steamLibraryStatsHandler aggregates a user's library. details= caps the
+// store lookups for games not yet in the details cache (default 10).
+func steamLibraryStatsHandler(c *gin.Context) {
+	steamID, err := steamUserParam(c, "userid")
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	fetch, err := queryInt(c, "details", 10, 0, 50)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	top, err := queryInt(c, "top", 10, 1, 100)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	games, err := fetchSteamOwnedGames(c.Request.Context(), currentConfig().Apikeys.Steamapikey, steamID)
+	if err != nil {
+		slog.WarnContext(c.Request.Context(), "steam owned games failed", "userid", steamID, "err", err)
+		api.Fail(c, err)
+		return
+	}
+	api.OK(c, steamLibraryStatsFor(c.Request.Context(), steamID, games, fetch, top))
+}
+
+// steamLibraryHistoryHandler charts the snapshots taken for a user.
+func steamLibraryHistoryHandler(c *gin.Context) {
+	steamID, err := steamUserParam(c, "userid")
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	weeks, err := queryInt(c, "weeks", 12, 1, 520)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	top, err := queryInt(c, "top", 5, 0, 50)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	bucket := c.DefaultQuery("bucket", "week")
+	if bucket != "week" && bucket != "snapshot" {
+		api.Fail(c, api.New(api.KindInvalidInput, fmt.Sprintf("unknown bucket %q", bucket), "Use week or snapshot."))
+		return
+	}
+
+	h, err := readSteamLibraryHistory(steamID)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		api.Fail(c, api.New(api.KindNotFound, "no library snapshots for Steam ID "+steamID,
+			"Add it to steam.library_snapshot_users, or take one with POST /steam/v1/admin/library/snapshot."))
+		return
+	case err != nil:
+		api.Fail(c, err)
+		return
+	}
+	since := time.Now().AddDate(0, 0, -7*weeks)
+	api.OK(c, gin.H{
+		"steamid": steamID,
+		"tracked": steamLibrary.tracked(steamID),
+		"bucket":  bucket,
+		"points":  steamHistoryPoints(h, bucket == "week", since, top),
+	})
+}
+
+// steamLibrarySnapshotHandler takes a snapshot now, for any user.
+func steamLibrarySnapshotHandler(c *gin.Context) {
+	steamID, err := steamUserParam(c, "userid")
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	snap, err := steamLibrary.snapshot(c.Request.Context(), currentConfig().Apikeys.Steamapikey, steamID)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	snap.Apps = nil
+	api.OK(c, gin.H{"steamid": steamID, "snapshot": snap})
+}
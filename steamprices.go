@@ -0,0 +1,732 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"earapi/api"
+	"earapi/atomicfile"
+	"earapi/metrics"
+)
+
+// steamPricesDir holds the tracked list and one price history per app and
+// store country.
+const steamPricesDir = "steamdata/prices"
+
+// steamPriceChunk is how many apps one price request asks the store about.
+const steamPriceChunk = 50
+
+var countryPattern = regexp.MustCompile(`^[a-z]{2}$`)
+
+// steamPriceWatch is one tracked app in one store country.
+type steamPriceWatch struct {
+	AppID     int       `json:"appid"`
+	Country   string    `json:"country"`             // store country code, lower case
+	Threshold int       `json:"threshold,omitempty"` // alert at or below this final price, in minor units
+	AddedAt   time.Time `json:"added_at"`
+}
+
+func (w steamPriceWatch) key() string { return fmt.Sprintf("%d-%s", w.AppID, w.Country) }
+
+// steamPriceHistory is one app's prices in one country, a point per change.
+type steamPriceHistory struct {
+	AppID     int               `json:"appid"`
+	Country   string            `json:"country"`
+	Name      string            `json:"name,omitempty"`
+	CheckedAt time.Time         `json:"checked_at"` // last successful poll
+	Points    []steamPricePoint `json:"points"`     // oldest first
+}
+
+// steamPricePoint is a price as the store quoted it. Amounts are in the
+// currency's minor units; a free app has a zero price and Free set.
+type steamPricePoint struct {
+	At              time.Time `json:"at"`
+	Currency        string    `json:"currency,omitempty"`
+	Initial         int       `json:"initial"`
+	Final           int       `json:"final"`
+	DiscountPercent int       `json:"discount_percent"`
+	FinalFormatted  string    `json:"final_formatted,omitempty"`
+	Free            bool      `json:"free,omitempty"`
+}
+
+func (p steamPricePoint) same(q steamPricePoint) bool {
+	return p.Currency == q.Currency && p.Initial == q.Initial && p.Final == q.Final &&
+		p.DiscountPercent == q.DiscountPercent && p.Free == q.Free
+}
+
+// low and high are the cheapest and dearest points in the history's latest
+// currency, nil when there are none.
+func (h *steamPriceHistory) low() *steamPricePoint {
+	return h.extreme(func(a, b steamPricePoint) int { return cmp.Compare(a.Final, b.Final) })
+}
+
+func (h *steamPriceHistory) high() *steamPricePoint {
+	return h.extreme(func(a, b steamPricePoint) int { return cmp.Compare(b.Final, a.Final) })
+}
+
+func (h *steamPriceHistory) extreme(better func(a, b steamPricePoint) int) *steamPricePoint {
+	if len(h.Points) == 0 {
+		return nil
+	}
+	currency := h.Points[len(h.Points)-1].Currency
+	var best *steamPricePoint
+	for i, p := range h.Points {
+		if p.Currency == currency && (best == nil || better(p, *best) < 0) {
+			best = &h.Points[i]
+		}
+	}
+	return best
+}
+
+func (h *steamPriceHistory) current() *steamPricePoint {
+	if len(h.Points) == 0 {
+		return nil
+	}
+	return &h.Points[len(h.Points)-1]
+}
+
+// steamPriceAlert is the webhook body.
+type steamPriceAlert struct {
+	Event         string          `json:"event"`   // always "steam.price_alert"
+	Reasons       []string        `json:"reasons"` // "threshold", "historical_low"
+	AppID         int             `json:"appid"`
+	Name          string          `json:"name,omitempty"`
+	Country       string          `json:"country"`
+	StoreURL      string          `json:"storeurl"`
+	Price         steamPricePoint `json:"price"`
+	PreviousFinal *int            `json:"previous_final,omitempty"`
+	PreviousLow   *int            `json:"previous_low,omitempty"`
+	Threshold     int             `json:"threshold,omitempty"`
+}
+
+// Alerts wait in a queue for the webhook, so a slow or failing endpoint
+// never holds up a poll. Each gets a minute for all its tries.
+const (
+	steamAlertQueue  = 64
+	steamAlertBudget = time.Minute
+)
+
+// steamPrices tracks the prices behind /steam/v1/prices.
+var steamPrices = &steamPriceTracker{alerts: make(chan *steamPriceAlert, steamAlertQueue)}
+
+type steamPriceTracker struct {
+	mu       sync.Mutex
+	watches  []steamPriceWatch
+	loaded   bool
+	country  string        // default store country
+	interval time.Duration // 0 disables scheduled polls
+	webhook  string
+	secret   string
+	lastPoll time.Time
+
+	polling sync.Mutex // held for the whole of a poll
+	files   sync.Mutex // held while the tracked list or a history is rewritten
+
+	alerts  chan *steamPriceAlert // waiting for the webhook
+	sending sync.Once             // starts the sender on the first alert
+}
+
+// apply swaps in the poll schedule, default country and webhook from cfg.
+func (t *steamPriceTracker) apply(cfg earapiSettings) {
+	minutes := cfg.Steam.PricePollMinutes
+	if minutes == 0 {
+		minutes = 360
+	}
+	country := strings.ToLower(cfg.Steam.PriceCountry)
+	if country == "" {
+		country = "us"
+	}
+	t.mu.Lock()
+	t.interval = time.Duration(max(minutes, 0)) * time.Minute
+	t.country = country
+	t.webhook, t.secret = cfg.Steam.PriceWebhookURL, cfg.Steam.PriceWebhookSecret
+	t.mu.Unlock()
+}
+
+// list returns the tracked apps, reading them from disk on first use.
+func (t *steamPriceTracker) list() ([]steamPriceWatch, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.loaded {
+		file, err := os.ReadFile(filepath.Join(steamPricesDir, "tracked.json"))
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, err
+		default:
+			if err := json.Unmarshal(file, &t.watches); err != nil {
+				return nil, fmt.Errorf("%s/tracked.json: %w", steamPricesDir, err)
+			}
+		}
+		t.loaded = true
+	}
+	return slices.Clone(t.watches), nil
+}
+
+// update changes the tracked list with fn and writes it back.
+func (t *steamPriceTracker) update(fn func(watches []steamPriceWatch) []steamPriceWatch) error {
+	if _, err := t.list(); err != nil {
+		return err
+	}
+	t.files.Lock()
+	defer t.files.Unlock()
+	t.mu.Lock()
+	next := fn(slices.Clone(t.watches))
+	t.mu.Unlock()
+
+	if err := os.MkdirAll(steamPricesDir, 0755); err != nil {
+		return err
+	}
+	file, err := json.MarshalIndent(next, "", " ")
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.Write(filepath.Join(steamPricesDir, "tracked.json"), file, 0644); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	t.watches = next
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *steamPriceTracker) defaultCountry() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.country
+}
+
+// due reports whether a scheduled poll should run now.
+func (t *steamPriceTracker) due(now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.interval > 0 && len(t.watches) > 0 && now.Sub(t.lastPoll) >= t.interval
+}
+
+// run polls on schedule until ctx ends, checking once a minute like the
+// other steam jobs.
+func (t *steamPriceTracker) run(ctx context.Context) {
+	if _, err := t.list(); err != nil {
+		slog.Warn("steam price tracking: tracked list unreadable", "err", err)
+	}
+	tick := time.NewTicker(time.Minute)
+	defer tick.Stop()
+	for {
+		if t.due(time.Now()) {
+			if r, err := t.tryPoll(ctx, nil); err == nil {
+				slog.Info("steam prices polled", "checked", r.Checked, "changed", r.Changed, "alerts", r.Alerts, "errors", len(r.Errors))
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// steamPollResult sums up one poll.
+type steamPollResult struct {
+	At      time.Time         `json:"at"`
+	Checked int               `json:"checked"`
+	Changed int               `json:"changed"`
+	Alerts  int               `json:"alerts"`
+	Errors  map[string]string `json:"errors,omitempty"` // by appid-country
+}
+
+// tryPoll polls only, or every tracked app when only is nil, unless another
+// poll is running.
+func (t *steamPriceTracker) tryPoll(ctx context.Context, only []steamPriceWatch) (steamPollResult, error) {
+	if !t.polling.TryLock() {
+		return steamPollResult{}, errPollRunning
+	}
+	defer t.polling.Unlock()
+
+	watches := only
+	if watches == nil {
+		var err error
+		if watches, err = t.list(); err != nil {
+			return steamPollResult{}, err
+		}
+		t.mu.Lock()
+		t.lastPoll = time.Now()
+		t.mu.Unlock()
+	}
+
+	r := steamPollResult{At: time.Now().UTC(), Errors: map[string]string{}}
+	byCountry := map[string][]steamPriceWatch{}
+	for _, w := range watches {
+		byCountry[w.Country] = append(byCountry[w.Country], w)
+	}
+	for _, country := range slices.Sorted(maps.Keys(byCountry)) {
+		for chunk := range slices.Chunk(byCountry[country], steamPriceChunk) {
+			ids := make([]int, len(chunk))
+			for i, w := range chunk {
+				ids[i] = w.AppID
+			}
+			quotes, err := fetchSteamPrices(ctx, ids, country)
+			for _, w := range chunk {
+				if err == nil {
+					q, ok := quotes[w.AppID]
+					if !ok {
+						err = fmt.Errorf("not sold in %s", strings.ToUpper(country))
+					} else {
+						err = t.record(ctx, w, q, &r)
+					}
+				}
+				if err != nil {
+					r.Errors[w.key()] = err.Error()
+				}
+				r.Checked++
+			}
+		}
+	}
+	for k, msg := range r.Errors {
+		slog.Warn("steam price check failed", "watch", k, "err", msg)
+	}
+	return r, nil
+}
+
+var errPollRunning = errors.New("a price poll is already running")
+
+// record adds q to w's history when the price changed, and raises an alert
+// when it fell to the threshold or below the lowest price seen before.
+func (t *steamPriceTracker) record(ctx context.Context, w steamPriceWatch, q steamPricePoint, r *steamPollResult) error {
+	t.files.Lock()
+	h, err := readSteamPriceHistory(w.AppID, w.Country)
+	if errors.Is(err, os.ErrNotExist) {
+		h, err = &steamPriceHistory{AppID: w.AppID, Country: w.Country}, nil
+	}
+	if err != nil {
+		t.files.Unlock()
+		return err
+	}
+	if name := steamAppName(ctx, w.AppID); name != "" {
+		h.Name = name
+	}
+
+	var alert *steamPriceAlert
+	prev := h.current()
+	if prev == nil || !prev.same(q) {
+		r.Changed++
+		alert = &steamPriceAlert{Event: "steam.price_alert", AppID: w.AppID, Name: h.Name, Country: w.Country,
+			StoreURL: fmt.Sprintf("https://store.steampowered.com/app/%d/", w.AppID), Price: q, Threshold: w.Threshold}
+		if w.Threshold > 0 && q.Final <= w.Threshold && (prev == nil || prev.Currency != q.Currency || prev.Final > w.Threshold) {
+			alert.Reasons = append(alert.Reasons, "threshold")
+		}
+		if prev != nil && prev.Currency == q.Currency {
+			alert.PreviousFinal = &prev.Final
+			if low := h.low(); q.Final < low.Final {
+				alert.PreviousLow = &low.Final
+				alert.Reasons = append(alert.Reasons, "historical_low")
+			}
+		}
+		h.Points = append(h.Points, q)
+	}
+	h.CheckedAt = q.At
+	err = writeSteamPriceHistory(h)
+	t.files.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if alert != nil && len(alert.Reasons) > 0 {
+		r.Alerts++
+		t.queueAlert(alert)
+	}
+	return nil
+}
+
+// queueAlert logs alert and queues it for the webhook, if one is set. When
+// the queue is full the webhook is already far behind, so the alert is
+// dropped rather than making the poll wait.
+func (t *steamPriceTracker) queueAlert(alert *steamPriceAlert) {
+	slog.Info("steam price alert", "appid", alert.AppID, "country", alert.Country, "final", alert.Price.Final, "reasons", alert.Reasons)
+	t.mu.Lock()
+	url := t.webhook
+	t.mu.Unlock()
+	if url == "" {
+		return
+	}
+	t.sending.Do(func() { go t.send() })
+	select {
+	case t.alerts <- alert:
+	default:
+		slog.Warn("steam price alert dropped: webhook queue full", "appid", alert.AppID, "country", alert.Country)
+	}
+}
+
+// send delivers queued alerts one at a time, each within steamAlertBudget.
+func (t *steamPriceTracker) send() {
+	for alert := range t.alerts {
+		ctx, cancel := context.WithTimeout(context.Background(), steamAlertBudget)
+		if err := t.notify(ctx, alert); err != nil {
+			slog.Warn("steam price alert webhook failed", "appid", alert.AppID, "country", alert.Country, "err", err)
+		}
+		cancel()
+	}
+}
+
+// notify POSTs alert to the configured webhook, if any, signing it when a
+// secret is set. It tries three times before giving up.
+func (t *steamPriceTracker) notify(ctx context.Context, alert *steamPriceAlert) error {
+	t.mu.Lock()
+	url, secret := t.webhook, t.secret
+	t.mu.Unlock()
+	if url == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	var sig string
+	if secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		sig = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	for attempt := range 3 {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(attempt*attempt) * 2 * time.Second):
+			}
+		}
+		if err = postSteamWebhook(ctx, url, sig, body); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func postSteamWebhook(ctx context.Context, url, sig string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "earapi/"+appVersion)
+	if sig != "" {
+		req.Header.Set("X-Earapi-Signature", sig)
+	}
+	done := metrics.Upstream("webhook", "steam_price_alert")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		done(metrics.OutcomeError)
+		return steamErr(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		done(metrics.OutcomeError)
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	done(metrics.OutcomeOK)
+	return nil
+}
+
+// fetchSteamPrices asks the store for the current prices of appIDs in one
+// country. Apps the store doesn't sell there are left out.
+func fetchSteamPrices(ctx context.Context, appIDs []int, country string) (map[int]steamPricePoint, error) {
+	if wait, err := steamDetails.limit.wait(ctx); err != nil {
+		return nil, fmt.Errorf("store lookup budget used up; next in %s", wait.Round(time.Second))
+	}
+	ids := make([]string, len(appIDs))
+	for i, id := range appIDs {
+		ids[i] = strconv.Itoa(id)
+	}
+	body, err := steamGet(ctx, "appdetails_prices", fmt.Sprintf(
+		"https://store.steampowered.com/api/appdetails?appids=%s&cc=%s&filters=price_overview",
+		strings.Join(ids, ","),
+		country,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("steam store request failed: %w", err)
+	}
+	var resp map[string]struct {
+		Success bool            `json:"success"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("steam store response: %w", err)
+	}
+
+	now := time.Now().UTC()
+	out := map[int]steamPricePoint{}
+	for _, id := range appIDs {
+		r, ok := resp[strconv.Itoa(id)]
+		if !ok || !r.Success {
+			continue
+		}
+		var data struct {
+			Price *struct {
+				Currency        string `json:"currency"`
+				Initial         int    `json:"initial"`
+				Final           int    `json:"final"`
+				DiscountPercent int    `json:"discount_percent"`
+				FinalFormatted  string `json:"final_formatted"`
+			} `json:"price_overview"`
+		}
+		// Free apps come back with "data": [] rather than an object.
+		if len(r.Data) > 0 && r.Data[0] == '{' {
+			if err := json.Unmarshal(r.Data, &data); err != nil {
+				return nil, fmt.Errorf("steam store response for app %d: %w", id, err)
+			}
+		}
+		p := steamPricePoint{At: now, Free: data.Price == nil}
+		if data.Price != nil {
+			p.Currency, p.Initial, p.Final = data.Price.Currency, data.Price.Initial, data.Price.Final
+			p.DiscountPercent, p.FinalFormatted = data.Price.DiscountPercent, data.Price.FinalFormatted
+		}
+		out[id] = p
+	}
+	return out, nil
+}
+
+// steamAppName names an app from the app list or the store details cache,
+// without asking Steam.
+func steamAppName(ctx context.Context, appID int) string {
+	if name := steamApps.name(appID); name != "" {
+		return name
+	}
+	if d, _, err := steamDetails.get(ctx, appID, false); err == nil {
+		return d.Name
+	}
+	return ""
+}
+
+func steamPriceHistoryFile(appID int, country string) string {
+	return filepath.Join(steamPricesDir, fmt.Sprintf("%d-%s.json", appID, country))
+}
+
+func readSteamPriceHistory(appID int, country string) (*steamPriceHistory, error) {
+	file, err := os.ReadFile(steamPriceHistoryFile(appID, country))
+	if err != nil {
+		return nil, err
+	}
+	h := &steamPriceHistory{}
+	if err := json.Unmarshal(file, h); err != nil {
+		return nil, fmt.Errorf("%s: %w", steamPriceHistoryFile(appID, country), err)
+	}
+	return h, nil
+}
+
+func writeSteamPriceHistory(h *steamPriceHistory) error {
+	if err := os.MkdirAll(steamPricesDir, 0755); err != nil {
+		return err
+	}
+	file, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return atomicfile.Write(steamPriceHistoryFile(h.AppID, h.Country), file, 0644)
+}
+
+// steamCountryParam reads a store country code, the configured default when
+// absent.
+func steamCountryParam(raw string) (string, error) {
+	if raw == "" {
+		return steamPrices.defaultCountry(), nil
+	}
+	cc := strings.ToLower(raw)
+	if !countryPattern.MatchString(cc) {
+		return "", api.New(api.KindInvalidInput, fmt.Sprintf("country %q is not a two-letter code", raw), "Use a store country such as us, gb or de.")
+	}
+	return cc, nil
+}
+
+// steamPriceView is a tracked app as the list endpoint shows it.
+type steamPriceView struct {
+	steamPriceWatch
+	Name      string           `json:"name,omitempty"`
+	CheckedAt *time.Time       `json:"checked_at,omitempty"`
+	Current   *steamPricePoint `json:"current,omitempty"`
+	Low       *steamPricePoint `json:"low,omitempty"`
+}
+
+func steamPriceViewOf(w steamPriceWatch) steamPriceView {
+	v := steamPriceView{steamPriceWatch: w}
+	if h, err := readSteamPriceHistory(w.AppID, w.Country); err == nil {
+		v.Name, v.Current, v.Low = h.Name, h.current(), h.low()
+		if !h.CheckedAt.IsZero() {
+			v.CheckedAt = &h.CheckedAt
+		}
+	}
+	return v
+}
+
+func steamPricesListHandler(c *gin.Context) {
+	watches, err := steamPrices.list()
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	out := make([]steamPriceView, len(watches))
+	for i, w := range watches {
+		out[i] = steamPriceViewOf(w)
+	}
+	api.OK(c, gin.H{"tracked": out})
+}
+
+// steamPricesTrackHandler starts tracking an app, or changes the threshold
+// of one already tracked, and checks its price straight away.
+func steamPricesTrackHandler(c *gin.Context) {
+	var req struct {
+		AppID     int    `json:"appid"`
+		Country   string `json:"country"`
+		Threshold int    `json:"threshold"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		api.Fail(c, api.New(api.KindInvalidInput, "malformed request", `Send {"appid":1086940,"country":"us","threshold":2999}.`))
+		return
+	}
+	if req.AppID <= 0 {
+		api.Fail(c, api.New(api.KindInvalidInput, "appid is required", ""))
+		return
+	}
+	if req.Threshold < 0 {
+		api.Fail(c, api.New(api.KindInvalidInput, "threshold cannot be negative", "Give it in the currency's minor units: 2999 for 29.99."))
+		return
+	}
+	country, err := steamCountryParam(req.Country)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	w := steamPriceWatch{AppID: req.AppID, Country: country, Threshold: req.Threshold, AddedAt: time.Now().UTC()}
+	created := true
+	err = steamPrices.update(func(watches []steamPriceWatch) []steamPriceWatch {
+		for i, cur := range watches {
+			if cur.key() == w.key() {
+				created = false
+				w.AddedAt = cur.AddedAt
+				watches[i] = w
+				return watches
+			}
+		}
+		return append(watches, w)
+	})
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	out := gin.H{}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), steamSingleTimeout)
+	defer cancel()
+	if r, err := steamPrices.tryPoll(ctx, []steamPriceWatch{w}); err != nil {
+		out["check_error"] = err.Error()
+	} else if msg, ok := r.Errors[w.key()]; ok {
+		out["check_error"] = msg
+	}
+	out["tracked"] = steamPriceViewOf(w)
+	out["created"] = created
+	api.OK(c, out)
+}
+
+// steamPricesUntrackHandler stops tracking an app. Its history stays.
+func steamPricesUntrackHandler(c *gin.Context) {
+	appID, err := steamAppIDParam(c.Param("appid"))
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	country, err := steamCountryParam(c.Query("country"))
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	key := steamPriceWatch{AppID: appID, Country: country}.key()
+	removed := false
+	err = steamPrices.update(func(watches []steamPriceWatch) []steamPriceWatch {
+		return slices.DeleteFunc(watches, func(w steamPriceWatch) bool {
+			if w.key() == key {
+				removed = true
+			}
+			return w.key() == key
+		})
+	})
+	switch {
+	case err != nil:
+		api.Fail(c, err)
+	case !removed:
+		api.Fail(c, api.Errorf(api.KindNotFound, "app %d is not tracked in %s", appID, strings.ToUpper(country)))
+	default:
+		api.OK(c, gin.H{"appid": appID, "country": country, "removed": true})
+	}
+}
+
+func steamPriceHistoryHandler(c *gin.Context) {
+	appID, err := steamAppIDParam(c.Param("appid"))
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	country, err := steamCountryParam(c.Query("country"))
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	h, err := readSteamPriceHistory(appID, country)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		api.Fail(c, api.New(api.KindNotFound, fmt.Sprintf("no price history for app %d in %s", appID, strings.ToUpper(country)),
+			"Track it with POST /steam/v1/prices."))
+		return
+	case err != nil:
+		api.Fail(c, err)
+		return
+	}
+	watches, _ := steamPrices.list()
+	tracked := slices.ContainsFunc(watches, func(w steamPriceWatch) bool { return w.AppID == appID && w.Country == country })
+	api.OK(c, gin.H{
+		"appid":      h.AppID,
+		"country":    h.Country,
+		"name":       h.Name,
+		"tracked":    tracked,
+		"checked_at": h.CheckedAt,
+		"current":    h.current(),
+		"low":        h.low(),
+		"high":       h.high(),
+		"points":     h.Points,
+	})
+}
+
+// steamPricesPollHandler polls every tracked app now.
+func steamPricesPollHandler(c *gin.Context) {
+	r, err := steamPrices.tryPoll(c.Request.Context(), nil)
+	switch {
+	case errors.Is(err, errPollRunning):
+		api.Fail(c, api.New(api.KindConflict, err.Error(), "Try again once it finishes."))
+	case err != nil:
+		api.Fail(c, err)
+	default:
+		api.OK(c, r)
+	}
+}
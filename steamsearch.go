@@ -1,25 +1,53 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
-	"strconv"
+	neturl "net/url"
 	"strings"
-	"unicode"
+	"time"
+
+	"earapi/api"
+	"earapi/metrics"
 )
 
 const steamGamesCacheFile = "steamdata/steamgames.json"
 
+// steamAppListVersion is bumped when cached entries gain a field that only a
+// full fetch can fill in; an older file is refetched whole on the next refresh.
+// 2 added the app type.
+const steamAppListVersion = 2
+
+// steamSearchAppList is the app list as cached in steamGamesCacheFile. Files
+// written before fetched_at existed load with a zero FetchedAt, which makes
+// them due for a refresh straight away.
 type steamSearchAppList struct {
-	Apps []steamSearchApp `json:"apps"`
+	Version   int              `json:"version,omitempty"`
+	FetchedAt time.Time        `json:"fetched_at,omitzero"`
+	LastAppID uint32           `json:"last_appid,omitempty"` // highest app id held; delta refreshes page on from here
+	Apps      []steamSearchApp `json:"apps"`
 }
 
 type steamSearchApp struct {
 	AppID int    `json:"appid"`
 	Name  string `json:"name"`
+	Type  string `json:"type,omitempty"` // one of steamAppTypes; empty in files before version 2
+}
+
+// steamAppType is an app type search can filter on and the GetAppList flag
+// that selects it.
+type steamAppType struct{ name, flag string }
+
+var steamAppTypes = []steamAppType{
+	{"game", "include_games"},
+	{"dlc", "include_dlc"},
+	{"software", "include_software"},
+	{"video", "include_videos"},
+	{"hardware", "include_hardware"},
 }
 
 type steamStoreAppListResponse struct {
@@ -33,80 +61,77 @@ type steamStoreAppListResponse struct {
 	} `json:"response"`
 }
 
-func searchSteamApp(apiKey, input string) (string, error) {
+// searchSteamApp ranks the cached app list against input.
+func searchSteamApp(apiKey, input string, opts steamSearchOptions) ([]steamCandidate, error) {
 	if apiKey == "" {
-		return "", fmt.Errorf("steam API key is not configured")
+		return nil, api.New(api.KindUnavailable, "steam API key is not configured",
+			"Set apikeys.steamapikey (or EARAPI_APIKEYS_STEAMAPIKEY).")
 	}
 
-	appList, err := loadSteamSearchAppList(apiKey)
+	idx, err := steamApps.searchIndex(apiKey)
 	if err != nil {
-		return "", err
+		return nil, api.New(api.KindUpstream, err.Error(), "")
 	}
 
-	return findSteamSearchApp(appList, input)
+	found := idx.search(input, opts)
+	if len(found) == 0 {
+		return nil, api.Errorf(api.KindNotFound, "Game %s not found", input)
+	}
+	return found, nil
 }
 
-func loadSteamSearchAppList(apiKey string) (*steamSearchAppList, error) {
-	if _, err := os.Stat(steamGamesCacheFile); err == nil {
-		file, err := os.ReadFile(steamGamesCacheFile)
+// fetchSteamStoreAppList fetches every app with an id above after, one app
+// type at a time so each entry knows its type; 0 fetches the whole catalogue.
+// It returns the apps and the highest app id seen.
+func fetchSteamStoreAppList(ctx context.Context, apiKey string, after uint32) ([]steamSearchApp, uint32, error) {
+	var apps []steamSearchApp
+	for _, t := range steamAppTypes {
+		page, err := fetchSteamStoreAppPages(ctx, apiKey, t.flag, after)
 		if err != nil {
-			return nil, err
-		}
-
-		appList := &steamSearchAppList{}
-		if err := json.Unmarshal(file, appList); err != nil {
-			return nil, err
+			return nil, 0, err
 		}
-		if len(appList.Apps) > 0 {
-			return appList, nil
+		for i := range page {
+			page[i].Type = t.name
 		}
+		apps = append(apps, page...)
 	}
 
-	appList, err := fetchSteamStoreAppList(apiKey)
-	if err != nil {
-		return nil, err
-	}
-
-	file, err := json.MarshalIndent(appList, "", " ")
-	if err != nil {
-		return nil, err
+	if len(apps) == 0 && after == 0 {
+		return nil, 0, fmt.Errorf("steam store app list was empty")
 	}
-	if err := os.WriteFile(steamGamesCacheFile, file, 0644); err != nil {
-		return nil, err
+	lastAppID := after
+	for _, app := range apps {
+		lastAppID = max(lastAppID, uint32(app.AppID))
 	}
-
-	return appList, nil
+	return apps, lastAppID, nil
 }
 
-func fetchSteamStoreAppList(apiKey string) (*steamSearchAppList, error) {
+// fetchSteamStoreAppPages pages through IStoreService/GetAppList for the
+// apps one include_* flag selects.
+func fetchSteamStoreAppPages(ctx context.Context, apiKey, flag string, after uint32) ([]steamSearchApp, error) {
 	const maxResults = 50000
 
 	var apps []steamSearchApp
-	var lastAppID uint32
+	lastAppID := after
 
 	for {
 		url := fmt.Sprintf(
-			"https://api.steampowered.com/IStoreService/GetAppList/v1/?key=%s&max_results=%d&include_games=true&include_dlc=true&include_software=true&include_videos=true&include_hardware=true",
+			"https://api.steampowered.com/IStoreService/GetAppList/v1/?key=%s&max_results=%d&%s=true",
 			apiKey,
 			maxResults,
+			flag,
 		)
+		// include_games defaults to on; switch it off when fetching another type.
+		if flag != "include_games" {
+			url += "&include_games=false"
+		}
 		if lastAppID > 0 {
 			url += fmt.Sprintf("&last_appid=%d", lastAppID)
 		}
 
-		resp, err := http.Get(url)
+		body, err := steamGet(ctx, "GetAppList", url)
 		if err != nil {
-			return nil, err
-		}
-
-		body, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			return nil, err
-		}
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("steam store app list request failed: %s", strings.TrimSpace(string(body)))
+			return nil, fmt.Errorf("steam store app list request failed: %w", err)
 		}
 
 		var page steamStoreAppListResponse
@@ -130,39 +155,42 @@ func fetchSteamStoreAppList(apiKey string) (*steamSearchAppList, error) {
 		lastAppID = page.Response.LastAppID
 	}
 
-	if len(apps) == 0 {
-		return nil, fmt.Errorf("steam store app list was empty")
-	}
-
-	return &steamSearchAppList{Apps: apps}, nil
+	return apps, nil
 }
 
-func cleanSteamSearchString(s string) string {
-	var cleaned strings.Builder
-	for _, r := range s {
-		if unicode.IsLetter(r) || unicode.IsNumber(r) {
-			cleaned.WriteRune(r)
-		}
+// steamGet fetches a Steam Web API url, timing it as op. Errors never carry
+// the url, as it holds the API key.
+func steamGet(ctx context.Context, op, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.New("bad request url")
 	}
-	return strings.ToLower(cleaned.String())
-}
-
-func findSteamSearchApp(appList *steamSearchAppList, input string) (string, error) {
-	cleanedInput := cleanSteamSearchString(input)
+	done := metrics.Upstream("steam", op)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		done(metrics.OutcomeError)
+		return nil, steamErr(err)
+	}
+	defer resp.Body.Close()
 
-	if appID, err := strconv.Atoi(input); err == nil {
-		for _, app := range appList.Apps {
-			if app.AppID == appID {
-				return app.Name, nil
-			}
-		}
-		return "", fmt.Errorf("AppID %d not found", appID)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		done(metrics.OutcomeError)
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		done(metrics.OutcomeError)
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
 	}
+	done(metrics.OutcomeOK)
+	return body, nil
+}
 
-	for _, app := range appList.Apps {
-		if cleanSteamSearchString(app.Name) == cleanedInput {
-			return strconv.Itoa(app.AppID), nil
-		}
+// steamErr drops the URL a *url.Error quotes, and with it the API key.
+func steamErr(err error) error {
+	var uerr *neturl.Error
+	if errors.As(err, &uerr) {
+		return uerr.Err
 	}
-	return "", fmt.Errorf("Game %s not found", input)
+	return err
 }
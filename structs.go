@@ -4,12 +4,32 @@ package main
 
 type earapiSettings struct {
 	API struct {
-		Port string `json:"port"`
+		Port           string   `json:"port"`
+		TrustedProxies []string `json:"trusted_proxies"` // IPs or CIDRs whose X-Forwarded-For is believed; empty = none
 	} `json:"api"`
 	Apikeys struct {
 		Steamapikey  string `json:"steamapikey"`
 		Tmdbapitoken string `json:"tmdbapitoken"`
 	} `json:"apikeys"`
+	Steam struct {
+		AppListRefreshMinutes int           `json:"applist_refresh_minutes"` // app list behind /steam/v1/search; 0 = 360, <0 = never
+		LibrarySnapshotUsers  []string      `json:"library_snapshot_users"`  // steam ids whose library /steam/v1/library/history tracks
+		LibrarySnapshotHours  int           `json:"library_snapshot_hours"`  // 0 = 24, <0 = never
+		AppDetailsCacheHours  int           `json:"appdetails_cache_hours"`  // store details behind /steam/v1/appdata; 0 = 24, <0 = no cache
+		StoreRateLimit        rateLimitRule `json:"store_ratelimit"`         // pace of our calls to the Steam store
+		PriceCountry          string        `json:"price_country"`           // store country for tracked prices when none is given; "" = us
+		PricePollMinutes      int           `json:"price_poll_minutes"`      // 0 = 360, <0 = never
+		PriceWebhookURL       string        `json:"price_webhook_url"`       // price alerts are POSTed here; "" = log only
+		PriceWebhookSecret    string        `json:"price_webhook_secret"`    // signs alerts with HMAC-SHA256 when set
+	} `json:"steam"`
+	Tmdb struct {
+		CacheHours int `json:"cache_hours"` // detail and provider responses in moviedata/; 0 = 24, <0 = no cache
+	} `json:"tmdb"`
+	Netflix struct {
+		CacheMinutes     int      `json:"cache_minutes"`     // scraped charts behind /netflix/v1/top; 0 = 60, <0 = no cache
+		ArchiveCountries []string `json:"archive_countries"` // Tudum country slugs whose charts are archived weekly; "global" for worldwide
+		ArchiveHours     int      `json:"archive_hours"`     // how often to look for a new week; 0 = 6, <0 = never
+	} `json:"netflix"`
 	Youtube struct {
 		ClientID       string `json:"client_id"`
 		ClientSecret   string `json:"client_secret"`
@@ -21,5 +41,30 @@ type earapiSettings struct {
 		CacheMinutes   int    `json:"cache_minutes"` // IMDb list disk cache; 0 disables
 		BrowserPath    string `json:"browser_path"`  // optional Chrome/Chromium path for p.* aliases
 		BrowserHeadful bool   `json:"browser_headful"`
+		StoreBackend   string `json:"store_backend"`  // "bolt" (default) or "memory"
+		StorePath      string `json:"store_path"`     // bolt database file
+		RetentionDays  int    `json:"retention_days"` // stored handles; 0 = 90 days, <0 = forever
 	} `json:"watchlist"`
+	Auth struct {
+		Enabled      bool           `json:"enabled"`       // enforce API keys on write endpoints
+		ProtectReads bool           `json:"protect_reads"` // also require the read scope on everything else
+		Keys         []apiKeyRecord `json:"keys"`          // managed with `earapi apikey`
+	} `json:"auth"`
+	RateLimit struct {
+		Enabled bool                     `json:"enabled"`
+		Default rateLimitRule            `json:"default"` // groups without their own rule
+		Groups  map[string]rateLimitRule `json:"groups"`  // keyed by route group: steam, netflix, imdb, …
+	} `json:"ratelimit"`
+	CORS struct {
+		Default corsRule            `json:"default"`
+		Groups  map[string]corsRule `json:"groups"` // keyed by route group; unset fields inherit default
+	} `json:"cors"`
+	Log struct {
+		Level  string `json:"level"`  // debug, info (default), warn, error
+		Format string `json:"format"` // text (default) or json
+	} `json:"log"`
+	Health struct {
+		Probes          bool `json:"probes"`            // /readyz also calls IMDb and the connected Jellyfin server
+		ProbeTTLSeconds int  `json:"probe_ttl_seconds"` // how long a probe result is reused; 0 = 60
+	} `json:"health"`
 }
@@ -2,9 +2,9 @@ package main
 
 import (
 	"fmt"
-	"net/http"
 	"strconv"
 
+	"earapi/api"
 	"earapi/tilecalc"
 
 	"github.com/gin-gonic/gin"
@@ -13,57 +13,57 @@ import (
 func tilecalcArrangeHandler(c *gin.Context) {
 	width, height, err := parseTileSizeQuery(c)
 	if err != nil {
-		c.JSON(http.StatusOK, gin.H{"success": false, "msg": err.Error()})
+		api.Fail(c, api.New(api.KindInvalidInput, err.Error(), ""))
 		return
 	}
 
 	count, err := strconv.Atoi(c.DefaultQuery("count", "0"))
 	if err != nil || count <= 0 {
-		c.JSON(http.StatusOK, gin.H{"success": false, "msg": "count must be a positive integer"})
+		api.Fail(c, api.New(api.KindInvalidInput, "count must be a positive integer", ""))
 		return
 	}
 
 	opts, err := parseTilecalcOptions(c)
 	if err != nil {
-		c.JSON(http.StatusOK, gin.H{"success": false, "msg": err.Error()})
+		api.Fail(c, api.New(api.KindInvalidInput, err.Error(), ""))
 		return
 	}
 
 	result, err := tilecalc.Arrange(width, height, count, opts)
 	if err != nil {
-		c.JSON(http.StatusOK, gin.H{"success": false, "msg": err.Error()})
+		api.Fail(c, api.New(api.KindInvalidInput, err.Error(), ""))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"success": true, "msg": "", "data": result})
+	api.OK(c, result)
 }
 
 func tilecalcCoverageHandler(c *gin.Context) {
 	width, height, err := parseTileSizeQuery(c)
 	if err != nil {
-		c.JSON(http.StatusOK, gin.H{"success": false, "msg": err.Error()})
+		api.Fail(c, api.New(api.KindInvalidInput, err.Error(), ""))
 		return
 	}
 
 	spaceW, spaceH, err := parseSpaceQuery(c)
 	if err != nil {
-		c.JSON(http.StatusOK, gin.H{"success": false, "msg": err.Error()})
+		api.Fail(c, api.New(api.KindInvalidInput, err.Error(), ""))
 		return
 	}
 
 	opts, err := parseTilecalcOptions(c)
 	if err != nil {
-		c.JSON(http.StatusOK, gin.H{"success": false, "msg": err.Error()})
+		api.Fail(c, api.New(api.KindInvalidInput, err.Error(), ""))
 		return
 	}
 
 	result, err := tilecalc.Coverage(width, height, spaceW, spaceH, opts)
 	if err != nil {
-		c.JSON(http.StatusOK, gin.H{"success": false, "msg": err.Error()})
+		api.Fail(c, api.New(api.KindInvalidInput, err.Error(), ""))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"success": true, "msg": "", "data": result})
+	api.OK(c, result)
 }
 
 func parseTileSizeQuery(c *gin.Context) (int, int, error) {
@@ -153,3 +153,16 @@ func queryBool(c *gin.Context, name string) bool {
 	}
 	return b
 }
+
+// queryInt reads an optional whole-number parameter, def when absent.
+func queryInt(c *gin.Context, name string, def, lo, hi int) (int, error) {
+	v := c.Query(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < lo || n > hi {
+		return 0, api.New(api.KindInvalidInput, fmt.Sprintf("%s must be between %d and %d", name, lo, hi), "")
+	}
+	return n, nil
+}
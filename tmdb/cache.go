@@ -0,0 +1,100 @@
+package tmdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"earapi/atomicfile"
+	"earapi/metrics"
+)
+
+// cacheEntry is one response as TMDB sent it. Keeping the raw body means a
+// change to the trimmed types never needs the cache cleared.
+type cacheEntry struct {
+	FetchedAt time.Time       `json:"fetched_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// SetCache keeps detail responses in dir for ttl; a ttl of 0 disables the
+// cache. Searches are never cached.
+func (c *Client) SetCache(dir string, ttl time.Duration) {
+	if dir != "" {
+		_ = os.MkdirAll(dir, 0o755)
+	}
+	c.cacheDir = dir
+	c.SetCacheTTL(ttl)
+}
+
+// SetCacheTTL changes how long cached responses stay fresh; 0 disables the
+// cache. Entries already on disk are judged by the new TTL.
+func (c *Client) SetCacheTTL(ttl time.Duration) { c.cacheTTL.Store(int64(ttl)) }
+
+// cachePath names the file for path and q: "/movie/603" in German becomes
+// movie-603.de.json.
+func (c *Client) cachePath(path string, q url.Values) string {
+	if c.cacheDir == "" {
+		return ""
+	}
+	name := strings.ReplaceAll(strings.Trim(path, "/"), "/", "-")
+	if lang := q.Get("language"); lang != "" {
+		name += "." + lang
+	}
+	return filepath.Join(c.cacheDir, name+".json")
+}
+
+// getCached is get through the disk cache. When TMDB can't answer, an
+// expired entry is better than nothing and is served instead.
+func (c *Client) getCached(ctx context.Context, path string, q url.Values, out any) error {
+	file := c.cachePath(path, q)
+	ttl := time.Duration(c.cacheTTL.Load())
+	if file == "" || ttl <= 0 {
+		return c.get(ctx, path, q, out)
+	}
+
+	stale, readErr := readCacheEntry(file)
+	fresh := readErr == nil && time.Since(stale.FetchedAt) <= ttl
+	metrics.CacheLookup("tmdb", fresh)
+	if fresh {
+		return json.Unmarshal(stale.Data, out)
+	}
+
+	var raw json.RawMessage
+	err := c.get(ctx, path, q, &raw)
+	if err != nil {
+		var te *Error
+		if readErr == nil && errors.As(err, &te) && te.Kind != "not_found" && te.Kind != "invalid_input" {
+			slog.WarnContext(ctx, "tmdb unavailable, serving expired cache", "path", path, "age", time.Since(stale.FetchedAt).Round(time.Second), "err", err)
+			return json.Unmarshal(stale.Data, out)
+		}
+		return err
+	}
+	if err := writeCacheEntry(file, cacheEntry{FetchedAt: time.Now().UTC(), Data: raw}); err != nil {
+		slog.WarnContext(ctx, "tmdb cache write failed", "path", file, "err", err)
+	}
+	return json.Unmarshal(raw, out)
+}
+
+func readCacheEntry(file string) (cacheEntry, error) {
+	var e cacheEntry
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return e, err
+	}
+	err = json.Unmarshal(data, &e)
+	return e, err
+}
+
+func writeCacheEntry(file string, e cacheEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return atomicfile.Write(file, data, 0o644)
+}
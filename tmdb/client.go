@@ -0,0 +1,147 @@
+// Package tmdb talks to The Movie Database's v3 REST API.
+//
+// It is a thin client over the handful of endpoints earapi exposes, returning
+// trimmed, stable types rather than TMDB's full payloads, so the API's
+// response shape doesn't move whenever TMDB adds a field.
+package tmdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"earapi/metrics"
+)
+
+const (
+	endpoint = "https://api.themoviedb.org/3"
+
+	// ImageBase prefixes TMDB's poster and profile paths; w500 is wide
+	// enough for a card without pulling the original upload.
+	ImageBase = "https://image.tmdb.org/t/p/w500"
+)
+
+// v3Key matches a v3 API key, sent as a query parameter. Anything else is
+// taken to be a v4 read access token, sent as a bearer token.
+var v3Key = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// Error is a classified TMDB failure.
+type Error struct {
+	Kind    string `json:"kind"` // invalid_input | not_found | auth | rate_limited | transport | upstream
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+	err     error
+}
+
+func (e *Error) Error() string {
+	if e.err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Kind, e.Message, e.err)
+	}
+	return fmt.Sprintf("%s: %s", e.Kind, e.Message)
+}
+func (e *Error) Unwrap() error { return e.err }
+
+func tmdbErr(kind, msg, hint string, err error) *Error {
+	return &Error{Kind: kind, Message: msg, Hint: hint, err: err}
+}
+
+// Client is a configured TMDB connection.
+type Client struct {
+	HTTP  *http.Client
+	token string
+
+	cacheDir string       // detail responses; "" disables the cache
+	cacheTTL atomic.Int64 // time.Duration; swapped on config reload
+}
+
+// NewClient returns a client for token, a v3 API key or a v4 read access
+// token. An empty token gives a client whose every call fails with auth.
+func NewClient(token string) *Client {
+	return &Client{
+		HTTP:  &http.Client{Timeout: 15 * time.Second},
+		token: strings.TrimSpace(token),
+	}
+}
+
+// Configured reports whether the client has a token to send.
+func (c *Client) Configured() bool { return c.token != "" }
+
+// get fetches path with q and decodes the JSON answer into out.
+func (c *Client) get(ctx context.Context, path string, q url.Values, out any) error {
+	if c.token == "" {
+		return tmdbErr("auth", "TMDB API token is not configured",
+			"Set apikeys.tmdbapitoken (or EARAPI_APIKEYS_TMDBAPITOKEN).", nil)
+	}
+	if q == nil {
+		q = url.Values{}
+	}
+	if v3Key.MatchString(c.token) {
+		q.Set("api_key", c.token)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+path+"?"+q.Encode(), nil)
+	if err != nil {
+		return tmdbErr("invalid_input", "could not build request", "", err)
+	}
+	if !v3Key.MatchString(c.token) {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	// Label by first path segment (/search, /movie, …): ids further down
+	// the path would give every title its own series.
+	op, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	done := metrics.Upstream("tmdb", op)
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		done(metrics.OutcomeError)
+		// The URL may carry the v3 key; only the cause is worth keeping.
+		if ue, ok := err.(*url.Error); ok {
+			err = ue.Err
+		}
+		slog.WarnContext(ctx, "tmdb request failed", "path", path, "err", err)
+		return tmdbErr("transport", "could not reach TMDB", "", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if resp.StatusCode >= 400 {
+		done(metrics.OutcomeError)
+	} else {
+		done(metrics.OutcomeOK)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return tmdbErr("auth", "TMDB rejected the API token",
+			"Check apikeys.tmdbapitoken: a v3 API key or a v4 read access token.", nil)
+	case resp.StatusCode == http.StatusNotFound:
+		return tmdbErr("not_found", "TMDB has nothing at "+path, "", nil)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return tmdbErr("rate_limited", "TMDB is rate limiting us", "Try again in a few seconds.", nil)
+	case resp.StatusCode >= 400:
+		var body struct {
+			StatusMessage string `json:"status_message"`
+		}
+		_ = json.Unmarshal(data, &body)
+		return tmdbErr("upstream", fmt.Sprintf("TMDB returned HTTP %d", resp.StatusCode), body.StatusMessage, nil)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return tmdbErr("upstream", "could not decode TMDB's response", "", err)
+	}
+	return nil
+}
+
+// image turns a TMDB image path into a full URL.
+func image(path string) string {
+	if path == "" {
+		return ""
+	}
+	return ImageBase + path
+}
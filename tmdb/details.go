@@ -0,0 +1,403 @@
+package tmdb
+
+import (
+	"cmp"
+	"context"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Movie is the useful subset of /movie/{id} with its credits.
+type Movie struct {
+	ID               int      `json:"id"`
+	IMDbID           string   `json:"imdb_id,omitempty"`
+	Title            string   `json:"title"`
+	OriginalTitle    string   `json:"original_title,omitempty"`
+	Tagline          string   `json:"tagline,omitempty"`
+	Overview         string   `json:"overview,omitempty"`
+	ReleaseDate      string   `json:"release_date,omitempty"`
+	Year             int      `json:"year,omitempty"`
+	RuntimeMinutes   int      `json:"runtime_minutes,omitempty"`
+	Status           string   `json:"status,omitempty"`
+	Genres           []string `json:"genres"`
+	OriginalLanguage string   `json:"original_language,omitempty"`
+	Countries        []string `json:"countries"`
+	Collection       string   `json:"collection,omitempty"`
+	Homepage         string   `json:"homepage,omitempty"`
+	Poster           string   `json:"poster,omitempty"`
+	Backdrop         string   `json:"backdrop,omitempty"`
+	Budget           int64    `json:"budget,omitempty"`
+	Revenue          int64    `json:"revenue,omitempty"`
+	Popularity       float64  `json:"popularity"`
+	VoteAverage      float64  `json:"vote_average"`
+	VoteCount        int      `json:"vote_count"`
+	Credits          Credits  `json:"credits"`
+}
+
+// TV is the useful subset of /tv/{id} with the latest season's credits.
+type TV struct {
+	ID               int      `json:"id"`
+	IMDbID           string   `json:"imdb_id,omitempty"`
+	Title            string   `json:"title"`
+	OriginalTitle    string   `json:"original_title,omitempty"`
+	Tagline          string   `json:"tagline,omitempty"`
+	Overview         string   `json:"overview,omitempty"`
+	FirstAirDate     string   `json:"first_air_date,omitempty"`
+	LastAirDate      string   `json:"last_air_date,omitempty"`
+	Year             int      `json:"year,omitempty"`
+	Status           string   `json:"status,omitempty"`
+	InProduction     bool     `json:"in_production"`
+	Seasons          int      `json:"seasons"`
+	Episodes         int      `json:"episodes"`
+	EpisodeRuntime   int      `json:"episode_runtime_minutes,omitempty"`
+	Genres           []string `json:"genres"`
+	Networks         []string `json:"networks"`
+	CreatedBy        []string `json:"created_by"`
+	OriginalLanguage string   `json:"original_language,omitempty"`
+	Countries        []string `json:"countries"`
+	Homepage         string   `json:"homepage,omitempty"`
+	Poster           string   `json:"poster,omitempty"`
+	Backdrop         string   `json:"backdrop,omitempty"`
+	Popularity       float64  `json:"popularity"`
+	VoteAverage      float64  `json:"vote_average"`
+	VoteCount        int      `json:"vote_count"`
+	Credits          Credits  `json:"credits"`
+}
+
+// Credits are a title's cast in billing order and its crew, key jobs first.
+type Credits struct {
+	Cast []CastMember `json:"cast"`
+	Crew []CrewMember `json:"crew"`
+}
+
+type CastMember struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Character string `json:"character,omitempty"`
+	Image     string `json:"image,omitempty"`
+}
+
+type CrewMember struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Job        string `json:"job"`
+	Department string `json:"department"`
+	Image      string `json:"image,omitempty"`
+}
+
+// Person is the useful subset of /person/{id} with everything they were in.
+type Person struct {
+	ID           int          `json:"id"`
+	IMDbID       string       `json:"imdb_id,omitempty"`
+	Name         string       `json:"name"`
+	Department   string       `json:"known_for_department,omitempty"`
+	Biography    string       `json:"biography,omitempty"`
+	Birthday     string       `json:"birthday,omitempty"`
+	Deathday     string       `json:"deathday,omitempty"`
+	PlaceOfBirth string       `json:"place_of_birth,omitempty"`
+	AlsoKnownAs  []string     `json:"also_known_as"`
+	Homepage     string       `json:"homepage,omitempty"`
+	Image        string       `json:"image,omitempty"`
+	Popularity   float64      `json:"popularity"`
+	Credits      []PersonRole `json:"credits"` // newest first
+}
+
+// PersonRole is one title a person acted in or worked on. Someone who both
+// directed and starred in a film has a role for each.
+type PersonRole struct {
+	ID          int     `json:"id"`
+	Type        string  `json:"type"` // movie | tv
+	Title       string  `json:"title"`
+	Date        string  `json:"date,omitempty"`
+	Year        int     `json:"year,omitempty"`
+	Character   string  `json:"character,omitempty"` // cast
+	Job         string  `json:"job,omitempty"`       // crew
+	Department  string  `json:"department"`          // "Acting" for cast
+	Episodes    int     `json:"episodes,omitempty"`  // tv
+	Image       string  `json:"image,omitempty"`
+	VoteAverage float64 `json:"vote_average,omitempty"`
+	VoteCount   int     `json:"vote_count,omitempty"`
+}
+
+type named struct {
+	Name string `json:"name"`
+}
+
+type rawCredit struct {
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	Title       string  `json:"title"`
+	MediaType   string  `json:"media_type"`
+	Character   string  `json:"character"`
+	Job         string  `json:"job"`
+	Department  string  `json:"department"`
+	Order       int     `json:"order"`
+	ProfilePath string  `json:"profile_path"`
+	PosterPath  string  `json:"poster_path"`
+	ReleaseDate string  `json:"release_date"`
+	FirstAir    string  `json:"first_air_date"`
+	Episodes    int     `json:"episode_count"`
+	VoteAverage float64 `json:"vote_average"`
+	VoteCount   int     `json:"vote_count"`
+}
+
+type rawCredits struct {
+	Cast []rawCredit `json:"cast"`
+	Crew []rawCredit `json:"crew"`
+}
+
+// keyJobs rank first in a crew list, lowest first; everyone else follows in
+// TMDB's order.
+var keyJobs = map[string]int{
+	"Director": 1, "Creator": 1, "Screenplay": 2, "Writer": 2, "Novel": 3, "Story": 3,
+	"Producer": 4, "Executive Producer": 5, "Original Music Composer": 6, "Director of Photography": 7, "Editor": 8,
+}
+
+func (r rawCredits) credits() Credits {
+	out := Credits{Cast: []CastMember{}, Crew: []CrewMember{}}
+	slices.SortStableFunc(r.Cast, func(a, b rawCredit) int { return cmp.Compare(a.Order, b.Order) })
+	for _, c := range r.Cast {
+		out.Cast = append(out.Cast, CastMember{ID: c.ID, Name: c.Name, Character: c.Character, Image: image(c.ProfilePath)})
+	}
+	slices.SortStableFunc(r.Crew, func(a, b rawCredit) int {
+		return cmp.Compare(cmp.Or(keyJobs[a.Job], 99), cmp.Or(keyJobs[b.Job], 99))
+	})
+	for _, c := range r.Crew {
+		out.Crew = append(out.Crew, CrewMember{ID: c.ID, Name: c.Name, Job: c.Job, Department: c.Department, Image: image(c.ProfilePath)})
+	}
+	return out
+}
+
+// Trim caps the cast and crew; a negative limit keeps them all.
+func (c *Credits) Trim(cast, crew int) {
+	if cast >= 0 && len(c.Cast) > cast {
+		c.Cast = c.Cast[:cast]
+	}
+	if crew >= 0 && len(c.Crew) > crew {
+		c.Crew = c.Crew[:crew]
+	}
+}
+
+func names(in []named) []string {
+	out := make([]string, 0, len(in))
+	for _, n := range in {
+		out = append(out, n.Name)
+	}
+	return out
+}
+
+func year(date string) int {
+	if len(date) < 4 {
+		return 0
+	}
+	y, _ := strconv.Atoi(date[:4])
+	return y
+}
+
+func detailQuery(language, appendTo string) (url.Values, error) {
+	q := url.Values{"append_to_response": {appendTo}}
+	if err := checkLanguage(language); err != nil {
+		return nil, err
+	}
+	if language != "" {
+		q.Set("language", language)
+	}
+	return q, nil
+}
+
+func checkID(id int) error {
+	if id <= 0 {
+		return tmdbErr("invalid_input", "id must be a positive TMDB id", "", nil)
+	}
+	return nil
+}
+
+// Movie fetches a movie and its credits.
+func (c *Client) Movie(ctx context.Context, id int, language string) (*Movie, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	q, err := detailQuery(language, "credits")
+	if err != nil {
+		return nil, err
+	}
+	var raw struct {
+		ID                  int     `json:"id"`
+		IMDbID              string  `json:"imdb_id"`
+		Title               string  `json:"title"`
+		OriginalTitle       string  `json:"original_title"`
+		Tagline             string  `json:"tagline"`
+		Overview            string  `json:"overview"`
+		ReleaseDate         string  `json:"release_date"`
+		Runtime             int     `json:"runtime"`
+		Status              string  `json:"status"`
+		Genres              []named `json:"genres"`
+		OriginalLanguage    string  `json:"original_language"`
+		ProductionCountries []struct {
+			ISO string `json:"iso_3166_1"`
+		} `json:"production_countries"`
+		BelongsToCollection *named     `json:"belongs_to_collection"`
+		Homepage            string     `json:"homepage"`
+		PosterPath          string     `json:"poster_path"`
+		BackdropPath        string     `json:"backdrop_path"`
+		Budget              int64      `json:"budget"`
+		Revenue             int64      `json:"revenue"`
+		Popularity          float64    `json:"popularity"`
+		VoteAverage         float64    `json:"vote_average"`
+		VoteCount           int        `json:"vote_count"`
+		Credits             rawCredits `json:"credits"`
+	}
+	if err := c.getCached(ctx, "/movie/"+strconv.Itoa(id), q, &raw); err != nil {
+		return nil, err
+	}
+	m := &Movie{
+		ID: raw.ID, IMDbID: raw.IMDbID, Title: raw.Title, OriginalTitle: raw.OriginalTitle,
+		Tagline: raw.Tagline, Overview: raw.Overview, ReleaseDate: raw.ReleaseDate, Year: year(raw.ReleaseDate),
+		RuntimeMinutes: raw.Runtime, Status: raw.Status, Genres: names(raw.Genres),
+		OriginalLanguage: raw.OriginalLanguage, Countries: []string{}, Homepage: raw.Homepage,
+		Poster: image(raw.PosterPath), Backdrop: image(raw.BackdropPath), Budget: raw.Budget, Revenue: raw.Revenue,
+		Popularity: raw.Popularity, VoteAverage: raw.VoteAverage, VoteCount: raw.VoteCount,
+		Credits: raw.Credits.credits(),
+	}
+	for _, pc := range raw.ProductionCountries {
+		m.Countries = append(m.Countries, pc.ISO)
+	}
+	if raw.BelongsToCollection != nil {
+		m.Collection = raw.BelongsToCollection.Name
+	}
+	if m.OriginalTitle == m.Title {
+		m.OriginalTitle = ""
+	}
+	return m, nil
+}
+
+// TV fetches a show and the credits of its latest season.
+func (c *Client) TV(ctx context.Context, id int, language string) (*TV, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	q, err := detailQuery(language, "credits,external_ids")
+	if err != nil {
+		return nil, err
+	}
+	var raw struct {
+		ID               int        `json:"id"`
+		Name             string     `json:"name"`
+		OriginalName     string     `json:"original_name"`
+		Tagline          string     `json:"tagline"`
+		Overview         string     `json:"overview"`
+		FirstAirDate     string     `json:"first_air_date"`
+		LastAirDate      string     `json:"last_air_date"`
+		Status           string     `json:"status"`
+		InProduction     bool       `json:"in_production"`
+		Seasons          int        `json:"number_of_seasons"`
+		Episodes         int        `json:"number_of_episodes"`
+		EpisodeRunTime   []int      `json:"episode_run_time"`
+		Genres           []named    `json:"genres"`
+		Networks         []named    `json:"networks"`
+		CreatedBy        []named    `json:"created_by"`
+		OriginalLanguage string     `json:"original_language"`
+		OriginCountry    []string   `json:"origin_country"`
+		Homepage         string     `json:"homepage"`
+		PosterPath       string     `json:"poster_path"`
+		BackdropPath     string     `json:"backdrop_path"`
+		Popularity       float64    `json:"popularity"`
+		VoteAverage      float64    `json:"vote_average"`
+		VoteCount        int        `json:"vote_count"`
+		Credits          rawCredits `json:"credits"`
+		ExternalIDs      struct {
+			IMDbID string `json:"imdb_id"`
+		} `json:"external_ids"`
+	}
+	if err := c.getCached(ctx, "/tv/"+strconv.Itoa(id), q, &raw); err != nil {
+		return nil, err
+	}
+	t := &TV{
+		ID: raw.ID, IMDbID: raw.ExternalIDs.IMDbID, Title: raw.Name, OriginalTitle: raw.OriginalName,
+		Tagline: raw.Tagline, Overview: raw.Overview, FirstAirDate: raw.FirstAirDate, LastAirDate: raw.LastAirDate,
+		Year: year(raw.FirstAirDate), Status: raw.Status, InProduction: raw.InProduction,
+		Seasons: raw.Seasons, Episodes: raw.Episodes, Genres: names(raw.Genres), Networks: names(raw.Networks),
+		CreatedBy: names(raw.CreatedBy), OriginalLanguage: raw.OriginalLanguage, Countries: raw.OriginCountry,
+		Homepage: raw.Homepage, Poster: image(raw.PosterPath), Backdrop: image(raw.BackdropPath),
+		Popularity: raw.Popularity, VoteAverage: raw.VoteAverage, VoteCount: raw.VoteCount,
+		Credits: raw.Credits.credits(),
+	}
+	if len(raw.EpisodeRunTime) > 0 {
+		t.EpisodeRuntime = raw.EpisodeRunTime[0]
+	}
+	if t.Countries == nil {
+		t.Countries = []string{}
+	}
+	if t.OriginalTitle == t.Title {
+		t.OriginalTitle = ""
+	}
+	return t, nil
+}
+
+// Person fetches a person and every movie and show they're credited on.
+func (c *Client) Person(ctx context.Context, id int, language string) (*Person, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	q, err := detailQuery(language, "combined_credits")
+	if err != nil {
+		return nil, err
+	}
+	var raw struct {
+		ID                 int        `json:"id"`
+		IMDbID             string     `json:"imdb_id"`
+		Name               string     `json:"name"`
+		KnownForDepartment string     `json:"known_for_department"`
+		Biography          string     `json:"biography"`
+		Birthday           string     `json:"birthday"`
+		Deathday           string     `json:"deathday"`
+		PlaceOfBirth       string     `json:"place_of_birth"`
+		AlsoKnownAs        []string   `json:"also_known_as"`
+		Homepage           string     `json:"homepage"`
+		ProfilePath        string     `json:"profile_path"`
+		Popularity         float64    `json:"popularity"`
+		CombinedCredits    rawCredits `json:"combined_credits"`
+	}
+	if err := c.getCached(ctx, "/person/"+strconv.Itoa(id), q, &raw); err != nil {
+		return nil, err
+	}
+	p := &Person{
+		ID: raw.ID, IMDbID: raw.IMDbID, Name: raw.Name, Department: raw.KnownForDepartment,
+		Biography: raw.Biography, Birthday: raw.Birthday, Deathday: raw.Deathday, PlaceOfBirth: raw.PlaceOfBirth,
+		AlsoKnownAs: raw.AlsoKnownAs, Homepage: raw.Homepage, Image: image(raw.ProfilePath),
+		Popularity: raw.Popularity, Credits: []PersonRole{},
+	}
+	if p.AlsoKnownAs == nil {
+		p.AlsoKnownAs = []string{}
+	}
+	role := func(r rawCredit) PersonRole {
+		out := PersonRole{
+			ID: r.ID, Type: r.MediaType, Title: cmp.Or(r.Title, r.Name), Date: cmp.Or(r.ReleaseDate, r.FirstAir),
+			Character: r.Character, Job: r.Job, Department: r.Department, Episodes: r.Episodes,
+			Image: image(r.PosterPath), VoteAverage: r.VoteAverage, VoteCount: r.VoteCount,
+		}
+		out.Year = year(out.Date)
+		return out
+	}
+	for _, r := range raw.CombinedCredits.Cast {
+		cast := role(r)
+		cast.Department = "Acting"
+		p.Credits = append(p.Credits, cast)
+	}
+	for _, r := range raw.CombinedCredits.Crew {
+		p.Credits = append(p.Credits, role(r))
+	}
+	// Newest first; undated (announced) titles lead, as they're the newest.
+	slices.SortStableFunc(p.Credits, func(a, b PersonRole) int {
+		switch {
+		case a.Date == "" && b.Date != "":
+			return -1
+		case a.Date != "" && b.Date == "":
+			return 1
+		}
+		return strings.Compare(b.Date, a.Date)
+	})
+	return p, nil
+}
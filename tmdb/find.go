@@ -0,0 +1,41 @@
+package tmdb
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strconv"
+)
+
+var imdbIDPattern = regexp.MustCompile(`^tt\d{7,10}$`)
+
+// FindIMDb looks up the movie or show with an IMDb id. TMDB can list both
+// for one id; prefer picks which wins (TypeMovie or TypeTV), movies when
+// empty. A title TMDB doesn't know fails with not_found.
+func (c *Client) FindIMDb(ctx context.Context, imdbID, prefer string) (*Result, error) {
+	if !imdbIDPattern.MatchString(imdbID) {
+		return nil, tmdbErr("invalid_input", strconv.Quote(imdbID)+" is not an IMDb title id", "", nil)
+	}
+	var raw struct {
+		Movies []rawResult `json:"movie_results"`
+		TV     []rawResult `json:"tv_results"`
+	}
+	if err := c.getCached(ctx, "/find/"+imdbID, url.Values{"external_source": {"imdb_id"}}, &raw); err != nil {
+		return nil, err
+	}
+	first, second := raw.Movies, raw.TV
+	firstType, secondType := TypeMovie, TypeTV
+	if prefer == TypeTV {
+		first, second = second, first
+		firstType, secondType = secondType, firstType
+	}
+	switch {
+	case len(first) > 0:
+		r := first[0].result(firstType)
+		return &r, nil
+	case len(second) > 0:
+		r := second[0].result(secondType)
+		return &r, nil
+	}
+	return nil, tmdbErr("not_found", "TMDB has no movie or show for "+imdbID, "", nil)
+}
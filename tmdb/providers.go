@@ -0,0 +1,99 @@
+package tmdb
+
+import (
+	"cmp"
+	"context"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ProviderAttribution must accompany watch provider data: TMDB gets it from
+// JustWatch and its terms require the credit.
+const ProviderAttribution = "Streaming availability from JustWatch"
+
+var regionPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// Providers is where a title can be watched in one region. Link is TMDB's
+// page for the title there, which links on to each service.
+type Providers struct {
+	Region string     `json:"region"`
+	Link   string     `json:"link,omitempty"`
+	Stream []Provider `json:"stream"` // subscription
+	Free   []Provider `json:"free"`
+	Ads    []Provider `json:"ads"`
+	Rent   []Provider `json:"rent"`
+	Buy    []Provider `json:"buy"`
+}
+
+type Provider struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Logo string `json:"logo,omitempty"`
+}
+
+type rawProvider struct {
+	ProviderID      int    `json:"provider_id"`
+	ProviderName    string `json:"provider_name"`
+	LogoPath        string `json:"logo_path"`
+	DisplayPriority int    `json:"display_priority"`
+}
+
+func providers(in []rawProvider) []Provider {
+	slices.SortStableFunc(in, func(a, b rawProvider) int { return cmp.Compare(a.DisplayPriority, b.DisplayPriority) })
+	out := make([]Provider, 0, len(in))
+	for _, p := range in {
+		out = append(out, Provider{ID: p.ProviderID, Name: p.ProviderName, Logo: image(p.LogoPath)})
+	}
+	return out
+}
+
+// WatchProviders fetches where a movie or show (typ TypeMovie or TypeTV)
+// can be watched, by region. With region set, only that region is
+// returned, with empty lists when TMDB knows of nowhere to watch it there.
+func (c *Client) WatchProviders(ctx context.Context, typ string, id int, region string) ([]Providers, error) {
+	if typ != TypeMovie && typ != TypeTV {
+		return nil, tmdbErr("invalid_input", "watch providers are only known for movies and tv", "", nil)
+	}
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	region = strings.ToUpper(region)
+	if region != "" && !regionPattern.MatchString(region) {
+		return nil, tmdbErr("invalid_input", "region "+strconv.Quote(region)+" is not a two-letter country code", "Use US, GB, DE and so on.", nil)
+	}
+
+	var raw struct {
+		Results map[string]struct {
+			Link     string        `json:"link"`
+			Flatrate []rawProvider `json:"flatrate"`
+			Free     []rawProvider `json:"free"`
+			Ads      []rawProvider `json:"ads"`
+			Rent     []rawProvider `json:"rent"`
+			Buy      []rawProvider `json:"buy"`
+		} `json:"results"`
+	}
+	if err := c.getCached(ctx, "/"+typ+"/"+strconv.Itoa(id)+"/watch/providers", nil, &raw); err != nil {
+		return nil, err
+	}
+
+	out := []Providers{}
+	for _, r := range slices.Sorted(func(yield func(string) bool) {
+		for k := range raw.Results {
+			if (region == "" || k == region) && !yield(k) {
+				return
+			}
+		}
+	}) {
+		v := raw.Results[r]
+		out = append(out, Providers{
+			Region: r, Link: v.Link, Stream: providers(v.Flatrate), Free: providers(v.Free),
+			Ads: providers(v.Ads), Rent: providers(v.Rent), Buy: providers(v.Buy),
+		})
+	}
+	if region != "" && len(out) == 0 {
+		out = append(out, Providers{Region: region, Stream: []Provider{}, Free: []Provider{}, Ads: []Provider{}, Rent: []Provider{}, Buy: []Provider{}})
+	}
+	return out, nil
+}
@@ -0,0 +1,193 @@
+package tmdb
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Media types. TypeMulti searches the other three at once.
+const (
+	TypeMulti  = "multi"
+	TypeMovie  = "movie"
+	TypeTV     = "tv"
+	TypePerson = "person"
+)
+
+// MaxPage is the last page TMDB will serve for any search.
+const MaxPage = 500
+
+var languagePattern = regexp.MustCompile(`^[a-z]{2}(-[A-Z]{2})?$`)
+
+// checkLanguage accepts "" or a language code TMDB understands.
+func checkLanguage(language string) error {
+	if language != "" && !languagePattern.MatchString(language) {
+		return tmdbErr("invalid_input", "language "+strconv.Quote(language)+" is not a language code",
+			"Use an ISO 639-1 code, optionally with a region: en, de, pt-BR.", nil)
+	}
+	return nil
+}
+
+// SearchOptions narrows a search. Zero values mean TMDB's defaults.
+type SearchOptions struct {
+	Query        string
+	Type         string // TypeMulti when empty
+	Year         int    // release year for movies, first air year for TV
+	Language     string // ISO 639-1, optionally with a region: "de" or "pt-BR"
+	Page         int    // 1-MaxPage, 1 when zero
+	IncludeAdult bool
+}
+
+// SearchPage is one page of results.
+type SearchPage struct {
+	Page         int      `json:"page"`
+	TotalPages   int      `json:"total_pages"`
+	TotalResults int      `json:"total_results"`
+	Results      []Result `json:"results"`
+}
+
+// Result is a movie, TV show or person. People carry their name in Title
+// and their best-known work in KnownFor.
+type Result struct {
+	ID               int      `json:"id"`
+	Type             string   `json:"type"`
+	Title            string   `json:"title"`
+	OriginalTitle    string   `json:"original_title,omitempty"`
+	Overview         string   `json:"overview,omitempty"`
+	Date             string   `json:"date,omitempty"` // release or first air date
+	Year             int      `json:"year,omitempty"`
+	Image            string   `json:"image,omitempty"` // poster, or profile photo for people
+	Backdrop         string   `json:"backdrop,omitempty"`
+	OriginalLanguage string   `json:"original_language,omitempty"`
+	Popularity       float64  `json:"popularity"`
+	VoteAverage      float64  `json:"vote_average,omitempty"`
+	VoteCount        int      `json:"vote_count,omitempty"`
+	Department       string   `json:"known_for_department,omitempty"`
+	KnownFor         []Result `json:"known_for,omitempty"`
+}
+
+type rawResult struct {
+	ID               int         `json:"id"`
+	MediaType        string      `json:"media_type"`
+	Title            string      `json:"title"`
+	Name             string      `json:"name"`
+	OriginalTitle    string      `json:"original_title"`
+	OriginalName     string      `json:"original_name"`
+	Overview         string      `json:"overview"`
+	ReleaseDate      string      `json:"release_date"`
+	FirstAirDate     string      `json:"first_air_date"`
+	PosterPath       string      `json:"poster_path"`
+	ProfilePath      string      `json:"profile_path"`
+	BackdropPath     string      `json:"backdrop_path"`
+	OriginalLanguage string      `json:"original_language"`
+	Popularity       float64     `json:"popularity"`
+	VoteAverage      float64     `json:"vote_average"`
+	VoteCount        int         `json:"vote_count"`
+	Department       string      `json:"known_for_department"`
+	KnownFor         []rawResult `json:"known_for"`
+}
+
+// result trims r; typ fills in media_type, which single-type searches omit.
+func (r rawResult) result(typ string) Result {
+	if r.MediaType != "" {
+		typ = r.MediaType
+	}
+	out := Result{
+		ID:               r.ID,
+		Type:             typ,
+		Title:            r.Title,
+		OriginalTitle:    r.OriginalTitle,
+		Overview:         r.Overview,
+		Date:             r.ReleaseDate,
+		Image:            image(r.PosterPath),
+		Backdrop:         image(r.BackdropPath),
+		OriginalLanguage: r.OriginalLanguage,
+		Popularity:       r.Popularity,
+		VoteAverage:      r.VoteAverage,
+		VoteCount:        r.VoteCount,
+		Department:       r.Department,
+	}
+	switch typ {
+	case TypeTV:
+		out.Title, out.OriginalTitle, out.Date = r.Name, r.OriginalName, r.FirstAirDate
+	case TypePerson:
+		out.Title, out.Image = r.Name, image(r.ProfilePath)
+		for _, k := range r.KnownFor {
+			out.KnownFor = append(out.KnownFor, k.result(""))
+		}
+	}
+	if out.OriginalTitle == out.Title {
+		out.OriginalTitle = ""
+	}
+	out.Year = year(out.Date)
+	return out
+}
+
+// Search runs one page of a title or name search.
+//
+// TMDB's combined search takes no year, so with TypeMulti the year is applied
+// to the page after the fact: people are dropped along with titles from
+// other years, and the totals still count TMDB's unfiltered matches.
+func (c *Client) Search(ctx context.Context, opts SearchOptions) (*SearchPage, error) {
+	query := strings.TrimSpace(opts.Query)
+	if query == "" {
+		return nil, tmdbErr("invalid_input", "a search query is required", "Pass q=<title or name>.", nil)
+	}
+	typ := opts.Type
+	if typ == "" {
+		typ = TypeMulti
+	}
+	page := opts.Page
+	if page == 0 {
+		page = 1
+	}
+
+	q := url.Values{"query": {query}, "page": {strconv.Itoa(page)}, "include_adult": {strconv.FormatBool(opts.IncludeAdult)}}
+	switch typ {
+	case TypeMulti, TypePerson:
+		if opts.Year != 0 && typ == TypePerson {
+			return nil, tmdbErr("invalid_input", "year does not apply to people", "Drop year, or search type=movie or tv.", nil)
+		}
+	case TypeMovie:
+		if opts.Year != 0 {
+			q.Set("primary_release_year", strconv.Itoa(opts.Year))
+		}
+	case TypeTV:
+		if opts.Year != 0 {
+			q.Set("first_air_date_year", strconv.Itoa(opts.Year))
+		}
+	default:
+		return nil, tmdbErr("invalid_input", "unknown search type "+strconv.Quote(typ), "Use movie, tv, person or multi.", nil)
+	}
+	if page < 1 || page > MaxPage {
+		return nil, tmdbErr("invalid_input", "page must be between 1 and "+strconv.Itoa(MaxPage), "", nil)
+	}
+	if err := checkLanguage(opts.Language); err != nil {
+		return nil, err
+	}
+	if opts.Language != "" {
+		q.Set("language", opts.Language)
+	}
+
+	var raw struct {
+		Page         int         `json:"page"`
+		TotalPages   int         `json:"total_pages"`
+		TotalResults int         `json:"total_results"`
+		Results      []rawResult `json:"results"`
+	}
+	if err := c.get(ctx, "/search/"+typ, q, &raw); err != nil {
+		return nil, err
+	}
+
+	out := &SearchPage{Page: raw.Page, TotalPages: raw.TotalPages, TotalResults: raw.TotalResults, Results: []Result{}}
+	for _, r := range raw.Results {
+		res := r.result(typ)
+		if typ == TypeMulti && opts.Year != 0 && res.Year != opts.Year {
+			continue
+		}
+		out.Results = append(out.Results, res)
+	}
+	return out, nil
+}
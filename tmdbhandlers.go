@@ -1,25 +1,182 @@
 package main
 
 import (
-	"net/http"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 
-	"github.com/earentir/tmdbapidata"
 	"github.com/gin-gonic/gin"
+
+	"earapi/api"
+	"earapi/tmdb"
 )
 
+// tmdbClient serves /tmdb/v1. The token is read once at startup.
+var tmdbClient = tmdb.NewClient("")
+
+// tmdbAPIErr maps a tmdb.Error onto the api error kinds.
+func tmdbAPIErr(err error) error {
+	var te *tmdb.Error
+	if !errors.As(err, &te) {
+		return err
+	}
+	kind := api.KindUpstream
+	switch te.Kind {
+	case "invalid_input":
+		kind = api.KindInvalidInput
+	case "not_found":
+		kind = api.KindNotFound
+	case "auth":
+		kind = api.KindUnavailable // our token, not the caller's
+	case "rate_limited":
+		kind = api.KindRateLimited
+	}
+	return api.New(kind, te.Message, te.Hint)
+}
+
+// movieSearchHandler searches movies, TV and people, a page at a time.
 func movieSearchHandler(c *gin.Context) {
-    // Accept both `q` and `query` params; only default if both are empty
-    search := c.Query("q")
-    if search == "" {
-        search = c.Query("query")
-    }
-    if search == "" {
-        search = "Blade Runner"
-    }
-	resulttype, resulttitle, mediadata := tmdbapidata.SearchTMDB(config.Apikeys.Tmdbapitoken, search)
-
-	c.JSON(http.StatusOK, gin.H{
-		resulttype: resulttitle,
-		"info":     mediadata,
+	// Accept both `q` and `query` params
+	search := c.Query("q")
+	if search == "" {
+		search = c.Query("query")
+	}
+	if strings.TrimSpace(search) == "" {
+		api.Fail(c, api.New(api.KindInvalidInput, "q is required", "Pass q=<title or name>, e.g. q=Blade%20Runner."))
+		return
+	}
+
+	opts := tmdb.SearchOptions{Query: search, Language: c.Query("language"), IncludeAdult: queryBool(c, "adult")}
+	switch t := strings.ToLower(c.Query("type")); t {
+	case "", "all", tmdb.TypeMulti:
+		opts.Type = tmdb.TypeMulti
+	default:
+		opts.Type = t
+	}
+	var err error
+	if opts.Year, err = queryInt(c, "year", 0, 1870, 2100); err != nil {
+		api.Fail(c, err)
+		return
+	}
+	if opts.Page, err = queryInt(c, "page", 1, 1, tmdb.MaxPage); err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	page, err := tmdbClient.Search(c.Request.Context(), opts)
+	if err != nil {
+		api.Fail(c, tmdbAPIErr(err))
+		return
+	}
+	api.OK(c, gin.H{
+		"query":         strings.TrimSpace(search),
+		"type":          opts.Type,
+		"page":          page.Page,
+		"total_pages":   page.TotalPages,
+		"total_results": page.TotalResults,
+		"results":       page.Results,
 	})
 }
+
+// tmdbIDParam reads the :id path parameter.
+func tmdbIDParam(c *gin.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		return 0, api.New(api.KindInvalidInput, fmt.Sprintf("%q is not a TMDB id", c.Param("id")), "Take the id from /tmdb/v1/search.")
+	}
+	return id, nil
+}
+
+// tmdbCreditLimits reads how much of the cast and crew to return.
+func tmdbCreditLimits(c *gin.Context) (cast, crew int, err error) {
+	if cast, err = queryInt(c, "cast", 20, 0, 500); err != nil {
+		return 0, 0, err
+	}
+	crew, err = queryInt(c, "crew", 20, 0, 500)
+	return cast, crew, err
+}
+
+func tmdbMovieHandler(c *gin.Context) {
+	id, err := tmdbIDParam(c)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	cast, crew, err := tmdbCreditLimits(c)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	m, err := tmdbClient.Movie(c.Request.Context(), id, c.Query("language"))
+	if err != nil {
+		api.Fail(c, tmdbAPIErr(err))
+		return
+	}
+	m.Credits.Trim(cast, crew)
+	api.OK(c, m)
+}
+
+func tmdbTVHandler(c *gin.Context) {
+	id, err := tmdbIDParam(c)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	cast, crew, err := tmdbCreditLimits(c)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	t, err := tmdbClient.TV(c.Request.Context(), id, c.Query("language"))
+	if err != nil {
+		api.Fail(c, tmdbAPIErr(err))
+		return
+	}
+	t.Credits.Trim(cast, crew)
+	api.OK(c, t)
+}
+
+func tmdbPersonHandler(c *gin.Context) {
+	id, err := tmdbIDParam(c)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	limit, err := queryInt(c, "credits", 50, 0, 1000)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	p, err := tmdbClient.Person(c.Request.Context(), id, c.Query("language"))
+	if err != nil {
+		api.Fail(c, tmdbAPIErr(err))
+		return
+	}
+	if len(p.Credits) > limit {
+		p.Credits = p.Credits[:limit]
+	}
+	api.OK(c, p)
+}
+
+// tmdbProvidersHandler answers where a movie or show of typ can be watched.
+func tmdbProvidersHandler(typ string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := tmdbIDParam(c)
+		if err != nil {
+			api.Fail(c, err)
+			return
+		}
+		regions, err := tmdbClient.WatchProviders(c.Request.Context(), typ, id, c.Query("region"))
+		if err != nil {
+			api.Fail(c, tmdbAPIErr(err))
+			return
+		}
+		api.OK(c, gin.H{
+			"id":          id,
+			"type":        typ,
+			"regions":     regions,
+			"attribution": tmdb.ProviderAttribution,
+		})
+	}
+}
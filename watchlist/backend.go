@@ -0,0 +1,108 @@
+package watchlist
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Buckets the store writes to. Each kind of handle lives in its own namespace
+// so a watchlist id can never be read back as a comparison.
+const (
+	bucketWatchlists = "watchlists"
+	bucketCompares   = "compares"
+)
+
+// Record is one stored value plus the bookkeeping needed to expire it.
+type Record struct {
+	CreatedAt time.Time       `json:"created_at"`
+	ExpiresAt time.Time       `json:"expires_at,omitzero"` // zero keeps it forever
+	Data      json.RawMessage `json:"data"`
+}
+
+// Expired reports whether the record is past its retention window.
+func (r Record) Expired(now time.Time) bool {
+	return !r.ExpiresAt.IsZero() && now.After(r.ExpiresAt)
+}
+
+// Backend persists stored watchlists and comparisons. Values are opaque JSON:
+// the Store owns encoding and retention, a Backend only keeps bytes safe.
+type Backend interface {
+	Put(bucket, id string, rec Record) error
+	Get(bucket, id string) (Record, bool, error)
+	Delete(bucket, id string) (bool, error)
+	// Purge drops every record that has expired by now and returns how many.
+	Purge(now time.Time) (int, error)
+	Close() error
+}
+
+// OpenBackend builds the backend named by kind. "bolt" (the default) keeps
+// handles in an embedded database at path; "memory" keeps them only for the
+// life of the process, which is what earlier versions did.
+func OpenBackend(kind, path string) (Backend, error) {
+	switch kind {
+	case "", "bolt":
+		return OpenBolt(path)
+	case "memory":
+		return NewMemoryBackend(), nil
+	}
+	return nil, fmt.Errorf("unknown watchlist store backend %q", kind)
+}
+
+// memoryBackend keeps records in process memory.
+type memoryBackend struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string]Record
+}
+
+// NewMemoryBackend returns a Backend that forgets everything on restart.
+func NewMemoryBackend() Backend {
+	return &memoryBackend{buckets: map[string]map[string]Record{}}
+}
+
+func (m *memoryBackend) Put(bucket, id string, rec Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.buckets[bucket]
+	if !ok {
+		b = map[string]Record{}
+		m.buckets[bucket] = b
+	}
+	b[id] = rec
+	return nil
+}
+
+func (m *memoryBackend) Get(bucket, id string) (Record, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	rec, ok := m.buckets[bucket][id]
+	return rec, ok, nil
+}
+
+func (m *memoryBackend) Delete(bucket, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.buckets[bucket][id]; !ok {
+		return false, nil
+	}
+	delete(m.buckets[bucket], id)
+	return true, nil
+}
+
+func (m *memoryBackend) Purge(now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, b := range m.buckets {
+		for id, rec := range b {
+			if rec.Expired(now) {
+				delete(b, id)
+				n++
+			}
+		}
+	}
+	return n, nil
+}
+
+func (m *memoryBackend) Close() error { return nil }
@@ -0,0 +1,128 @@
+package watchlist
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltBackend keeps records in a single-file embedded database, so handles
+// handed to the UI survive deploys and restarts.
+type boltBackend struct {
+	db *bolt.DB
+}
+
+// OpenBolt opens (or creates) the database at path. The file is locked while
+// open; a second process pointed at the same path fails after a short wait
+// rather than hanging.
+func OpenBolt(path string) (Backend, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, err
+		}
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{bucketWatchlists, bucketCompares} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &boltBackend{db: db}, nil
+}
+
+func (b *boltBackend) Put(bucket, id string, rec Record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bk, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return bk.Put([]byte(id), data)
+	})
+}
+
+func (b *boltBackend) Get(bucket, id string) (Record, bool, error) {
+	var (
+		rec   Record
+		found bool
+	)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bk := tx.Bucket([]byte(bucket))
+		if bk == nil {
+			return nil
+		}
+		data := bk.Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		found = true
+		// data is only valid inside the transaction; Unmarshal copies it out.
+		return json.Unmarshal(data, &rec)
+	})
+	if err != nil {
+		return Record{}, false, err
+	}
+	return rec, found, nil
+}
+
+func (b *boltBackend) Delete(bucket, id string) (bool, error) {
+	var found bool
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bk := tx.Bucket([]byte(bucket))
+		if bk == nil || bk.Get([]byte(id)) == nil {
+			return nil
+		}
+		found = true
+		return bk.Delete([]byte(id))
+	})
+	return found, err
+}
+
+func (b *boltBackend) Purge(now time.Time) (int, error) {
+	n := 0
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(_ []byte, bk *bolt.Bucket) error {
+			var expired [][]byte
+			err := bk.ForEach(func(k, v []byte) error {
+				// Only the expiry is needed here; skip decoding the payload.
+				var rec struct {
+					ExpiresAt time.Time `json:"expires_at"`
+				}
+				if json.Unmarshal(v, &rec) == nil && !rec.ExpiresAt.IsZero() && now.After(rec.ExpiresAt) {
+					expired = append(expired, append([]byte(nil), k...))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			// Deleting while iterating a bucket cursor skips keys; do it after.
+			for _, k := range expired {
+				if err := bk.Delete(k); err != nil {
+					return err
+				}
+			}
+			n += len(expired)
+			return nil
+		})
+	})
+	return n, err
+}
+
+func (b *boltBackend) Close() error { return b.db.Close() }
@@ -0,0 +1,201 @@
+package watchlist
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"earapi/api"
+	"earapi/imdb"
+	"earapi/tmdb"
+)
+
+// enrichWorkers bounds concurrent TMDB lookups; TMDB allows about 50
+// requests a second and each title costs up to two.
+const enrichWorkers = 4
+
+var regionPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// parseRegions reads a comma-separated region list, US when empty.
+func parseRegions(raw string) ([]string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return []string{"US"}, nil
+	}
+	var out []string
+	for r := range strings.SplitSeq(raw, ",") {
+		r = strings.ToUpper(strings.TrimSpace(r))
+		if r == "" {
+			continue
+		}
+		if !regionPattern.MatchString(r) {
+			return nil, errors.New("region " + r + " is not a two-letter country code")
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// enrichTitle looks t up on TMDB and fills in t.TMDB. A title TMDB doesn't
+// know is left alone and reported with matched false.
+func (s *Service) enrichTitle(ctx context.Context, t *imdb.Title, regions []string) (matched bool, err error) {
+	prefer := tmdb.TypeMovie
+	if t.Type == "tvSeries" || t.Type == "tvMiniSeries" {
+		prefer = tmdb.TypeTV
+	}
+	r, err := s.TMDB.FindIMDb(ctx, t.IMDbID, prefer)
+	var te *tmdb.Error
+	if errors.As(err, &te) && te.Kind == "not_found" {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	info := &imdb.TMDBInfo{
+		ID:               r.ID,
+		Type:             r.Type,
+		BackdropURL:      r.Backdrop,
+		OriginalLanguage: r.OriginalLanguage,
+		EnrichedAt:       time.Now().UTC(),
+	}
+	if len(regions) > 0 {
+		all, err := s.TMDB.WatchProviders(ctx, r.Type, r.ID, "")
+		if err != nil {
+			return false, err
+		}
+		for _, p := range all {
+			if !slices.Contains(regions, p.Region) {
+				continue
+			}
+			if info.Providers == nil {
+				info.Providers = map[string]imdb.StreamOptions{}
+			}
+			info.Providers[p.Region] = imdb.StreamOptions{
+				Link: p.Link, Stream: providerNames(p.Stream), Free: providerNames(p.Free), Ads: providerNames(p.Ads),
+			}
+		}
+	}
+	t.TMDB = info
+	return true, nil
+}
+
+func providerNames(in []tmdb.Provider) []string {
+	var out []string
+	for _, p := range in {
+		out = append(out, p.Name)
+	}
+	return out
+}
+
+// handleEnrich adds TMDB data to every title on a stored watchlist and saves
+// it back under the same id.
+func (s *Service) handleEnrich(c *gin.Context) {
+	if source := c.DefaultQuery("source", "tmdb"); source != "tmdb" {
+		writeErr(c, http.StatusBadRequest, "invalid_input", "unknown enrichment source "+source, "Only source=tmdb is supported.")
+		return
+	}
+	if s.TMDB == nil || !s.TMDB.Configured() {
+		writeErr(c, http.StatusServiceUnavailable, "unavailable", "TMDB is not configured",
+			"Set apikeys.tmdbapitoken (or EARAPI_APIKEYS_TMDBAPITOKEN).")
+		return
+	}
+	regions, err := parseRegions(c.Query("regions"))
+	if err != nil {
+		writeErr(c, http.StatusBadRequest, "invalid_input", err.Error(), "Use regions=US,GB,DE.")
+		return
+	}
+	wl, ok, err := s.Store.Watchlist(c.Param("id"))
+	if err != nil {
+		writeStoreErr(c, err)
+		return
+	}
+	if !ok {
+		writeErr(c, http.StatusNotFound, "not_found", "that watchlist is no longer stored",
+			"Fetch it again — stored lists expire after the retention window or when deleted.")
+		return
+	}
+
+	bg := context.WithoutCancel(c.Request.Context())
+	job := s.Jobs.Create(bg, "Matching on TMDB")
+
+	go func() {
+		ctx, cancel := context.WithTimeout(bg, 10*time.Minute)
+		defer cancel()
+
+		var (
+			next               = make(chan int)
+			done, found, fails atomic.Int64
+			unmatched          = []string{}
+			firstErr           error
+			mu                 sync.Mutex
+			wg                 sync.WaitGroup
+		)
+		total := len(wl.Titles)
+		for range enrichWorkers {
+			wg.Go(func() {
+				for i := range next {
+					t := &wl.Titles[i]
+					ok, err := s.enrichTitle(ctx, t, regions)
+					switch {
+					case err != nil:
+						fails.Add(1)
+						mu.Lock()
+						if firstErr == nil {
+							firstErr = err
+						}
+						mu.Unlock()
+					case ok:
+						found.Add(1)
+					default:
+						mu.Lock()
+						unmatched = append(unmatched, t.IMDbID)
+						mu.Unlock()
+					}
+					job.Progress("Matching on TMDB", int(done.Add(1)), total)
+				}
+			})
+		}
+		for i := range wl.Titles {
+			next <- i
+		}
+		close(next)
+		wg.Wait()
+
+		// Scattered failures are counted; a TMDB that answered nothing at all
+		// (bad token, outage) fails the job rather than saving a no-op.
+		if firstErr != nil && found.Load() == 0 && len(unmatched) == 0 {
+			job.Fail(jobErrOf(firstErr))
+			return
+		}
+		// Written back under its own retention window; a list deleted while
+		// the job ran stays deleted.
+		stored, err := s.Store.UpdateWatchlist(wl)
+		if err != nil {
+			job.Fail(storeErr(err))
+			return
+		}
+		if !stored {
+			job.Fail("not_found", "the watchlist was deleted or expired while it was being enriched", "")
+			return
+		}
+		job.Done(map[string]any{
+			"watchlist_id": wl.ID,
+			"source":       "tmdb",
+			"regions":      regions,
+			"matched":      found.Load(),
+			"unmatched":    unmatched,
+			"failed":       fails.Load(),
+			"attribution":  tmdb.ProviderAttribution,
+			"watchlist":    wl,
+		})
+	}()
+
+	api.Respond(c, http.StatusAccepted, gin.H{"job_id": job.ID, "watchlist_id": wl.ID})
+}
@@ -11,9 +11,11 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"earapi/api"
 	"earapi/compare"
 	"earapi/imdb"
 	"earapi/jellyfin"
+	"earapi/tmdb"
 )
 
 // RegisterRoutes mounts IMDb, watchlist, compare, Jellyfin, and jobs endpoints.
@@ -30,6 +32,8 @@ func RegisterRoutes(r *gin.Engine, svc *Service) {
 	{
 		wlG.GET("/:id", svc.handleGetWatchlist)
 		wlG.GET("/:id/export", svc.handleExportWatchlist)
+		wlG.DELETE("/:id", svc.handleDeleteWatchlist)
+		wlG.POST("/:id/enrich", svc.handleEnrich)
 	}
 
 	cmpG := r.Group("/compare/v1")
@@ -38,6 +42,7 @@ func RegisterRoutes(r *gin.Engine, svc *Service) {
 		cmpG.POST("/", svc.handleCompare)
 		cmpG.GET("/:id", svc.handleGetCompare)
 		cmpG.GET("/:id/export", svc.handleExportCompare)
+		cmpG.DELETE("/:id", svc.handleDeleteCompare)
 	}
 
 	jfG := r.Group("/jellyfin/v1")
@@ -62,7 +67,7 @@ func RegisterRoutes(r *gin.Engine, svc *Service) {
 }
 
 func (s *Service) handleCapabilities(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
+	api.OK(c, gin.H{
 		"supports_alias": s.BrowserName != "",
 		"browser":        s.BrowserName,
 		"groups": []string{
@@ -73,18 +78,9 @@ func (s *Service) handleCapabilities(c *gin.Context) {
 
 // --- error helpers -----------------------------------------------------------
 
-type apiError struct {
-	Error struct {
-		Kind    string `json:"kind"`
-		Message string `json:"message"`
-		Hint    string `json:"hint,omitempty"`
-	} `json:"error"`
-}
-
+// writeErr answers with a domain error whose kind has its own status.
 func writeErr(c *gin.Context, code int, kind, msg, hint string) {
-	var e apiError
-	e.Error.Kind, e.Error.Message, e.Error.Hint = kind, msg, hint
-	c.JSON(code, e)
+	api.Fail(c, api.New(kind, msg, hint).WithStatus(code))
 }
 
 func writeDomainErr(c *gin.Context, err error) {
@@ -114,6 +110,22 @@ func writeDomainErr(c *gin.Context, err error) {
 		writeErr(c, code, je.Kind, je.Message, je.Hint)
 		return
 	}
+	var te *tmdb.Error
+	if errors.As(err, &te) {
+		code := http.StatusBadGateway
+		switch te.Kind {
+		case "invalid_input":
+			code = http.StatusBadRequest
+		case "not_found":
+			code = http.StatusNotFound
+		case "auth":
+			code = http.StatusServiceUnavailable
+		case "rate_limited":
+			code = http.StatusTooManyRequests
+		}
+		writeErr(c, code, te.Kind, te.Message, te.Hint)
+		return
+	}
 	writeErr(c, http.StatusInternalServerError, "internal", err.Error(), "")
 }
 
@@ -126,9 +138,25 @@ func jobErrOf(err error) (kind, msg, hint string) {
 	if errors.As(err, &je) {
 		return je.Kind, je.Message, je.Hint
 	}
+	var te *tmdb.Error
+	if errors.As(err, &te) {
+		return te.Kind, te.Message, te.Hint
+	}
 	return "internal", err.Error(), ""
 }
 
+// storeErr classifies a persistence failure. These are server-side problems
+// (disk full, database locked), never something the caller can fix.
+func storeErr(err error) (kind, msg, hint string) {
+	return "storage", "could not access the watchlist store: " + err.Error(),
+		"Check that the API's data directory is writable and has free space."
+}
+
+func writeStoreErr(c *gin.Context, err error) {
+	kind, msg, hint := storeErr(err)
+	writeErr(c, http.StatusInternalServerError, kind, msg, hint)
+}
+
 // --- imdb --------------------------------------------------------------------
 
 func (s *Service) handleResolve(c *gin.Context) {
@@ -144,7 +172,7 @@ func (s *Service) handleResolve(c *gin.Context) {
 		writeDomainErr(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, ref)
+	api.OK(c, ref)
 }
 
 func (s *Service) handleFetch(c *gin.Context) {
@@ -167,17 +195,24 @@ func (s *Service) handleFetch(c *gin.Context) {
 	if ref.Kind == imdb.KindAlias {
 		phase = "Opening IMDb in " + firstNonEmpty(s.BrowserName, "a browser")
 	}
-	job := s.Jobs.Create(phase)
+	// The job outlives this request; keep its values (request id) but not its
+	// cancellation. gin recycles c, so take what the goroutine needs now.
+	bg := context.WithoutCancel(c.Request.Context())
+	job := s.Jobs.Create(bg, phase)
 	owner := strings.TrimSpace(req.Owner)
 
 	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		ctx, cancel := context.WithTimeout(bg, 10*time.Minute)
 		defer cancel()
 
 		if !req.Refresh {
 			if wl, ok := s.Store.CachedList(ref); ok {
 				wl.Owner = owner
-				id := s.Store.PutWatchlist(wl)
+				id, err := s.Store.PutWatchlist(wl)
+				if err != nil {
+					job.Fail(storeErr(err))
+					return
+				}
 				job.Done(map[string]any{"watchlist_id": id, "cached": true, "watchlist": wl})
 				return
 			}
@@ -192,11 +227,15 @@ func (s *Service) handleFetch(c *gin.Context) {
 		}
 		wl.Owner = owner
 		s.Store.CacheList(ref, wl)
-		id := s.Store.PutWatchlist(wl)
+		id, err := s.Store.PutWatchlist(wl)
+		if err != nil {
+			job.Fail(storeErr(err))
+			return
+		}
 		job.Done(map[string]any{"watchlist_id": id, "cached": false, "watchlist": wl})
 	}()
 
-	c.JSON(http.StatusAccepted, gin.H{"job_id": job.ID, "source": ref})
+	api.Respond(c, http.StatusAccepted, gin.H{"job_id": job.ID, "source": ref})
 }
 
 func (s *Service) handleImportCSV(c *gin.Context) {
@@ -222,8 +261,12 @@ func (s *Service) handleImportCSV(c *gin.Context) {
 		wl.Name = header.Filename
 		wl.Source.Label = header.Filename
 	}
-	id := s.Store.PutWatchlist(wl)
-	c.JSON(http.StatusOK, gin.H{"watchlist_id": id, "watchlist": wl})
+	id, err := s.Store.PutWatchlist(wl)
+	if err != nil {
+		writeStoreErr(c, err)
+		return
+	}
+	api.OK(c, gin.H{"watchlist_id": id, "watchlist": wl})
 }
 
 func (s *Service) handleHydrate(c *gin.Context) {
@@ -246,23 +289,31 @@ func (s *Service) handleHydrate(c *gin.Context) {
 		writeDomainErr(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"count": len(titles), "titles": titles})
+	api.OK(c, gin.H{"count": len(titles), "titles": titles})
 }
 
 func (s *Service) handleGetWatchlist(c *gin.Context) {
-	wl, ok := s.Store.Watchlist(c.Param("id"))
+	wl, ok, err := s.Store.Watchlist(c.Param("id"))
+	if err != nil {
+		writeStoreErr(c, err)
+		return
+	}
 	if !ok {
-		writeErr(c, http.StatusNotFound, "not_found", "that watchlist is no longer loaded",
-			"Fetch it again — results are kept only while the API process is running.")
+		writeErr(c, http.StatusNotFound, "not_found", "that watchlist is no longer stored",
+			"Fetch it again — stored lists expire after the retention window or when deleted.")
 		return
 	}
-	c.JSON(http.StatusOK, wl)
+	api.OK(c, wl)
 }
 
 func (s *Service) handleExportWatchlist(c *gin.Context) {
-	wl, ok := s.Store.Watchlist(c.Param("id"))
+	wl, ok, err := s.Store.Watchlist(c.Param("id"))
+	if err != nil {
+		writeStoreErr(c, err)
+		return
+	}
 	if !ok {
-		writeErr(c, http.StatusNotFound, "not_found", "that watchlist is no longer loaded", "")
+		writeErr(c, http.StatusNotFound, "not_found", "that watchlist is no longer stored", "")
 		return
 	}
 	base := safeFilename(firstNonEmpty(wl.Owner, wl.Name, "watchlist"))
@@ -274,6 +325,7 @@ func (s *Service) handleExportWatchlist(c *gin.Context) {
 		return
 	}
 	c.Header("Content-Disposition", `attachment; filename="`+base+`.json"`)
+	// An export is a file, not an API response: no v2 envelope.
 	c.JSON(http.StatusOK, gin.H{
 		"schema_version": 1,
 		"exported_at":    time.Now().UTC(),
@@ -286,6 +338,20 @@ func (s *Service) handleExportWatchlist(c *gin.Context) {
 	})
 }
 
+func (s *Service) handleDeleteWatchlist(c *gin.Context) {
+	id := c.Param("id")
+	found, err := s.Store.DeleteWatchlist(id)
+	if err != nil {
+		writeStoreErr(c, err)
+		return
+	}
+	if !found {
+		writeErr(c, http.StatusNotFound, "not_found", "no stored watchlist with that id", "")
+		return
+	}
+	api.OK(c, gin.H{"watchlist_id": id, "deleted": true})
+}
+
 // --- compare -----------------------------------------------------------------
 
 func (s *Service) handleCompare(c *gin.Context) {
@@ -301,7 +367,10 @@ func (s *Service) handleCompare(c *gin.Context) {
 		return
 	}
 
-	var inputs []compare.Input
+	var (
+		inputs  []compare.Input
+		sources []string
+	)
 	switch {
 	case len(req.Lists) >= 2:
 		inputs = make([]compare.Input, 0, len(req.Lists))
@@ -314,11 +383,16 @@ func (s *Service) handleCompare(c *gin.Context) {
 		}
 	case len(req.WatchlistIDs) >= 2:
 		inputs = make([]compare.Input, 0, len(req.WatchlistIDs))
+		sources = req.WatchlistIDs
 		for i, id := range req.WatchlistIDs {
-			wl, ok := s.Store.Watchlist(id)
+			wl, ok, err := s.Store.Watchlist(id)
+			if err != nil {
+				writeStoreErr(c, err)
+				return
+			}
 			if !ok {
 				writeErr(c, http.StatusNotFound, "not_found",
-					"one of those lists is no longer loaded", "Fetch it again, then compare.")
+					"one of those lists is no longer stored", "Fetch it again, then compare.")
 				return
 			}
 			owner := wl.Owner
@@ -335,23 +409,36 @@ func (s *Service) handleCompare(c *gin.Context) {
 	}
 
 	res := compare.Compare(inputs)
-	id := s.Store.PutCompare(res)
-	c.JSON(http.StatusOK, gin.H{"compare_id": id, "result": res})
+	res.Sources = sources
+	id, err := s.Store.PutCompare(res)
+	if err != nil {
+		writeStoreErr(c, err)
+		return
+	}
+	api.OK(c, gin.H{"compare_id": id, "result": res})
 }
 
 func (s *Service) handleGetCompare(c *gin.Context) {
-	res, ok := s.Store.Compare(c.Param("id"))
+	res, ok, err := s.Store.Compare(c.Param("id"))
+	if err != nil {
+		writeStoreErr(c, err)
+		return
+	}
 	if !ok {
-		writeErr(c, http.StatusNotFound, "not_found", "that comparison is no longer loaded", "")
+		writeErr(c, http.StatusNotFound, "not_found", "that comparison is no longer stored", "")
 		return
 	}
-	c.JSON(http.StatusOK, res)
+	api.OK(c, res)
 }
 
 func (s *Service) handleExportCompare(c *gin.Context) {
-	res, ok := s.Store.Compare(c.Param("id"))
+	res, ok, err := s.Store.Compare(c.Param("id"))
+	if err != nil {
+		writeStoreErr(c, err)
+		return
+	}
 	if !ok {
-		writeErr(c, http.StatusNotFound, "not_found", "that comparison is no longer loaded", "")
+		writeErr(c, http.StatusNotFound, "not_found", "that comparison is no longer stored", "")
 		return
 	}
 	view := c.Query("view")
@@ -369,6 +456,7 @@ func (s *Service) handleExportCompare(c *gin.Context) {
 		return
 	}
 	c.Header("Content-Disposition", `attachment; filename="`+base+`.json"`)
+	// An export is a file, not an API response: no v2 envelope.
 	c.JSON(http.StatusOK, gin.H{
 		"schema_version": 1,
 		"exported_at":    time.Now().UTC(),
@@ -380,6 +468,20 @@ func (s *Service) handleExportCompare(c *gin.Context) {
 	})
 }
 
+func (s *Service) handleDeleteCompare(c *gin.Context) {
+	id := c.Param("id")
+	found, err := s.Store.DeleteCompare(id)
+	if err != nil {
+		writeStoreErr(c, err)
+		return
+	}
+	if !found {
+		writeErr(c, http.StatusNotFound, "not_found", "no stored comparison with that id", "")
+		return
+	}
+	api.OK(c, gin.H{"compare_id": id, "deleted": true})
+}
+
 // --- jellyfin ----------------------------------------------------------------
 
 func (s *Service) handleJFConnect(c *gin.Context) {
@@ -401,12 +503,12 @@ func (s *Service) handleJFConnect(c *gin.Context) {
 		return
 	}
 	s.setConnection(conn)
-	c.JSON(http.StatusOK, gin.H{"server": conn.Server, "user": conn.User, "url": conn.Client.BaseURL})
+	api.OK(c, gin.H{"server": conn.Server, "user": conn.User, "url": conn.Client.BaseURL})
 }
 
 func (s *Service) handleJFDisconnect(c *gin.Context) {
 	s.clearConnection()
-	c.JSON(http.StatusOK, gin.H{"connected": false})
+	api.OK(c, gin.H{"connected": false})
 }
 
 func (s *Service) handleJFStatus(c *gin.Context) {
@@ -423,7 +525,7 @@ func (s *Service) handleJFStatus(c *gin.Context) {
 			"scanned_at": idx.ScannedAt, "total": idx.Total, "with_imdb": idx.WithIMDb,
 		}
 	}
-	c.JSON(http.StatusOK, out)
+	api.OK(c, out)
 }
 
 func (s *Service) requireConn(c *gin.Context) (*jellyfin.Connection, bool) {
@@ -468,9 +570,10 @@ func (s *Service) handleJFScan(c *gin.Context) {
 		return
 	}
 
-	job := s.Jobs.Create("Scanning Jellyfin library")
+	bg := context.WithoutCancel(c.Request.Context())
+	job := s.Jobs.Create(bg, "Scanning Jellyfin library")
 	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+		ctx, cancel := context.WithTimeout(bg, 15*time.Minute)
 		defer cancel()
 
 		idx, err := conn.Client.ScanLibrary(ctx, conn.User.ID, func(fetched, total int) {
@@ -488,7 +591,21 @@ func (s *Service) handleJFScan(c *gin.Context) {
 		})
 	}()
 
-	c.JSON(http.StatusAccepted, gin.H{"job_id": job.ID})
+	api.Respond(c, http.StatusAccepted, gin.H{"job_id": job.ID})
+}
+
+// storeFault is a resolveTitles failure that is the store's, not the
+// request's.
+type storeFault struct{ error }
+
+// writeTitlesErr answers a resolveTitles failure.
+func writeTitlesErr(c *gin.Context, err error) {
+	var sf storeFault
+	if errors.As(err, &sf) {
+		writeStoreErr(c, sf.error)
+		return
+	}
+	writeErr(c, http.StatusBadRequest, "invalid_input", err.Error(), "")
 }
 
 func (s *Service) resolveTitles(watchlistID, compareID, view string, inline []imdb.Title) ([]imdb.Title, error) {
@@ -496,15 +613,21 @@ func (s *Service) resolveTitles(watchlistID, compareID, view string, inline []im
 	case len(inline) > 0:
 		return inline, nil
 	case watchlistID != "":
-		wl, ok := s.Store.Watchlist(watchlistID)
+		wl, ok, err := s.Store.Watchlist(watchlistID)
+		if err != nil {
+			return nil, storeFault{err}
+		}
 		if !ok {
-			return nil, errors.New("that watchlist is no longer loaded")
+			return nil, errors.New("that watchlist is no longer stored")
 		}
 		return wl.Titles, nil
 	case compareID != "":
-		res, ok := s.Store.Compare(compareID)
+		res, ok, err := s.Store.Compare(compareID)
+		if err != nil {
+			return nil, storeFault{err}
+		}
 		if !ok {
-			return nil, errors.New("that comparison is no longer loaded")
+			return nil, errors.New("that comparison is no longer stored")
 		}
 		titles, ok := res.View(view)
 		if !ok {
@@ -557,7 +680,7 @@ func (s *Service) handleJFMatch(c *gin.Context) {
 
 	titles, err := s.resolveTitles(req.WatchlistID, req.CompareID, req.View, req.Titles)
 	if err != nil {
-		writeErr(c, http.StatusBadRequest, "invalid_input", err.Error(), "")
+		writeTitlesErr(c, err)
 		return
 	}
 	if req.MoviesOnly {
@@ -572,7 +695,7 @@ func (s *Service) handleJFMatch(c *gin.Context) {
 		writeDomainErr(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, idx.Match(titles))
+	api.OK(c, idx.Match(titles))
 }
 
 func (s *Service) handleJFSync(c *gin.Context) {
@@ -626,7 +749,7 @@ func (s *Service) handleJFSync(c *gin.Context) {
 	if len(itemIDs) == 0 {
 		titles, err := s.resolveTitles(req.WatchlistID, req.CompareID, req.View, req.Titles)
 		if err != nil {
-			writeErr(c, http.StatusBadRequest, "invalid_input", err.Error(), "")
+			writeTitlesErr(c, err)
 			return
 		}
 		if req.MoviesOnly {
@@ -653,7 +776,7 @@ func (s *Service) handleJFSync(c *gin.Context) {
 			writeDomainErr(c, err)
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"dry_run": true, "plan": plan, "match": match})
+		api.OK(c, gin.H{"dry_run": true, "plan": plan, "match": match})
 		return
 	}
 
@@ -662,7 +785,7 @@ func (s *Service) handleJFSync(c *gin.Context) {
 		writeDomainErr(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"dry_run": false, "result": res, "match": match})
+	api.OK(c, gin.H{"dry_run": false, "result": res, "match": match})
 }
 
 func (s *Service) handleJFPlaylists(c *gin.Context) {
@@ -692,7 +815,7 @@ func (s *Service) handleJFPlaylists(c *gin.Context) {
 			writeErr(c, http.StatusNotFound, "not_found", "no playlist named "+name, "")
 			return
 		}
-		c.JSON(http.StatusOK, pl)
+		api.OK(c, pl)
 		return
 	}
 
@@ -701,7 +824,7 @@ func (s *Service) handleJFPlaylists(c *gin.Context) {
 		writeDomainErr(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"count": len(items), "playlists": items})
+	api.OK(c, gin.H{"count": len(items), "playlists": items})
 }
 
 func (s *Service) handleJFPlaylistItems(c *gin.Context) {
@@ -730,7 +853,7 @@ func (s *Service) handleJFPlaylistItems(c *gin.Context) {
 		writeDomainErr(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"playlist_id": playlistID, "count": len(ids), "item_ids": ids})
+	api.OK(c, gin.H{"playlist_id": playlistID, "count": len(ids), "item_ids": ids})
 }
 
 // --- jobs --------------------------------------------------------------------
@@ -741,7 +864,7 @@ func (s *Service) handleJobStatus(c *gin.Context) {
 		writeErr(c, http.StatusNotFound, "not_found", "unknown job", "")
 		return
 	}
-	c.JSON(http.StatusOK, job.Snapshot())
+	api.OK(c, job.Snapshot())
 }
 
 func (s *Service) handleJobEvents(c *gin.Context) {
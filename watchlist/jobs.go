@@ -1,6 +1,8 @@
 package watchlist
 
 import (
+	"context"
+	"log/slog"
 	"sync"
 	"time"
 )
@@ -39,6 +41,10 @@ type Job struct {
 	ID        string
 	CreatedAt time.Time
 
+	// logCtx carries the starting request's values (its request id) but not
+	// its cancellation, so the job's log lines can be traced back to it.
+	logCtx context.Context
+
 	mu      sync.Mutex
 	last    JobUpdate
 	subs    map[chan JobUpdate]struct{}
@@ -60,15 +66,17 @@ func NewJobs() *Jobs {
 	return j
 }
 
-// Create registers a new running job.
-func (js *Jobs) Create(phase string) *Job {
+// Create registers a new running job on behalf of the request behind ctx.
+func (js *Jobs) Create(ctx context.Context, phase string) *Job {
 	job := &Job{
 		ID:        NewID(),
 		CreatedAt: time.Now(),
+		logCtx:    context.WithoutCancel(ctx),
 		subs:      map[chan JobUpdate]struct{}{},
 		retain:    10 * time.Minute,
 	}
 	job.last = JobUpdate{ID: job.ID, State: JobRunning, Phase: phase}
+	slog.InfoContext(job.logCtx, "job started", "job_id", job.ID, "phase", phase)
 
 	js.mu.Lock()
 	js.jobs[job.ID] = job
@@ -84,6 +92,20 @@ func (js *Jobs) Get(id string) (*Job, bool) {
 	return j, ok
 }
 
+// Counts returns how many jobs the registry holds in each state. Finished
+// jobs count until the reaper drops them.
+func (js *Jobs) Counts() map[JobState]int {
+	out := map[JobState]int{JobRunning: 0, JobDone: 0, JobFailed: 0}
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	for _, j := range js.jobs {
+		j.mu.Lock()
+		out[j.last.State]++
+		j.mu.Unlock()
+	}
+	return out
+}
+
 func (js *Jobs) reap() {
 	for range time.Tick(time.Minute) {
 		now := time.Now()
@@ -176,6 +198,7 @@ func (j *Job) Done(result any) {
 	j.mu.Unlock()
 	u.State, u.Result, u.Finished, u.Error = JobDone, result, true, nil
 	j.publish(u)
+	slog.InfoContext(j.logCtx, "job done", "job_id", j.ID, "took", time.Since(j.CreatedAt))
 }
 
 // Fail finishes the job with a classified error.
@@ -186,6 +209,8 @@ func (j *Job) Fail(kind, msg, hint string) {
 	u.State, u.Finished = JobFailed, true
 	u.Error = &JobErr{Kind: kind, Message: msg, Hint: hint}
 	j.publish(u)
+	slog.WarnContext(j.logCtx, "job failed", "job_id", j.ID, "phase", u.Phase,
+		"kind", kind, "message", msg, "took", time.Since(j.CreatedAt))
 }
 
 // Snapshot returns the latest update.
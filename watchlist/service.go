@@ -3,27 +3,36 @@
 package watchlist
 
 import (
+	"context"
 	"sync"
 	"time"
 
 	"earapi/browser"
 	"earapi/imdb"
 	"earapi/jellyfin"
+	"earapi/tmdb"
 )
 
-// Config controls cache and optional browser-backed alias resolution.
+// Config controls storage, cache and optional browser-backed alias resolution.
 type Config struct {
-	CacheDir     string
-	CacheTTL     time.Duration // 0 disables disk cache
-	BrowserPath  string        // optional override; empty = auto-discover
+	CacheDir       string
+	CacheTTL       time.Duration // 0 disables disk cache
+	BrowserPath    string        // optional override; empty = auto-discover
 	BrowserHeadful bool
+
+	StoreBackend string        // "bolt" (default) or "memory"
+	StorePath    string        // database file for the bolt backend
+	Retention    time.Duration // how long stored handles live; 0 keeps them forever
+
+	TMDB *tmdb.Client // for enrichment; nil disables it
 }
 
-// Service wires IMDb client, in-memory store, jobs, and Jellyfin session state.
+// Service wires IMDb client, persistent store, jobs, and Jellyfin session state.
 type Service struct {
 	Store *Store
 	Jobs  *Jobs
 	IMDb  *imdb.Client
+	TMDB  *tmdb.Client
 
 	BrowserName string // empty when p.* alias resolution is unavailable
 
@@ -32,13 +41,19 @@ type Service struct {
 	jfIdx  *jellyfin.LibraryIndex
 }
 
-// New builds a Service. A missing browser is non-fatal (alias links need CSV).
+// New builds a Service. A missing browser is non-fatal (alias links need CSV),
+// but a store that cannot be opened is: handles would silently stop persisting.
 // CacheTTL of 0 disables the on-disk IMDb list cache.
-func New(cfg Config) *Service {
+func New(cfg Config) (*Service, error) {
+	backend, err := OpenBackend(cfg.StoreBackend, cfg.StorePath)
+	if err != nil {
+		return nil, err
+	}
 	svc := &Service{
-		Store: NewStore(cfg.CacheDir, cfg.CacheTTL),
+		Store: NewStore(backend, cfg.CacheDir, cfg.CacheTTL, cfg.Retention),
 		Jobs:  NewJobs(),
 		IMDb:  imdb.NewClient(),
+		TMDB:  cfg.TMDB,
 	}
 
 	r, err := browser.New("")
@@ -50,7 +65,21 @@ func New(cfg Config) *Service {
 		svc.IMDb.Pages = r
 		svc.BrowserName = r.Name()
 	}
-	return svc
+	return svc, nil
+}
+
+// Close releases the persistent store.
+func (s *Service) Close() error { return s.Store.Close() }
+
+// PingJellyfin checks that the session's Jellyfin server still answers.
+// connected is false, and nothing is sent, when there is no session.
+func (s *Service) PingJellyfin(ctx context.Context) (connected bool, err error) {
+	conn := s.connection()
+	if conn == nil {
+		return false, nil
+	}
+	_, err = conn.Client.SystemInfo(ctx)
+	return true, err
 }
 
 func (s *Service) setConnection(conn *jellyfin.Connection) {
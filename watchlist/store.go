@@ -5,37 +5,55 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"earapi/atomicfile"
 	"earapi/compare"
 	"earapi/imdb"
+	"earapi/metrics"
 )
 
-// Store holds fetched watchlists and comparisons for the life of the process,
-// plus a disk cache so repeated fetches of the same list don't hammer IMDb.
+// Store holds fetched watchlists and comparisons in a pluggable Backend, plus a
+// disk cache so repeated fetches of the same list don't hammer IMDb.
+//
+// Stored handles expire after the retention window; the list cache is separate
+// and only decides whether a fetch needs to go back to IMDb.
 type Store struct {
-	mu         sync.RWMutex
-	watchlists map[string]*imdb.Watchlist
-	compares   map[string]*compare.Result
+	backend   Backend
+	retention time.Duration // 0 keeps stored handles forever
 
 	cacheDir string
-	cacheTTL time.Duration
+	cacheTTL atomic.Int64 // time.Duration; swapped on config reload
+
+	rewrite sync.Mutex // orders UpdateWatchlist against deletes
+
+	stop      chan struct{} // closed by Close to end the purge
+	reaper    sync.WaitGroup
+	closeOnce sync.Once
 }
 
-// NewStore creates a store backed by cacheDir.
-func NewStore(cacheDir string, ttl time.Duration) *Store {
+// NewStore creates a store that persists handles in backend and caches list
+// fetches in cacheDir. A positive retention starts an hourly purge.
+func NewStore(backend Backend, cacheDir string, ttl, retention time.Duration) *Store {
 	if cacheDir != "" {
 		_ = os.MkdirAll(cacheDir, 0o700)
 	}
-	return &Store{
-		watchlists: map[string]*imdb.Watchlist{},
-		compares:   map[string]*compare.Result{},
-		cacheDir:   cacheDir,
-		cacheTTL:   ttl,
+	s := &Store{
+		backend:   backend,
+		retention: retention,
+		cacheDir:  cacheDir,
+		stop:      make(chan struct{}),
+	}
+	s.SetCacheTTL(ttl)
+	if retention > 0 {
+		s.reaper.Go(s.reap)
 	}
+	return s
 }
 
 // NewID returns a random hex identifier.
@@ -47,40 +65,129 @@ func NewID() string {
 	return hex.EncodeToString(b[:])
 }
 
+// SetCacheTTL changes how long cached list fetches stay fresh; 0 disables the
+// cache. Entries already on disk are judged by the new TTL.
+func (s *Store) SetCacheTTL(ttl time.Duration) { s.cacheTTL.Store(int64(ttl)) }
+
+// Close stops the purge, waiting out one under way, and releases the
+// backend.
+func (s *Store) Close() error {
+	s.closeOnce.Do(func() { close(s.stop) })
+	s.reaper.Wait()
+	return s.backend.Close()
+}
+
+func (s *Store) reap() {
+	t := time.NewTicker(time.Hour)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-t.C:
+			_, _ = s.backend.Purge(time.Now())
+		}
+	}
+}
+
+func (s *Store) put(bucket, id string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	rec := Record{CreatedAt: time.Now().UTC(), Data: data}
+	if s.retention > 0 {
+		rec.ExpiresAt = rec.CreatedAt.Add(s.retention)
+	}
+	return s.backend.Put(bucket, id, rec)
+}
+
+// get decodes a live record into out. Expired records read as missing even
+// before the purge gets to them; a backend that can't be read, or a record
+// that doesn't decode, is an error rather than missing.
+func (s *Store) get(bucket, id string, out any) (bool, error) {
+	if id == "" {
+		return false, nil
+	}
+	rec, ok, err := s.backend.Get(bucket, id)
+	if err != nil {
+		return false, err
+	}
+	if !ok || rec.Expired(time.Now()) {
+		return false, nil
+	}
+	if err := json.Unmarshal(rec.Data, out); err != nil {
+		return false, fmt.Errorf("%s/%s is corrupt: %w", bucket, id, err)
+	}
+	return true, nil
+}
+
 // PutWatchlist stores a watchlist and returns its handle.
-func (s *Store) PutWatchlist(wl *imdb.Watchlist) string {
+func (s *Store) PutWatchlist(wl *imdb.Watchlist) (string, error) {
 	if wl.ID == "" {
 		wl.ID = NewID()
 	}
-	s.mu.Lock()
-	s.watchlists[wl.ID] = wl
-	s.mu.Unlock()
-	return wl.ID
+	if err := s.put(bucketWatchlists, wl.ID, wl); err != nil {
+		return "", err
+	}
+	return wl.ID, nil
 }
 
-// Watchlist returns a stored watchlist.
-func (s *Store) Watchlist(id string) (*imdb.Watchlist, bool) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	wl, ok := s.watchlists[id]
-	return wl, ok
+// Watchlist returns a stored watchlist; ok is false when there is none.
+func (s *Store) Watchlist(id string) (wl *imdb.Watchlist, ok bool, err error) {
+	wl = &imdb.Watchlist{}
+	if ok, err = s.get(bucketWatchlists, id, wl); !ok {
+		return nil, false, err
+	}
+	return wl, true, nil
+}
+
+// UpdateWatchlist rewrites a stored watchlist in place, keeping its
+// retention window. ok is false, and nothing is written, when it has been
+// deleted or has expired since it was read.
+func (s *Store) UpdateWatchlist(wl *imdb.Watchlist) (ok bool, err error) {
+	data, err := json.Marshal(wl)
+	if err != nil {
+		return false, err
+	}
+	s.rewrite.Lock()
+	defer s.rewrite.Unlock()
+	rec, ok, err := s.backend.Get(bucketWatchlists, wl.ID)
+	if err != nil || !ok || rec.Expired(time.Now()) {
+		return false, err
+	}
+	rec.Data = data
+	return true, s.backend.Put(bucketWatchlists, wl.ID, rec)
+}
+
+// DeleteWatchlist removes a stored watchlist, reporting whether it existed.
+func (s *Store) DeleteWatchlist(id string) (bool, error) {
+	s.rewrite.Lock()
+	defer s.rewrite.Unlock()
+	return s.backend.Delete(bucketWatchlists, id)
 }
 
 // PutCompare stores a comparison and returns its handle.
-func (s *Store) PutCompare(r *compare.Result) string {
+func (s *Store) PutCompare(r *compare.Result) (string, error) {
 	id := NewID()
-	s.mu.Lock()
-	s.compares[id] = r
-	s.mu.Unlock()
-	return id
+	if err := s.put(bucketCompares, id, r); err != nil {
+		return "", err
+	}
+	return id, nil
 }
 
-// Compare returns a stored comparison.
-func (s *Store) Compare(id string) (*compare.Result, bool) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	r, ok := s.compares[id]
-	return r, ok
+// Compare returns a stored comparison; ok is false when there is none.
+func (s *Store) Compare(id string) (r *compare.Result, ok bool, err error) {
+	r = &compare.Result{}
+	if ok, err = s.get(bucketCompares, id, r); !ok {
+		return nil, false, err
+	}
+	return r, true, nil
+}
+
+// DeleteCompare removes a stored comparison, reporting whether it existed.
+func (s *Store) DeleteCompare(id string) (bool, error) {
+	return s.backend.Delete(bucketCompares, id)
 }
 
 func (s *Store) cachePath(ref imdb.ListRef) string {
@@ -94,9 +201,16 @@ func (s *Store) cachePath(ref imdb.ListRef) string {
 // CachedList returns a cached fetch if it is still within the TTL.
 func (s *Store) CachedList(ref imdb.ListRef) (*imdb.Watchlist, bool) {
 	path := s.cachePath(ref)
-	if path == "" || s.cacheTTL <= 0 {
+	ttl := time.Duration(s.cacheTTL.Load())
+	if path == "" || ttl <= 0 {
 		return nil, false
 	}
+	wl, ok := s.readCachedList(path, ttl)
+	metrics.CacheLookup("imdb_list", ok)
+	return wl, ok
+}
+
+func (s *Store) readCachedList(path string, ttl time.Duration) (*imdb.Watchlist, bool) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, false
@@ -105,7 +219,7 @@ func (s *Store) CachedList(ref imdb.ListRef) (*imdb.Watchlist, bool) {
 	if err := json.Unmarshal(data, &wl); err != nil {
 		return nil, false
 	}
-	if time.Since(wl.FetchedAt) > s.cacheTTL {
+	if time.Since(wl.FetchedAt) > ttl {
 		return nil, false
 	}
 	wl.ID = ""
@@ -122,11 +236,5 @@ func (s *Store) CacheList(ref imdb.ListRef, wl *imdb.Watchlist) {
 	if err != nil {
 		return
 	}
-	tmp := path + ".tmp"
-	if err := os.WriteFile(tmp, data, 0o600); err != nil {
-		return
-	}
-	if err := os.Rename(tmp, path); err != nil {
-		_ = os.Remove(tmp)
-	}
+	_ = atomicfile.Write(path, data, 0o600)
 }
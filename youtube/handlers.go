@@ -2,12 +2,15 @@ package youtube
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"earapi/api"
 )
 
 type AddRequest struct {
@@ -18,23 +21,23 @@ type AddRequest struct {
 }
 
 func RegisterRoutes(r *gin.Engine, svc *Service) {
-    g := r.Group("/youtube/v1")
+    g := r.Group("/youtube/v1", api.Use(api.Plain))
     g.POST("/playlist/add", func(c *gin.Context) {
         var req AddRequest
-        if err := c.BindJSON(&req); err != nil {
-            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        if err := c.ShouldBindJSON(&req); err != nil {
+            api.Fail(c, api.New(api.KindInvalidInput, err.Error(), ""))
             return
         }
         ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
         defer cancel()
         res, err := svc.AddVideoToPlaylist(ctx, req.PlaylistName, req.Video, req.Force)
         if err != nil {
-            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            fail(c, err)
             return
         }
         // append log line on success
         go appendAddLog(req.PlaylistName, res.VideoID, req.User, req.Force)
-        c.JSON(http.StatusOK, res)
+        api.OK(c, res)
     })
 
     // Create playlist (separate from add)
@@ -43,18 +46,18 @@ func RegisterRoutes(r *gin.Engine, svc *Service) {
             Name    string `json:"name"`
             Privacy string `json:"privacy"`
         }
-        if err := c.BindJSON(&req); err != nil {
-            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        if err := c.ShouldBindJSON(&req); err != nil {
+            api.Fail(c, api.New(api.KindInvalidInput, err.Error(), ""))
             return
         }
         ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
         defer cancel()
         pl, err := svc.CreatePlaylist(ctx, req.Name, req.Privacy)
         if err != nil {
-            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            fail(c, err)
             return
         }
-        c.JSON(http.StatusOK, gin.H{"playlistId": pl.ID, "title": pl.Title})
+        api.OK(c, gin.H{"playlistId": pl.ID, "title": pl.Title})
     })
 
     // List items with optional fuzzy and metadata
@@ -66,7 +69,7 @@ func RegisterRoutes(r *gin.Engine, svc *Service) {
         defer cancel()
         items, pl, err := svc.ListItems(ctx, name, fuzzy)
         if err != nil {
-            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            fail(c, err)
             return
         }
         if withMeta {
@@ -84,10 +87,10 @@ func RegisterRoutes(r *gin.Engine, svc *Service) {
                 m := metaIdx[it.VideoID]
                 out = append(out, itemWithMeta{VideoID: it.VideoID, Title: it.Title, Date: m.Date, User: m.User, Playlist: m.Playlist, Force: m.Force})
             }
-            c.JSON(http.StatusOK, gin.H{"playlistId": pl.ID, "title": pl.Title, "items": out})
+            api.OK(c, gin.H{"playlistId": pl.ID, "title": pl.Title, "items": out})
             return
         }
-        c.JSON(http.StatusOK, gin.H{"playlistId": pl.ID, "title": pl.Title, "items": items})
+        api.OK(c, gin.H{"playlistId": pl.ID, "title": pl.Title, "items": items})
     })
 
     // Metadata for specific video+playlist
@@ -96,22 +99,36 @@ func RegisterRoutes(r *gin.Engine, svc *Service) {
         fuzzy := c.DefaultQuery("fuzzy", "true") == "true"
         videoID := c.Query("videoId")
         if name == "" || videoID == "" {
-            c.JSON(http.StatusBadRequest, gin.H{"error": "name and videoId are required"})
+            api.Fail(c, api.New(api.KindInvalidInput, "name and videoId are required", ""))
             return
         }
         ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
         defer cancel()
         _, pl, err := svc.ListItems(ctx, name, fuzzy)
         if err != nil {
-            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            fail(c, err)
             return
         }
         metaIdx := loadAdditionsIndex()
         m := metaIdx[videoID]
-        c.JSON(http.StatusOK, gin.H{"playlistId": pl.ID, "title": pl.Title, "videoId": videoID, "date": m.Date, "user": m.User, "playlist": m.Playlist, "force": m.Force})
+        api.OK(c, gin.H{"playlistId": pl.ID, "title": pl.Title, "videoId": videoID, "date": m.Date, "user": m.User, "playlist": m.Playlist, "force": m.Force})
     })
 }
 
+// fail answers err in the group's dialect. YouTube API failures carry no
+// kind of their own, so they are reported as upstream errors. v1 has always
+// answered every failure here with 400.
+func fail(c *gin.Context, err error) {
+    var e *api.Error
+    if errors.As(err, &e) {
+        cp := *e
+        e = &cp
+    } else {
+        e = api.New(api.KindUpstream, err.Error(), "")
+    }
+    api.Fail(c, e.WithV1Status(http.StatusBadRequest))
+}
+
 func appendAddLog(playlistProvided string, videoID string, user string, force bool) {
     if videoID == "" {
         return
@@ -3,12 +3,16 @@ package youtube
 import (
 	"context"
 	"errors"
+	"log/slog"
 	"net/http"
 	"regexp"
 	"strings"
 	"sync"
 	"time"
 
+	"earapi/api"
+	"earapi/metrics"
+
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/option"
@@ -64,18 +68,13 @@ func New(ctx context.Context, cfg Config) (*Service, error) {
     }
     token := &oauth2.Token{RefreshToken: cfg.RefreshToken}
     httpClient := oauthCfg.Client(ctx, token)
+    httpClient.Transport = metrics.Transport("youtube", httpClient.Transport)
 
     svc, err := yt.NewService(ctx, option.WithHTTPClient(httpClient), option.WithUserAgent("earapi-youtube/1.0"))
     if err != nil {
         return nil, err
     }
 
-    ttl := time.Duration(cfg.CacheMinutes)
-    if ttl <= 0 {
-        ttl = 10
-    }
-    ttl = ttl * time.Minute
-
     s := &Service{
         yt:        svc,
         cfg:       cfg,
@@ -84,7 +83,7 @@ func New(ctx context.Context, cfg Config) (*Service, error) {
         cache: &cacheStore{
             playlists:      nil,
             playlistVideos: make(map[string]map[string]videoInfo),
-            ttl:            ttl,
+            ttl:            cacheTTL(cfg.CacheMinutes),
         },
     }
 
@@ -94,6 +93,21 @@ func New(ctx context.Context, cfg Config) (*Service, error) {
     return s, nil
 }
 
+// cacheTTL turns the configured minutes into a TTL; unset means 10 minutes.
+func cacheTTL(minutes int) time.Duration {
+    if minutes <= 0 {
+        minutes = 10
+    }
+    return time.Duration(minutes) * time.Minute
+}
+
+// SetCacheMinutes changes how long the playlist cache stays fresh.
+func (s *Service) SetCacheMinutes(minutes int) {
+    s.cache.mu.Lock()
+    s.cache.ttl = cacheTTL(minutes)
+    s.cache.mu.Unlock()
+}
+
 func (s *Service) ensureCache(ctx context.Context) error {
     s.cache.mu.RLock()
     fresh := time.Since(s.cache.lastRefreshed) < s.cache.ttl && len(s.cache.playlists) > 0
@@ -105,9 +119,11 @@ func (s *Service) ensureCache(ctx context.Context) error {
 }
 
 func (s *Service) refreshCache(ctx context.Context) error {
+    start := time.Now()
     // Load playlists for the authenticated channel
     playlists, err := s.fetchAllPlaylists(ctx)
     if err != nil {
+        slog.WarnContext(ctx, "youtube playlist refresh failed", "err", err)
         return err
     }
     // For each playlist, load its items (video IDs and titles)
@@ -115,6 +131,7 @@ func (s *Service) refreshCache(ctx context.Context) error {
     for _, pl := range playlists {
         vids, err := s.fetchAllPlaylistVideos(ctx, pl.ID)
         if err != nil {
+            slog.WarnContext(ctx, "youtube playlist refresh failed", "playlist", pl.ID, "err", err)
             return err
         }
         inner := make(map[string]videoInfo)
@@ -128,6 +145,7 @@ func (s *Service) refreshCache(ctx context.Context) error {
     s.cache.playlistVideos = playlistVideos
     s.cache.lastRefreshed = time.Now()
     s.cache.mu.Unlock()
+    slog.InfoContext(ctx, "youtube playlist cache refreshed", "playlists", len(playlists), "took", time.Since(start))
     return nil
 }
 
@@ -244,7 +262,7 @@ func (s *Service) getVideoTitle(ctx context.Context, videoID string) (string, er
         return "", err
     }
     if len(resp.Items) == 0 {
-        return "", errors.New("video not found")
+        return "", api.New(api.KindNotFound, "video not found", "")
     }
     return resp.Items[0].Snippet.Title, nil
 }
@@ -266,12 +284,12 @@ func (s *Service) AddVideoToPlaylist(ctx context.Context, playlistName string, v
         return AddResult{}, err
     }
     if !ok {
-        return AddResult{}, errors.New("playlist not found")
+        return AddResult{}, api.New(api.KindNotFound, "playlist not found", "")
     }
 
     videoID := extractVideoID(video)
     if videoID == "" {
-        return AddResult{}, errors.New("invalid video identifier")
+        return AddResult{}, api.New(api.KindInvalidInput, "invalid video identifier", "Pass a video id or a youtube.com / youtu.be URL.")
     }
 
     // Duplicate by ID
@@ -358,7 +376,7 @@ func (s *Service) ListItems(ctx context.Context, playlistName string, fuzzy bool
         return nil, playlistInfo{}, err
     }
     if !ok {
-        return nil, playlistInfo{}, errors.New("playlist not found")
+        return nil, playlistInfo{}, api.New(api.KindNotFound, "playlist not found", "")
     }
     // Ensure we have fresh items for this playlist in cache (already loaded in refresh)
     s.cache.mu.RLock()
@@ -389,7 +407,7 @@ func (s *Service) ListItems(ctx context.Context, playlistName string, fuzzy bool
 // CreatePlaylist creates a new playlist with the given name and privacy status.
 func (s *Service) CreatePlaylist(ctx context.Context, name string, privacy string) (playlistInfo, error) {
     if name == "" {
-        return playlistInfo{}, errors.New("playlist name required")
+        return playlistInfo{}, api.New(api.KindInvalidInput, "playlist name required", "")
     }
     if privacy == "" {
         privacy = "private"
@@ -463,11 +481,16 @@ func (s *Service) startWeeklyRefresh(ctx context.Context) {
             // Proactively refresh to surface any new refresh_token
             ts := s.oauthCfg.TokenSource(ctx, &oauth2.Token{RefreshToken: s.cfg.RefreshToken})
             tok, err := ts.Token()
-            if err == nil && tok != nil {
+            if err != nil {
+                slog.WarnContext(ctx, "youtube token refresh failed", "err", err)
+            } else if tok != nil {
                 if tok.RefreshToken != "" && tok.RefreshToken != s.cfg.RefreshToken {
                     s.cfg.RefreshToken = tok.RefreshToken
+                    slog.InfoContext(ctx, "youtube issued a new refresh token")
                     if s.cfg.OnRefresh != nil {
-                        _ = s.cfg.OnRefresh(tok.RefreshToken)
+                        if err := s.cfg.OnRefresh(tok.RefreshToken); err != nil {
+                            slog.ErrorContext(ctx, "could not save new youtube refresh token", "err", err)
+                        }
                     }
                 }
             }